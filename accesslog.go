@@ -0,0 +1,68 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// accessLogTimeFormat is the layout of the time in the Common Log Format.
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEscaper escapes the values of the quoted access log fields.
+var accessLogEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// CommonLogFormatter formats the entries logged by Logger.HTTPRequest as lines of the Common Log Format (CLF)
+// used by Apache and Nginx, so that they can be consumed by the existing access log tools:
+//
+//	192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /users HTTP/1.1" 200 2326
+//
+// It renders the entry time and the fields "remote_addr" (without the port), "user", "method", "path", "protocol",
+// "status" and "bytes", which must not be nested in groups. Missing or empty fields are rendered as "-".
+func CommonLogFormatter(l *Logger, e *Entry) string {
+	return formatAccessLog(e, false)
+}
+
+// CombinedLogFormatter formats the entries logged by Logger.HTTPRequest as lines of the Combined Log Format,
+// which is the Common Log Format (see CommonLogFormatter) followed by the "referer" and "user_agent" fields:
+//
+//	192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /users HTTP/1.1" 200 2326 "http://example.com/" "Mozilla/5.0"
+func CombinedLogFormatter(l *Logger, e *Entry) string {
+	return formatAccessLog(e, true)
+}
+
+// formatAccessLog formats an entry as a line of the Common or Combined Log Format.
+func formatAccessLog(e *Entry, combined bool) string {
+	field := func(name string) string {
+		if value, ok := e.Fields[name]; ok {
+			if s := fmt.Sprint(logValue(value)); s != "" {
+				return s
+			}
+		}
+		return "-"
+	}
+	host := field("remote_addr")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(host)
+	buf.WriteString(" - ")
+	buf.WriteString(field("user"))
+	if e.Time.IsZero() {
+		buf.WriteString(" - ")
+	} else {
+		buf.WriteString(" [" + e.Time.Format(accessLogTimeFormat) + "] ")
+	}
+	fmt.Fprintf(buf, `"%v %v %v" %v %v`, accessLogEscaper.Replace(field("method")), accessLogEscaper.Replace(field("path")),
+		accessLogEscaper.Replace(field("protocol")), field("status"), field("bytes"))
+	if combined {
+		fmt.Fprintf(buf, ` "%v" "%v"`, accessLogEscaper.Replace(field("referer")), accessLogEscaper.Replace(field("user_agent")))
+	}
+	return buf.String()
+}
@@ -0,0 +1,292 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log/internal/batching"
+)
+
+// AckTarget sends log messages in batches to a collector which acknowledges every batch,
+// and resends the batches that are not acknowledged. It is meant for applications that cannot
+// tolerate losing log messages silently.
+//
+// Every batch is sent as a request frame, and the collector replies with an ack frame on the same
+// connection. A frame is a 4-byte big-endian length, at most 16MB, followed by that many bytes of JSON:
+//
+//	request: {"id":1,"entries":[{"time":"...","level":"Error","category":"app","message":"..."}]}
+//	ack:     {"id":1}
+//	nack:    {"id":1,"error":"reason"}
+//
+// The entries are encoded by JSONFormatter. A batch is delivered once an ack with its id is received.
+// If writing the request fails, no ack is received within Timeout, or the collector replies with an
+// error, the connection is closed and the batch is resent after RetryInterval, up to MaxRetries times.
+// Batches still undelivered after that, or when the target is closed, are dropped and reported by CloseError.
+//
+// A batch is sent as soon as it has BatchSize entries, or as soon as adding an entry would make the encoded
+// entries exceed MaxBatchBytes, whichever comes first, so that the requests stay within the body limit of
+// the collector. An entry larger than MaxBatchBytes by itself is sent alone. The limit does not include the
+// few bytes of the request envelope.
+//
+// Entries are queued in a channel of BufferSize entries. When it is full, the logger waits for room,
+// so that no entry is dropped while the collector is slow.
+type AckTarget struct {
+	*Filter
+	Name          string            // the name identifying the target in diagnostics
+	Tags          map[string]string // the tags identifying the target in diagnostics
+	Network       string            // the network of the collector, e.g. "tcp" or "unix"
+	Address       string            // the address of the collector
+	BatchSize     int               // the maximum number of entries sent in a batch
+	MaxBatchBytes int               // the maximum number of bytes of the encoded entries sent in a batch. 0 means no limit.
+	FlushInterval time.Duration     // how often a partial batch is sent
+	Timeout       time.Duration     // the time allowed for connecting, sending a batch and receiving its ack
+	MaxRetries    int               // how many times an unacknowledged batch is resent before it is dropped
+	RetryInterval time.Duration     // the time waited before resending a batch
+	BufferSize    int               // the size of the entry channel. 0 means the BufferSize of the logger.
+
+	entries           chan *Entry
+	defaultBufferSize int
+	conn              net.Conn
+	reader            *bufio.Reader
+	id                uint64
+	batch             []json.RawMessage // the encoded entries to be sent in the next batch
+	batchBytes        int               // the number of bytes of the encoded entries of the batch
+	drops             batching.Drops    // the entries in the dropped batches
+	closeErr          error
+	close             chan bool
+}
+
+// NewAckTarget creates an AckTarget.
+// The new AckTarget takes these default options:
+// MaxLevel: LevelDebug, BatchSize: 100, FlushInterval: 1s, Timeout: 5s, MaxRetries: 3, RetryInterval: 1s, BufferSize: 1024.
+// You must specify the Network and Address fields.
+func NewAckTarget() *AckTarget {
+	return &AckTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Timeout:       5 * time.Second,
+		MaxRetries:    3,
+		RetryInterval: time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// ackRequest is a batch of entries sent to the collector.
+type ackRequest struct {
+	ID      uint64            `json:"id"`
+	Entries []json.RawMessage `json:"entries"`
+}
+
+// ackResponse is the reply of the collector to a batch.
+type ackResponse struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// Open prepares AckTarget for processing log messages.
+// The connection to the collector is established when the first batch is sent.
+func (t *AckTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Network == "" {
+		return errors.New("AckTarget.Network must be specified")
+	}
+	if t.Address == "" {
+		return errors.New("AckTarget.Address must be specified")
+	}
+	if t.BatchSize <= 0 {
+		return errors.New("AckTarget.BatchSize must be greater than 0")
+	}
+	if t.MaxBatchBytes < 0 {
+		return errors.New("AckTarget.MaxBatchBytes must be no less than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("AckTarget.BufferSize must be no less than 0")
+	}
+	if t.MaxRetries < 0 {
+		return errors.New("AckTarget.MaxRetries must be no less than 0")
+	}
+	bufferSize := t.BufferSize
+	if bufferSize == 0 {
+		bufferSize = t.defaultBufferSize
+	}
+	t.entries = make(chan *Entry, bufferSize)
+	t.conn = nil
+	t.batch, t.batchBytes = nil, 0
+	t.drops = batching.Drops{Writer: errWriter}
+	go t.sendBatches()
+	return nil
+}
+
+func (t *AckTarget) setDefaultBufferSize(size int) {
+	t.defaultBufferSize = size
+}
+
+// Process puts an allowed log entry into the channel of the entries to be sent.
+func (t *AckTarget) Process(e *Entry) {
+	if e == nil || t.Allow(e) {
+		t.entries <- e
+	}
+}
+
+// Close closes the ack target.
+func (t *AckTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the ack target after sending the pending entries,
+// and returns an error if some entries were not delivered.
+func (t *AckTarget) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// sendBatches collects the entries into batches and sends them until the nil entry is received.
+func (t *AckTarget) sendBatches() {
+	batching.Run(t.entries, t.FlushInterval, t.add, t.flush)
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	t.closeErr = t.drops.Err(t.label(), "deliver", "entries")
+	t.close <- true
+}
+
+// add encodes an entry into the batch, sending the batch first if the entry would make it exceed MaxBatchBytes,
+// and afterwards if it is full.
+func (t *AckTarget) add(e *Entry) {
+	data := json.RawMessage(JSONFormatter(nil, e))
+	if t.MaxBatchBytes > 0 && len(t.batch) > 0 && t.batchBytes+len(data) > t.MaxBatchBytes {
+		t.flush()
+	}
+	t.batch = append(t.batch, data)
+	t.batchBytes += len(data)
+	if len(t.batch) >= t.BatchSize || t.MaxBatchBytes > 0 && t.batchBytes >= t.MaxBatchBytes {
+		t.flush()
+	}
+}
+
+// flush sends the pending batch.
+func (t *AckTarget) flush() {
+	t.send(t.batch)
+	t.batch, t.batchBytes = nil, 0
+}
+
+// send sends a batch until it is acknowledged or the retries are exhausted.
+func (t *AckTarget) send(batch []json.RawMessage) {
+	if len(batch) == 0 {
+		return
+	}
+	t.id++
+	data, err := json.Marshal(ackRequest{ID: t.id, Entries: batch})
+	if err != nil {
+		t.drop(batch, err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		if err = t.deliver(t.id, data); err == nil {
+			return
+		}
+		if t.conn != nil {
+			t.conn.Close()
+			t.conn = nil
+		}
+		if attempt >= t.MaxRetries {
+			break
+		}
+		time.Sleep(t.RetryInterval)
+	}
+	t.drop(batch, err)
+}
+
+// drop records a batch that cannot be delivered.
+func (t *AckTarget) drop(batch []json.RawMessage, err error) {
+	t.drops.Drop(t.label(), len(batch), "entries", err)
+}
+
+// deliver sends a request frame and waits for its ack.
+func (t *AckTarget) deliver(id uint64, data []byte) error {
+	if t.conn == nil {
+		conn, err := net.DialTimeout(t.Network, t.Address, t.Timeout)
+		if err != nil {
+			return fmt.Errorf("unable to connect to %v: %v", t.Address, err)
+		}
+		t.conn = conn
+		t.reader = bufio.NewReader(conn)
+	}
+	if t.Timeout > 0 {
+		t.conn.SetDeadline(time.Now().Add(t.Timeout))
+	}
+	if err := writeFrame(t.conn, data); err != nil {
+		return err
+	}
+	for {
+		data, err := readFrame(t.reader)
+		if err != nil {
+			return err
+		}
+		var ack ackResponse
+		if err := json.Unmarshal(data, &ack); err != nil {
+			return fmt.Errorf("invalid ack: %v", err)
+		}
+		if ack.ID != id {
+			// a late ack of a batch that was already resent
+			continue
+		}
+		if ack.Error != "" {
+			return fmt.Errorf("the collector rejected the batch: %v", ack.Error)
+		}
+		return nil
+	}
+}
+
+// writeFrame writes data prefixed by its length.
+func writeFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err := w.Write(frame)
+	return err
+}
+
+// maxFrameLen is the maximum length of a frame read by readFrame.
+const maxFrameLen = 16 << 20
+
+// readFrame reads data prefixed by its length. A length above maxFrameLen is an error,
+// so that a faulty collector cannot make the target allocate up to 4GB.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameLen {
+		return nil, fmt.Errorf("the frame length %v exceeds the maximum of %v bytes", size, maxFrameLen)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *AckTarget) Describe() TargetInfo {
+	return newTargetInfo("AckTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *AckTarget) label() string {
+	return targetLabel("AckTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,171 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runAckCollector accepts connections and passes the requests to handle, which returns the ack to reply or nil to drop the connection.
+func runAckCollector(t *testing.T, handle func(req ackRequest) *ackResponse) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					data, err := readFrame(conn)
+					if err != nil {
+						return
+					}
+					var req ackRequest
+					json.Unmarshal(data, &req)
+					ack := handle(req)
+					if ack == nil {
+						return
+					}
+					data, _ = json.Marshal(ack)
+					writeFrame(conn, data)
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestAckTarget(t *testing.T) {
+	requests := make(chan ackRequest, 10)
+	listener := runAckCollector(t, func(req ackRequest) *ackResponse {
+		requests <- req
+		if len(requests) == 1 {
+			// fail the first attempt so that the batch is resent
+			return nil
+		}
+		return &ackResponse{ID: req.ID}
+	})
+	defer listener.Close()
+
+	logger := NewLogger()
+	target := NewAckTarget()
+	target.Network = "tcp"
+	target.Address = listener.Addr().String()
+	target.BatchSize = 2
+	target.RetryInterval = 10 * time.Millisecond
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+	logger.Info("t3")
+	if err := logger.CloseError(); err != nil {
+		t.Fatalf("logger.CloseError(): %v", err)
+	}
+
+	var ids []uint64
+	var messages []string
+	for n := len(requests); n > 0; n-- {
+		req := <-requests
+		ids = append(ids, req.ID)
+		for _, e := range req.Entries {
+			var entry map[string]interface{}
+			json.Unmarshal(e, &entry)
+			messages = append(messages, entry["message"].(string))
+		}
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 1 || ids[2] != 2 {
+		t.Errorf("request ids = %v, expected %v", ids, []uint64{1, 1, 2})
+	}
+	if result := strings.Join(messages, ","); result != "t1,t2,t1,t2,t3" {
+		t.Errorf("messages = %v, expected %v", result, "t1,t2,t1,t2,t3")
+	}
+}
+
+func TestAckTargetUndelivered(t *testing.T) {
+	listener := runAckCollector(t, func(req ackRequest) *ackResponse {
+		return &ackResponse{ID: req.ID, Error: "disk full"}
+	})
+	defer listener.Close()
+
+	logger := NewLogger()
+	logger.ErrorWriter = ioutil.Discard
+	target := NewAckTarget()
+	target.Network = "tcp"
+	target.Address = listener.Addr().String()
+	target.MaxRetries = 1
+	target.RetryInterval = 10 * time.Millisecond
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "AckTarget was unable to deliver 2 entries") {
+		t.Errorf("logger.CloseError() = %v, expected the undelivered entries to be reported", err)
+	}
+}
+
+func TestAckTargetMaxBatchBytes(t *testing.T) {
+	requests := make(chan ackRequest, 10)
+	listener := runAckCollector(t, func(req ackRequest) *ackResponse {
+		requests <- req
+		return &ackResponse{ID: req.ID}
+	})
+	defer listener.Close()
+
+	logger := NewLogger()
+	target := NewAckTarget()
+	target.Network = "tcp"
+	target.Address = listener.Addr().String()
+	target.BatchSize = 10
+	target.MaxBatchBytes = 600
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	large := strings.Repeat("x", 200)
+	for i := 0; i < 4; i++ {
+		logger.Info(large)
+	}
+	logger.Info(strings.Repeat("y", 1000))
+	logger.Info("t1")
+	if err := logger.CloseError(); err != nil {
+		t.Fatalf("logger.CloseError(): %v", err)
+	}
+
+	var sizes []int
+	for n := len(requests); n > 0; n-- {
+		req := <-requests
+		sizes = append(sizes, len(req.Entries))
+		if bytes := len(req.Entries) * len(req.Entries[0]); len(req.Entries) > 1 && bytes > target.MaxBatchBytes {
+			t.Errorf("a batch of %v entries has %v bytes, expected at most %v", len(req.Entries), bytes, target.MaxBatchBytes)
+		}
+	}
+	if result := fmt.Sprint(sizes); result != "[2 2 1 1]" {
+		t.Errorf("batch sizes = %v, expected %v", result, "[2 2 1 1]")
+	}
+}
+
+func TestReadFrameMaxLen(t *testing.T) {
+	var frame []byte
+	frame = append(frame, 0xff, 0xff, 0xff, 0xff)
+	if _, err := readFrame(strings.NewReader(string(frame))); err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+		t.Errorf("readFrame() = %v, expected an error about the frame length", err)
+	}
+	var buf strings.Builder
+	writeFrame(&buf, []byte(`{"id":1}`))
+	if data, err := readFrame(strings.NewReader(buf.String())); err != nil || string(data) != `{"id":1}` {
+		t.Errorf("readFrame() = %q, %v, expected %q", data, err, `{"id":1}`)
+	}
+}
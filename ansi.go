@@ -0,0 +1,22 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiPattern matches the ANSI escape sequences: the control sequences (e.g. colors such as "\x1b[31m"),
+// the operating system commands (e.g. window titles and hyperlinks) and the other two-character escapes.
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// stripANSI removes the ANSI escape sequences from a message.
+func stripANSI(msg string) string {
+	if strings.IndexByte(msg, '\x1b') < 0 {
+		return msg
+	}
+	return ansiPattern.ReplaceAllString(msg, "")
+}
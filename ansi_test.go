@@ -0,0 +1,53 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		msg, expected string
+	}{
+		{"plain", "plain"},
+		{"\x1b[31merror\x1b[0m", "error"},
+		{"\x1b[1;38;5;208mbold orange\x1b[m done", "bold orange done"},
+		{"\x1b]0;title\x07text", "text"},
+		{"\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"a\x1bMb", "ab"},
+		{"100%\r\x1b[K", "100%\r"},
+	}
+	for _, test := range tests {
+		if msg := stripANSI(test.msg); msg != test.expected {
+			t.Errorf("stripANSI(%q) = %q, expected %q", test.msg, msg, test.expected)
+		}
+	}
+}
+
+func TestConsoleTargetStripANSI(t *testing.T) {
+	defer func(f func(io.Writer) bool) { isTerminal = f }(isTerminal)
+	for _, terminal := range []bool{false, true} {
+		isTerminal = func(io.Writer) bool { return terminal }
+		target := NewConsoleTarget()
+		var buf bytes.Buffer
+		target.Writer = &buf
+		target.ColorMode = false
+		target.StripANSI = true
+		if err := target.Open(nil); err != nil {
+			t.Fatalf("target.Open(): %v", err)
+		}
+		target.Process(&Entry{Level: LevelInfo, FormattedMessage: "\x1b[31mred\x1b[0m"})
+		expected := "red\n"
+		if terminal {
+			expected = "\x1b[31mred\x1b[0m\n"
+		}
+		if buf.String() != expected {
+			t.Errorf("output with terminal %v = %q, expected %q", terminal, buf.String(), expected)
+		}
+	}
+}
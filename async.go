@@ -0,0 +1,121 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"io"
+)
+
+// AsyncTarget processes the entries of another target in a goroutine of its own, so that a slow target
+// does not delay the other targets of the logger. The entries are queued in a channel of BufferSize entries;
+// when it is full, the logger waits for room, so that no entry is dropped.
+//
+// When the logger is closed, the close signal is queued after the pending entries and then passed to the
+// wrapped target, and Close waits until the wrapped target is closed too. As this holds for an AsyncTarget
+// wrapping another one, all entries have been processed by the innermost target when Logger.Close returns.
+type AsyncTarget struct {
+	Target     Target // the wrapped target
+	BufferSize int    // the size of the entry channel. 0 means the BufferSize of the logger.
+
+	entries           chan *Entry
+	defaultBufferSize int
+	done              chan bool
+}
+
+// NewAsyncTarget creates an AsyncTarget wrapping the given target.
+// The new AsyncTarget takes these default options:
+// BufferSize: 1024.
+func NewAsyncTarget(target Target) *AsyncTarget {
+	return &AsyncTarget{
+		Target:     target,
+		BufferSize: 1024,
+	}
+}
+
+func (t *AsyncTarget) setDefaultBufferSize(size int) {
+	t.defaultBufferSize = size
+	if target, ok := t.Target.(bufferedTarget); ok {
+		target.setDefaultBufferSize(size)
+	}
+}
+
+// Open opens the wrapped target and starts processing its entries.
+func (t *AsyncTarget) Open(errWriter io.Writer) error {
+	if t.Target == nil {
+		return errors.New("AsyncTarget.Target must be set")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("AsyncTarget.BufferSize must be no less than 0")
+	}
+	if err := t.Target.Open(errWriter); err != nil {
+		return err
+	}
+	bufferSize := t.BufferSize
+	if bufferSize == 0 {
+		bufferSize = t.defaultBufferSize
+	}
+	t.entries = make(chan *Entry, bufferSize)
+	t.done = make(chan bool, 0)
+	go t.process()
+	return nil
+}
+
+// ProcessClose queues the close signal, which is passed to the wrapped target after the queued entries.
+func (t *AsyncTarget) ProcessClose() {
+	t.entries <- nil
+}
+
+// Process queues an entry to be processed by the wrapped target.
+func (t *AsyncTarget) Process(e *Entry) {
+	t.entries <- e
+}
+
+// process passes the queued entries to the wrapped target until the nil entry signaling the close is queued,
+// which is passed to the wrapped target with ProcessClose if it implements ProcessCloser.
+func (t *AsyncTarget) process() {
+	for e := range t.entries {
+		if e == nil {
+			processClose(t.Target)
+			close(t.done)
+			return
+		}
+		t.Target.Process(e)
+	}
+}
+
+// Close closes the async target.
+func (t *AsyncTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError waits until the wrapped target has processed all entries and is closed,
+// and returns the error reported by the wrapped target if it implements ErrorCloser.
+func (t *AsyncTarget) CloseError() error {
+	// the wrapped target is closed while the close signal is being passed to it,
+	// as its Process may wait for its Close like the processing goroutine of the logger does
+	var err error
+	if closer, ok := t.Target.(ErrorCloser); ok {
+		err = closer.CloseError()
+	} else {
+		t.Target.Close()
+	}
+	<-t.done
+	return err
+}
+
+// Describe returns the type of the target together with the description of the wrapped target.
+func (t *AsyncTarget) Describe() TargetInfo {
+	wrapped := describeTarget(t.Target)
+	return TargetInfo{Type: "AsyncTarget", Enabled: true, Wrapped: &wrapped}
+}
+
+// FailureStreak returns the number of consecutive failures of the wrapped target if it implements FailureReporter, or 0.
+func (t *AsyncTarget) FailureStreak() int {
+	if reporter, ok := t.Target.(FailureReporter); ok {
+		return reporter.FailureStreak()
+	}
+	return 0
+}
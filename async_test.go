@@ -0,0 +1,69 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+// slowTarget records the entries it processes after a delay, and reports an error when closed.
+type slowTarget struct {
+	messages []string
+	close    chan bool
+}
+
+func (t *slowTarget) Open(io.Writer) error {
+	t.close = make(chan bool, 0)
+	return nil
+}
+
+func (t *slowTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.close <- true
+		return
+	}
+	time.Sleep(time.Millisecond)
+	t.messages = append(t.messages, e.Message)
+}
+
+func (t *slowTarget) Close() {
+	t.CloseError()
+}
+
+func (t *slowTarget) CloseError() error {
+	<-t.close
+	return errors.New("slowTarget closed")
+}
+
+func TestAsyncTarget(t *testing.T) {
+	inner := &slowTarget{}
+	middle := log.NewAsyncTarget(inner)
+	middle.BufferSize = 1
+	outer := log.NewAsyncTarget(middle)
+	outer.BufferSize = 0
+	logger := log.NewLogger()
+	logger.BufferSize = 2
+	logger.Targets = append(logger.Targets, outer)
+	logger.Open()
+	for i := 0; i < 20; i++ {
+		logger.Info("t%v", i)
+	}
+	err := logger.CloseError()
+
+	if len(inner.messages) != 20 {
+		t.Errorf("the innermost target processed %v entries before Close returned, expected 20", len(inner.messages))
+	}
+	if err == nil || err.Error() != "slowTarget closed" {
+		t.Errorf("logger.CloseError() = %v, expected the error of the innermost target", err)
+	}
+	if err := log.NewAsyncTarget(nil).Open(nil); err == nil {
+		t.Errorf("AsyncTarget.Open() should fail without a target")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// BroadcastLogger dispatches every log call to multiple loggers.
+// Each logger applies its own level filtering, formatting and targets independently.
+// This differs from attaching multiple targets to one logger, which share the logger's configuration.
+type BroadcastLogger struct {
+	Loggers []*Logger // the loggers receiving the log calls
+}
+
+// NewBroadcastLogger creates a BroadcastLogger dispatching log calls to the given loggers.
+func NewBroadcastLogger(loggers ...*Logger) *BroadcastLogger {
+	return &BroadcastLogger{Loggers: loggers}
+}
+
+// WithField returns a broadcast logger whose loggers have a single field added.
+func (b *BroadcastLogger) WithField(name string, value interface{}) *BroadcastLogger {
+	return b.WithFields(Fields{
+		name: value,
+	})
+}
+
+// WithFields returns a broadcast logger whose loggers have multiple fields added.
+func (b *BroadcastLogger) WithFields(fields Fields) *BroadcastLogger {
+	ret := &BroadcastLogger{Loggers: make([]*Logger, len(b.Loggers))}
+	for i, l := range b.Loggers {
+		ret.Loggers[i] = l.WithFields(fields)
+	}
+	return ret
+}
+
+// Emergency logs a message indicating the system is unusable.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Emergency(format string, a ...interface{}) {
+	b.log(LevelEmergency, format, a...)
+}
+
+// Alert logs a message indicating action must be taken immediately.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Alert(format string, a ...interface{}) {
+	b.log(LevelAlert, format, a...)
+}
+
+// Critical logs a message indicating critical conditions.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Critical(format string, a ...interface{}) {
+	b.log(LevelCritical, format, a...)
+}
+
+// Error logs a message indicating an error condition.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Error(format string, a ...interface{}) {
+	b.log(LevelError, format, a...)
+}
+
+// Warning logs a message indicating a warning condition.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Warning(format string, a ...interface{}) {
+	b.log(LevelWarning, format, a...)
+}
+
+// Notice logs a message meaning normal but significant condition.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Notice(format string, a ...interface{}) {
+	b.log(LevelNotice, format, a...)
+}
+
+// Info logs a message for informational purpose.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Info(format string, a ...interface{}) {
+	b.log(LevelInfo, format, a...)
+}
+
+// Debug logs a message for debugging purpose.
+// Please refer to Logger.Error() for how to use this method.
+func (b *BroadcastLogger) Debug(format string, a ...interface{}) {
+	b.log(LevelDebug, format, a...)
+}
+
+// Log logs a message of a specified severity level with every logger.
+func (b *BroadcastLogger) Log(level Level, format string, a ...interface{}) {
+	b.log(level, format, a...)
+}
+
+// log logs a message with every logger. It must be called directly by the exported methods
+// so that the call stack of the entries starts at the caller of those methods.
+func (b *BroadcastLogger) log(level Level, format string, a ...interface{}) {
+	for _, l := range b.Loggers {
+		l.log(4, level, format, a...)
+	}
+}
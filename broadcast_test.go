@@ -0,0 +1,67 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBroadcastLogger(t *testing.T) {
+	logger1 := NewLogger()
+	target1 := &MemoryTarget{ready: make(chan bool, 0)}
+	logger1.Targets = append(logger1.Targets, target1)
+	logger1.Open()
+
+	logger2 := NewLogger()
+	logger2.MaxLevel = LevelError
+	target2 := &MemoryTarget{ready: make(chan bool, 0)}
+	logger2.Targets = append(logger2.Targets, target2)
+	logger2.Open()
+
+	b := NewBroadcastLogger(logger1, logger2.GetLogger("plugin"))
+	b.Info("t1")
+	b.WithField("key", "value").Error("t2: %v", 2)
+
+	logger1.Close()
+	logger2.Close()
+
+	if len(target1.entries) != 2 {
+		t.Fatalf("len(target1.entries) = %v, expected %v", len(target1.entries), 2)
+	}
+	if len(target2.entries) != 1 {
+		t.Fatalf("len(target2.entries) = %v, expected %v", len(target2.entries), 1)
+	}
+	e := target2.entries[0]
+	if e.Message != "t2: 2" || e.Category != "plugin" || e.Fields["key"] != "value" {
+		t.Errorf("target2.entries[0] = %v/%v/%v, expected %v/%v/%v", e.Message, e.Category, e.Fields["key"], "t2: 2", "plugin", "value")
+	}
+	if len(b.Loggers[0].Fields) != 0 {
+		t.Errorf("WithField() should not modify the original loggers")
+	}
+}
+
+func TestBroadcastLoggerCallStack(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	b := NewBroadcastLogger(logger)
+	b.Info("t1")
+	b.Log(LevelInfo, "t2")
+
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	for _, e := range target.entries {
+		if !strings.Contains(e.CallStack, "broadcast_test.go") {
+			t.Errorf("%v: CallStack = %q, expected the frame of the caller", e.Message, e.CallStack)
+		}
+	}
+}
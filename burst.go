@@ -0,0 +1,49 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// BurstDetector detects bursts of error entries, i.e. more than Threshold entries of LevelError
+// or above within Window. When the threshold is exceeded, the logger logs a synthetic Critical
+// "error rate spike" entry reporting the observed rate, at most once per window. The synthetic
+// entries are not counted, so they do not feed back into the detection.
+// A BurstDetector is safe for concurrent use.
+type BurstDetector struct {
+	Threshold int           // the number of error entries per Window above which an alert is logged
+	Window    time.Duration // the length of the time window in which the error entries are counted. It must be positive.
+
+	lock    sync.Mutex
+	start   time.Time // the start of the current window
+	count   int       // the number of error entries in the current window
+	alerted bool      // whether an alert was logged in the current window
+}
+
+// NewBurstDetector creates a BurstDetector alerting when more than threshold error entries are logged within window.
+func NewBurstDetector(threshold int, window time.Duration) *BurstDetector {
+	return &BurstDetector{
+		Threshold: threshold,
+		Window:    window,
+	}
+}
+
+// observe counts an error entry logged at the given time. It returns the number of error entries
+// in the current window and the time elapsed since the window started when an alert should be logged.
+func (d *BurstDetector) observe(now time.Time) (int, time.Duration, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.start.IsZero() || now.Sub(d.start) >= d.Window {
+		d.start, d.count, d.alerted = now, 0, false
+	}
+	d.count++
+	if d.alerted || d.count <= d.Threshold {
+		return 0, 0, false
+	}
+	d.alerted = true
+	return d.count, now.Sub(d.start), true
+}
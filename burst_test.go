@@ -0,0 +1,57 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBurstDetector(t *testing.T) {
+	logger := NewLogger()
+	logger.ErrorBurst = NewBurstDetector(3, time.Hour)
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	for i := 0; i < 10; i++ {
+		logger.Info("info")
+		logger.Error("error")
+	}
+	logger.Close()
+
+	var alerts []*Entry
+	for _, e := range target.entries {
+		if e.Level == LevelCritical {
+			alerts = append(alerts, e)
+		}
+	}
+	if len(target.entries) != 21 || len(alerts) != 1 {
+		t.Fatalf("got %v entries and %v alerts, expected %v and %v", len(target.entries), len(alerts), 21, 1)
+	}
+	if !strings.HasPrefix(alerts[0].Message, "error rate spike: 4 error entries in ") || alerts[0] != target.entries[8] {
+		t.Errorf("alert = %q, expected to follow the 4th error", alerts[0].Message)
+	}
+}
+
+func TestBurstDetectorWindow(t *testing.T) {
+	d := NewBurstDetector(1, time.Second)
+	now := time.Now()
+	tests := []struct {
+		offset   time.Duration
+		expected bool
+	}{
+		{0, false},
+		{100 * time.Millisecond, true},
+		{200 * time.Millisecond, false}, // at most one alert per window
+		{time.Second, false},            // a new window starts
+		{1100 * time.Millisecond, true},
+	}
+	for i, test := range tests {
+		if _, _, ok := d.observe(now.Add(test.offset)); ok != test.expected {
+			t.Errorf("%v: observe() = %v, expected %v", i, ok, test.expected)
+		}
+	}
+}
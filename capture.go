@@ -0,0 +1,130 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CaptureStdout replaces os.Stdout with a pipe whose lines are logged at the given level, which is useful for
+// wrapping legacy libraries printing to the standard output. The empty lines are skipped. The returned function
+// restores os.Stdout; it returns once the lines written so far have been logged, and only its first call has
+// an effect.
+//
+// Only os.Stdout is replaced, not the underlying file descriptor: the code that has already stored os.Stdout
+// (including a ConsoleTarget created before the call, which is what keeps the captured lines from being captured
+// again), cgo code and child processes still write to the original standard output. Because the variable is
+// replaced without synchronization, CaptureStdout and the restore function should be called while no other
+// goroutine uses os.Stdout, typically at startup and shutdown. Captures may be nested if they are restored in
+// the reverse order.
+func (l *Logger) CaptureStdout(level Level) (restore func(), err error) {
+	return l.capture(&os.Stdout, level)
+}
+
+// CaptureStderr is like CaptureStdout, but replaces os.Stderr. Note that panics and runtime errors are written
+// to the file descriptor, so they are not captured.
+func (l *Logger) CaptureStderr(level Level) (restore func(), err error) {
+	return l.capture(&os.Stderr, level)
+}
+
+// capture replaces the given file with a pipe whose lines are logged at the given level.
+func (l *Logger) capture(file **os.File, level Level) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	original := *file
+	*file = w
+	done := make(chan bool, 0)
+	go func() {
+		l.logLines(r, level)
+		r.Close()
+		close(done)
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			*file = original
+			w.Close()
+			<-done
+		})
+	}, nil
+}
+
+// logLines logs each line read from r at the given level until r is exhausted.
+func (l *Logger) logLines(r io.Reader, level Level) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			l.log(3, level, "%v", line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// entryCapture holds the entries captured by Capture.
+type entryCapture struct {
+	lock    sync.Mutex
+	entries []*Entry
+}
+
+// Capture runs fn and returns the entries logged meanwhile by the logger and the loggers sharing its targets
+// (i.e. derived from the same root logger), including those logged by other goroutines, in the order they were
+// logged. This is a one-liner for tests checking what a function logs:
+//
+//	entries := logger.Capture(func() {
+//		handler.ServeHTTP(w, r)
+//	})
+//
+// The entries are captured synchronously by the log calls once they pass the level filtering and sampling of
+// the logger and are formatted, so that no flush or wait is needed; they are still sent to the targets, whose
+// filters do not apply to the capture. Only the entries logged while the logger is open are captured.
+// The captures may be nested or run concurrently; each of them gets all the entries logged while it runs.
+// The entries must not be modified.
+func (l *Logger) Capture(fn func()) []*Entry {
+	c := &entryCapture{}
+	l.updateCaptures(func(captures []*entryCapture) []*entryCapture {
+		return append(captures, c)
+	})
+	func() {
+		defer l.updateCaptures(func(captures []*entryCapture) []*entryCapture {
+			for i, capture := range captures {
+				if capture == c {
+					return append(captures[:i], captures[i+1:]...)
+				}
+			}
+			return captures
+		})
+		fn()
+	}()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entries
+}
+
+// updateCaptures replaces the captures with the result of update, which is given a copy of them.
+func (l *coreLogger) updateCaptures(update func([]*entryCapture) []*entryCapture) {
+	l.captureLock.Lock()
+	defer l.captureLock.Unlock()
+	captures, _ := l.captures.Load().([]*entryCapture)
+	l.captures.Store(update(append([]*entryCapture(nil), captures...)))
+}
+
+// captureEntry adds an entry to the running captures.
+func (l *coreLogger) captureEntry(entry *Entry) {
+	captures, _ := l.captures.Load().([]*entryCapture)
+	for _, c := range captures {
+		c.lock.Lock()
+		c.entries = append(c.entries, entry)
+		c.lock.Unlock()
+	}
+}
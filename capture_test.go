@@ -0,0 +1,101 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCaptureStdout(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	original := os.Stdout
+	restore, err := logger.CaptureStdout(LevelNotice)
+	if err != nil {
+		t.Fatalf("logger.CaptureStdout() = %v", err)
+	}
+	if os.Stdout == original {
+		t.Fatalf("os.Stdout was not replaced")
+	}
+	fmt.Println("line 1")
+	fmt.Print("line 2\r\n\nline 3")
+	restore()
+	restore()
+	if os.Stdout != original {
+		t.Errorf("os.Stdout was not restored")
+	}
+	logger.Close()
+
+	expected := []string{"line 1", "line 2", "line 3"}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("number of entries = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.Message != expected[i] || e.Level != LevelNotice {
+			t.Errorf("entries[%v] = %v %q, expected %v %q", i, e.Level, e.Message, LevelNotice, expected[i])
+		}
+	}
+}
+
+func TestCaptureStderr(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	restore, err := logger.CaptureStderr(LevelError)
+	if err != nil {
+		t.Fatalf("logger.CaptureStderr() = %v", err)
+	}
+	fmt.Fprintln(os.Stderr, "failed")
+	restore()
+	logger.Close()
+
+	if len(target.entries) != 1 || target.entries[0].Message != "failed" || target.entries[0].Level != LevelError {
+		t.Errorf("entries = %v, expected a single Error entry %q", target.entries, "failed")
+	}
+}
+
+func TestLoggerCapture(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Info("before")
+	var inner []*Entry
+	entries := logger.Capture(func() {
+		logger.Info("t1")
+		logger.Debug("filtered")
+		inner = logger.GetLogger("db").Capture(func() {
+			logger.GetLogger("db").Warning("t2 %v", 2)
+		})
+	})
+	logger.Info("after")
+	logger.Close()
+
+	var messages []string
+	for _, e := range entries {
+		messages = append(messages, e.FormattedMessage)
+	}
+	if len(entries) != 2 || entries[0].Message != "t1" || entries[1].Message != "t2 2" || entries[1].Category != "db" {
+		t.Errorf("captured entries = %q, expected t1 and t2 2", messages)
+	}
+	if len(inner) != 1 || inner[0] != entries[1] {
+		t.Errorf("nested capture = %v, expected the t2 entry", inner)
+	}
+	if len(target.entries) != 4 {
+		t.Errorf("the target got %v entries, expected 4", len(target.entries))
+	}
+	if entries := logger.Capture(func() {}); len(entries) != 0 {
+		t.Errorf("empty capture = %v, expected no entry", entries)
+	}
+}
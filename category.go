@@ -0,0 +1,124 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "strings"
+
+// categoryFormatter is a formatter set by SetCategoryFormatter.
+type categoryFormatter struct {
+	category  string
+	formatter Formatter
+}
+
+// SetCategoryFormatter sets the formatter of the messages of the given category, so that categories can be rendered
+// differently on the same targets, e.g. the "audit" category as JSON while the others stay human-readable:
+//
+//	logger.SetCategoryFormatter("audit", log.JSONFormatter)
+//
+// Like the categories of a Filter, the category can use "*" as a suffix for wildcard matching, e.g. "app.db.*".
+// The formatter applies to the loggers derived from the same root logger, and takes precedence over their
+// Formatter, including the one passed to GetLogger, which is used for the other categories. When several
+// categories match, an exact category wins over the wildcards, and the longest wildcard wins over the shorter
+// ones. Targets have no formatters of their own: they all receive the message formatted once by the logger.
+// Setting the formatter of a category again replaces it, and setting it to nil removes it.
+// SetCategoryFormatter may be called while messages are logged.
+func (l *Logger) SetCategoryFormatter(category string, formatter Formatter) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	formats := l.categoryFormatters()
+	// copy the formatters so that those being used by the log calls are not modified
+	result := make([]categoryFormatter, 0, len(formats)+1)
+	for _, f := range formats {
+		if f.category != category {
+			result = append(result, f)
+		}
+	}
+	if formatter != nil {
+		result = append(result, categoryFormatter{category, formatter})
+	}
+	l.formats.Store(result)
+}
+
+// categoryFormatters returns the formatters set by SetCategoryFormatter.
+func (l *coreLogger) categoryFormatters() []categoryFormatter {
+	formats, _ := l.formats.Load().([]categoryFormatter)
+	return formats
+}
+
+// categoryFormatter returns the formatter set by SetCategoryFormatter for the given category, or nil if none is set.
+func (l *coreLogger) categoryFormatter(category string) Formatter {
+	formats := l.categoryFormatters()
+	i := matchCategory(len(formats), func(i int) string { return formats[i].category }, category)
+	if i < 0 {
+		return nil
+	}
+	return formats[i].formatter
+}
+
+// matchCategory returns the index of the best of n categories matching the given category, or -1 if none matches.
+// An exact category wins over the wildcards, and the longest wildcard wins over the shorter ones.
+func matchCategory(n int, categoryAt func(int) string, category string) int {
+	result, longest := -1, -1
+	for i := 0; i < n; i++ {
+		c := categoryAt(i)
+		if c == category {
+			return i
+		}
+		if prefix := strings.TrimSuffix(c, "*"); prefix != c && len(prefix) > longest && strings.HasPrefix(category, prefix) {
+			result, longest = i, len(prefix)
+		}
+	}
+	return result
+}
+
+// categoryDepth is a call stack depth set by SetCategoryCallStackDepth.
+type categoryDepth struct {
+	category string
+	depth    int
+}
+
+// SetCategoryCallStackDepth sets the number of call stack frames logged for the messages of the given category,
+// so that the call stacks, which are expensive to capture, are only logged for the subsystems being diagnosed:
+//
+//	logger.SetCategoryCallStackDepth("payments", 10)
+//
+// The depth takes precedence over CallStackDepth, which is used for the other categories, and 0 logs no call stack
+// for the category. The categories are matched as by SetCategoryFormatter, and the messages are still limited by
+// CallStackMaxLevel. The depth applies to the loggers derived from the same root logger. Setting the depth of
+// a category again replaces it, and setting it to a negative value removes it.
+// SetCategoryCallStackDepth may be called while messages are logged.
+func (l *Logger) SetCategoryCallStackDepth(category string, depth int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	depths := l.categoryDepths()
+	// copy the depths so that those being used by the log calls are not modified
+	result := make([]categoryDepth, 0, len(depths)+1)
+	for _, d := range depths {
+		if d.category != category {
+			result = append(result, d)
+		}
+	}
+	if depth >= 0 {
+		result = append(result, categoryDepth{category, depth})
+	}
+	l.depths.Store(result)
+}
+
+// categoryDepths returns the call stack depths set by SetCategoryCallStackDepth.
+func (l *coreLogger) categoryDepths() []categoryDepth {
+	depths, _ := l.depths.Load().([]categoryDepth)
+	return depths
+}
+
+// categoryCallStackDepth returns the call stack depth set by SetCategoryCallStackDepth for the given category,
+// and whether one is set.
+func (l *coreLogger) categoryCallStackDepth(category string) (int, bool) {
+	depths := l.categoryDepths()
+	i := matchCategory(len(depths), func(i int) string { return depths[i].category }, category)
+	if i < 0 {
+		return 0, false
+	}
+	return depths[i].depth, true
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "testing"
+
+func TestSetCategoryFormatter(t *testing.T) {
+	logger := NewLogger()
+	logger.Formatter = func(l *Logger, e *Entry) string {
+		return "text: " + e.Message
+	}
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.SetCategoryFormatter("audit", func(l *Logger, e *Entry) string {
+		return "audit: " + e.Message
+	})
+	logger.SetCategoryFormatter("app.*", func(l *Logger, e *Entry) string {
+		return "app: " + e.Message
+	})
+	logger.SetCategoryFormatter("app.db.*", func(l *Logger, e *Entry) string {
+		return "db: " + e.Message
+	})
+
+	logger.GetLogger("audit").Info("t1")
+	logger.GetLogger("app").Info("t2")
+	logger.GetLogger("app.http").Info("t3")
+	logger.GetLogger("app.db.users").Info("t4")
+	logger.GetLogger("audit", DefaultFormatter).Info("t5")
+	logger.SetCategoryFormatter("audit", nil)
+	logger.GetLogger("audit").Info("t6")
+	logger.Close()
+
+	expected := []string{"audit: t1", "text: t2", "app: t3", "db: t4", "audit: t5", "text: t6"}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("number of entries = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.FormattedMessage != expected[i] {
+			t.Errorf("entries[%v].FormattedMessage = %q, expected %q", i, e.FormattedMessage, expected[i])
+		}
+	}
+}
+
+func TestSetCategoryCallStackDepth(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.SetCategoryCallStackDepth("payments", 5)
+	logger.SetCategoryCallStackDepth("payments.refunds", 0)
+
+	logger.GetLogger("payments").Error("t1")
+	logger.Error("t2")
+	logger.GetLogger("payments.refunds").Error("t3")
+	logger.SetCategoryCallStackDepth("payments", -1)
+	logger.GetLogger("payments").Error("t4")
+	logger.Close()
+
+	expected := []bool{true, false, false, false}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("number of entries = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if (e.CallStack != "") != expected[i] {
+			t.Errorf("entries[%v].CallStack = %q, expected a call stack: %v", i, e.CallStack, expected[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ChannelTarget forwards filtered log entries to a channel, which allows processing them
+// in a custom pipeline without implementing the Target interface.
+//
+// The entries are sent by the goroutine dispatching the entries of the logger to all targets.
+// If Block is true (the default), sending waits until the channel has room, so a slow consumer
+// slows down all targets of the logger and, once the logger buffer is full, the logging calls.
+// If Block is false, the entries that do not fit in the channel are dropped, and CloseError
+// reports how many were dropped. The channel is closed when the logger is closed, so that the
+// consumer can range over it; a ChannelTarget therefore cannot be opened again after it is closed.
+//
+// The entries are shared with the other targets and must not be modified.
+type ChannelTarget struct {
+	*Filter
+	Name  string            // the name identifying the target in diagnostics
+	Tags  map[string]string // the tags identifying the target in diagnostics
+	Block bool              // whether to wait for room in the channel instead of dropping the entry
+
+	ch       chan<- *Entry
+	closed   bool
+	dropped  int
+	closeErr error
+	close    chan bool
+}
+
+// NewChannelTarget creates a ChannelTarget sending the log entries to the given channel.
+// The new ChannelTarget takes these default options:
+// MaxLevel: LevelDebug, Block: true
+func NewChannelTarget(ch chan<- *Entry) *ChannelTarget {
+	return &ChannelTarget{
+		Filter: &Filter{MaxLevel: LevelDebug},
+		Block:  true,
+		ch:     ch,
+		close:  make(chan bool, 0),
+	}
+}
+
+// Open prepares ChannelTarget for processing log messages.
+func (t *ChannelTarget) Open(io.Writer) error {
+	t.Filter.Init()
+	if t.ch == nil {
+		return errors.New("ChannelTarget channel cannot be nil")
+	}
+	if t.closed {
+		return fmt.Errorf("%v cannot be opened again because its channel is closed", t.label())
+	}
+	t.dropped = 0
+	return nil
+}
+
+// Process sends an allowed log entry to the channel.
+func (t *ChannelTarget) Process(e *Entry) {
+	if e == nil {
+		close(t.ch)
+		t.closed = true
+		t.closeErr = nil
+		if t.dropped > 0 {
+			t.closeErr = fmt.Errorf("%v dropped %v entries because the channel was full", t.label(), t.dropped)
+		}
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	if t.Block {
+		t.ch <- e
+		return
+	}
+	select {
+	case t.ch <- e:
+	default:
+		t.dropped++
+	}
+}
+
+// Close closes the channel target.
+func (t *ChannelTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the channel target and returns an error if entries were dropped.
+func (t *ChannelTarget) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *ChannelTarget) Describe() TargetInfo {
+	return newTargetInfo("ChannelTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *ChannelTarget) label() string {
+	return targetLabel("ChannelTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+func TestChannelTarget(t *testing.T) {
+	ch := make(chan *log.Entry)
+	logger := log.NewLogger()
+	target := log.NewChannelTarget(ch)
+	target.MaxLevel = log.LevelInfo
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	done := make(chan []string)
+	go func() {
+		var messages []string
+		for e := range ch {
+			messages = append(messages, e.Message)
+		}
+		done <- messages
+	}()
+	logger.Info("t1")
+	logger.Debug("t2")
+	logger.Error("t3")
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("logger.CloseError() = %v, expected nil", err)
+	}
+
+	if messages := strings.Join(<-done, ","); messages != "t1,t3" {
+		t.Errorf("messages = %v, expected %v", messages, "t1,t3")
+	}
+	if err := target.Open(nil); err == nil {
+		t.Errorf("target.Open() should fail after the channel is closed")
+	}
+}
+
+func TestChannelTargetDrop(t *testing.T) {
+	ch := make(chan *log.Entry, 2)
+	logger := log.NewLogger()
+	target := log.NewChannelTarget(ch)
+	target.Block = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	for i := 0; i < 5; i++ {
+		logger.Info("t%v", i)
+	}
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "ChannelTarget dropped 3 entries") {
+		t.Errorf("logger.CloseError() = %v, expected the dropped entries to be reported", err)
+	}
+	if len(ch) != 2 {
+		t.Errorf("len(ch) = %v, expected %v", len(ch), 2)
+	}
+}
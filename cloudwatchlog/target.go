@@ -0,0 +1,375 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package cloudwatchlog sends the messages logged by ozzo-log loggers to Amazon CloudWatch Logs.
+// It is a separate package so that the log package does not depend on the AWS SDK.
+package cloudwatchlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/go-ozzo/ozzo-log"
+	"github.com/go-ozzo/ozzo-log/internal/batching"
+)
+
+// the limits of a PutLogEvents request.
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+	// the number of bytes counted for every event in addition to its message
+	eventOverhead = 26
+	// the maximum size of a message, so that an event does not exceed 256KB
+	maxMessageBytes = 262144 - eventOverhead
+	// the maximum time span of the events of a request
+	maxBatchSpan = 24 * time.Hour
+)
+
+// API is the part of the CloudWatch Logs client used by Target. It is satisfied by *cloudwatchlogs.Client.
+type API interface {
+	PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error)
+	CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error)
+}
+
+// Target sends filtered log messages in batches to a log stream of CloudWatch Logs with PutLogEvents.
+// For example,
+//
+//	cfg, err := config.LoadDefaultConfig(context.TODO())
+//	...
+//	target := cloudwatchlog.NewTarget(cloudwatchlogs.NewFromConfig(cfg))
+//	target.LogGroup = "/myapp/prod"
+//	target.LogStream = hostname
+//	logger.Targets = append(logger.Targets, target)
+//
+// Every message becomes a log event timestamped with the time of its entry, so the formatter of the logger
+// should suit the queries run on the logs, e.g. log.JSONFormatter for CloudWatch Logs Insights. The messages
+// longer than the 256KB limit of an event are truncated, and the empty messages, which CloudWatch rejects,
+// are not sent.
+//
+// A batch is sent when it has BatchSize events, every FlushInterval and when the target is closed. The batches
+// are split to follow the limits of PutLogEvents: at most 10,000 events, 1MB (counting 26 bytes per event in
+// addition to its message) and 24 hours between the first and the last event, sorted by time. If the log stream
+// does not exist, it is created and the batch sent again; the log group must exist. The sequence token returned
+// by CloudWatch is passed to the next request, and the expected token reported by an InvalidSequenceTokenException
+// is used to send the batch again (CloudWatch now ignores the tokens, but the older endpoints still require them).
+// If the request is throttled or the service is unavailable, the batch is sent again after RetryInterval, doubled
+// after every attempt, up to MaxRetries times; a batch still failing after that, or failing with another error,
+// is dropped and reported to the error writer of the logger, and the number of dropped events is reported by
+// CloseError, as are the events rejected by CloudWatch for being too old or too new.
+//
+// The credentials of the client need these IAM permissions on the log group, e.g. on the resource
+// "arn:aws:logs:<region>:<account>:log-group:<LogGroup>:*": logs:PutLogEvents and logs:CreateLogStream
+// (the latter is not needed if the log stream is created beforehand).
+//
+// Entries are queued in a channel of BufferSize entries. When it is full, the logger waits for room.
+type Target struct {
+	*log.Filter
+	Name          string            // the name identifying the target in diagnostics
+	Tags          map[string]string // the tags identifying the target in diagnostics
+	Client        API               // the CloudWatch Logs client, usually a *cloudwatchlogs.Client
+	LogGroup      string            // the name of the log group receiving the events. It must exist.
+	LogStream     string            // the name of the log stream receiving the events. It is created if it does not exist.
+	BatchSize     int               // the maximum number of events sent in a request, no greater than 10,000
+	FlushInterval time.Duration     // how often a partial batch is sent
+	Timeout       time.Duration     // the time allowed for a request. 0 means no limit.
+	MaxRetries    int               // how many times a throttled batch is sent again before it is dropped
+	RetryInterval time.Duration     // the time waited before sending a throttled batch again for the first time
+	BufferSize    int               // the size of the entry channel
+
+	entries  chan *log.Entry
+	batch    []types.InputLogEvent // the events to be sent in the next requests
+	token    *string               // the sequence token of the next request
+	created  bool                  // whether the log stream was created by the target
+	drops    batching.Drops        // the events in the dropped batches and the rejected events
+	closeErr error
+	close    chan bool
+}
+
+// NewTarget creates a Target sending the messages with the given client.
+// The new Target takes these default options:
+// MaxLevel: LevelDebug, BatchSize: 10000, FlushInterval: 5s, Timeout: 10s, MaxRetries: 5, RetryInterval: 200ms,
+// BufferSize: 1024.
+// You must specify the LogGroup and LogStream fields.
+func NewTarget(client API) *Target {
+	return &Target{
+		Filter:        &log.Filter{MaxLevel: log.LevelDebug},
+		Client:        client,
+		BatchSize:     maxBatchEvents,
+		FlushInterval: 5 * time.Second,
+		Timeout:       10 * time.Second,
+		MaxRetries:    5,
+		RetryInterval: 200 * time.Millisecond,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares Target for processing log messages.
+func (t *Target) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Client == nil {
+		return errors.New("cloudwatchlog.Target.Client must be set")
+	}
+	if t.LogGroup == "" || t.LogStream == "" {
+		return errors.New("cloudwatchlog.Target.LogGroup and LogStream must be specified")
+	}
+	if t.BatchSize <= 0 || t.BatchSize > maxBatchEvents {
+		return fmt.Errorf("cloudwatchlog.Target.BatchSize must be between 1 and %v", maxBatchEvents)
+	}
+	if t.BufferSize < 0 {
+		return errors.New("cloudwatchlog.Target.BufferSize must be no less than 0")
+	}
+	if t.MaxRetries < 0 {
+		return errors.New("cloudwatchlog.Target.MaxRetries must be no less than 0")
+	}
+	t.entries = make(chan *log.Entry, t.BufferSize)
+	t.batch = nil
+	t.token = nil
+	t.created = false
+	t.drops = batching.Drops{Writer: errWriter}
+	go t.sendBatches()
+	return nil
+}
+
+// Process puts an allowed log entry into the channel of the entries to be sent.
+func (t *Target) Process(e *log.Entry) {
+	if e == nil || t.Allow(e) {
+		t.entries <- e
+	}
+}
+
+// Close closes the CloudWatch target.
+func (t *Target) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the CloudWatch target after sending the pending entries,
+// and returns an error if some events were not delivered.
+func (t *Target) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *Target) Describe() log.TargetInfo {
+	info := log.TargetInfo{
+		Type:            "cloudwatchlog.Target",
+		Name:            t.Name,
+		Enabled:         t.Enabled(),
+		MaxLevel:        t.MaxLevel,
+		MinLevel:        t.MinLevel,
+		MostSevereLevel: t.MostSevereLevel,
+		Categories:      append([]string(nil), t.Categories...),
+	}
+	if t.Tags != nil {
+		info.Tags = make(map[string]string, len(t.Tags))
+		for k, v := range t.Tags {
+			info.Tags[k] = v
+		}
+	}
+	return info
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *Target) label() string {
+	if t.Name != "" {
+		return fmt.Sprintf("cloudwatchlog.Target %q", t.Name)
+	}
+	return "cloudwatchlog.Target"
+}
+
+// sendBatches collects the entries into batches and sends them until the nil entry is received.
+func (t *Target) sendBatches() {
+	batching.Run(t.entries, t.FlushInterval, func(e *log.Entry) {
+		if event, ok := newEvent(e); ok {
+			t.batch = append(t.batch, event)
+		}
+		if len(t.batch) >= t.BatchSize {
+			t.flush()
+		}
+	}, t.flush)
+	t.closeErr = t.drops.Err(t.label(), "deliver", "events")
+	t.close <- true
+}
+
+// flush sends the pending batch.
+func (t *Target) flush() {
+	t.send(t.batch)
+	t.batch = nil
+}
+
+// newEvent converts a log entry to a log event. It returns false if the message is empty.
+func newEvent(e *log.Entry) (types.InputLogEvent, bool) {
+	msg := e.String()
+	if msg == "" {
+		return types.InputLogEvent{}, false
+	}
+	if len(msg) > maxMessageBytes {
+		// cut the message at a UTF-8 character boundary
+		n := maxMessageBytes
+		for n > 0 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n]
+	}
+	at := e.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return types.InputLogEvent{
+		Message:   aws.String(msg),
+		Timestamp: aws.Int64(at.UnixNano() / int64(time.Millisecond)),
+	}, true
+}
+
+// send sorts the events of a batch by time and sends them in requests following the limits of PutLogEvents.
+func (t *Target) send(batch []types.InputLogEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	sort.SliceStable(batch, func(i, j int) bool {
+		return *batch[i].Timestamp < *batch[j].Timestamp
+	})
+	start, size := 0, 0
+	for i, event := range batch {
+		n := len(*event.Message) + eventOverhead
+		span := time.Duration(*event.Timestamp-*batch[start].Timestamp) * time.Millisecond
+		if i > start && (size+n > maxBatchBytes || span > maxBatchSpan) {
+			t.put(batch[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	t.put(batch[start:])
+}
+
+// put sends a request until it is accepted, it fails with an error that is not retried or the retries are exhausted.
+func (t *Target) put(events []types.InputLogEvent) {
+	wait := t.RetryInterval
+	for attempt := 0; ; attempt++ {
+		err := t.putEvents(events)
+		if err == nil {
+			return
+		}
+		var notFound *types.ResourceNotFoundException
+		var invalidToken *types.InvalidSequenceTokenException
+		var accepted *types.DataAlreadyAcceptedException
+		switch {
+		case errors.As(err, &accepted):
+			// the same events were already accepted, e.g. by a request whose response was lost
+			t.token = accepted.ExpectedSequenceToken
+			return
+		case errors.As(err, &invalidToken) && attempt < t.MaxRetries:
+			t.token = invalidToken.ExpectedSequenceToken
+			continue
+		case errors.As(err, &notFound) && !t.created:
+			// the stream is created once: if the request still fails, the log group does not exist
+			t.created = true
+			if err = t.createStream(); err == nil {
+				// the token of the previous stream, if any, is not valid for the new one
+				t.token = nil
+				// the request sent to the new stream does not count as a retry. This cannot loop,
+				// as the created flag lets a single request per target go through this case.
+				attempt--
+				continue
+			}
+		case throttled(err) && attempt < t.MaxRetries:
+			time.Sleep(wait)
+			wait *= 2
+			continue
+		}
+		t.drops.Drop(t.label(), len(events), "events", err)
+		return
+	}
+}
+
+// putEvents sends a PutLogEvents request, recording the next sequence token and the events rejected by CloudWatch.
+func (t *Target) putEvents(events []types.InputLogEvent) error {
+	ctx, cancel := t.context()
+	defer cancel()
+	output, err := t.Client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(t.LogGroup),
+		LogStreamName: aws.String(t.LogStream),
+		LogEvents:     events,
+		SequenceToken: t.token,
+	})
+	if err != nil {
+		return err
+	}
+	t.token = output.NextSequenceToken
+	if n := rejected(output.RejectedLogEventsInfo, len(events)); n > 0 {
+		t.drops.Count += n
+		fmt.Fprintf(t.drops.Writer, "%v: CloudWatch rejected %v events of %v as too old, too new or expired\n", t.label(), n, len(events))
+	}
+	return nil
+}
+
+// createStream creates the log stream. It succeeds if the stream already exists.
+func (t *Target) createStream() error {
+	ctx, cancel := t.context()
+	defer cancel()
+	_, err := t.Client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(t.LogGroup),
+		LogStreamName: aws.String(t.LogStream),
+	})
+	var exists *types.ResourceAlreadyExistsException
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("unable to create the log stream: %v", err)
+	}
+	return nil
+}
+
+// context returns the context of a request, limited by Timeout.
+func (t *Target) context() (context.Context, context.CancelFunc) {
+	if t.Timeout > 0 {
+		return context.WithTimeout(context.Background(), t.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// throttled checks if a request failed because it was throttled or because the service was unavailable.
+func throttled(err error) bool {
+	var unavailable *types.ServiceUnavailableException
+	if errors.As(err, &unavailable) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "Throttling", "ServiceUnavailableException":
+			return true
+		}
+	}
+	return false
+}
+
+// rejected returns the number of events of a request rejected by CloudWatch. The events up to the end index of
+// the too old and expired events, and those from the start index of the too new events, are rejected.
+func rejected(info *types.RejectedLogEventsInfo, n int) int {
+	if info == nil {
+		return 0
+	}
+	end, start := -1, n
+	for _, index := range []*int32{info.TooOldLogEventEndIndex, info.ExpiredLogEventEndIndex} {
+		if index != nil && int(*index) > end {
+			end = int(*index)
+		}
+	}
+	if info.TooNewLogEventStartIndex != nil {
+		start = int(*info.TooNewLogEventStartIndex)
+	}
+	if end >= start {
+		return n
+	}
+	return end + 1 + n - start
+}
@@ -0,0 +1,229 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cloudwatchlog_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/go-ozzo/ozzo-log"
+	"github.com/go-ozzo/ozzo-log/cloudwatchlog"
+)
+
+// fakeAPI records the PutLogEvents requests. The errors in putErrors are returned by the first requests,
+// and the stream is deleted before the request numbered deleteAt (from 1), if it is positive.
+type fakeAPI struct {
+	mu        sync.Mutex
+	streams   map[string]bool
+	putErrors []error
+	deleteAt  int
+	calls     int
+	requests  []*cloudwatchlogs.PutLogEventsInput
+	created   int
+	token     int
+}
+
+func (a *fakeAPI) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.calls++; a.calls == a.deleteAt {
+		delete(a.streams, aws.ToString(params.LogStreamName))
+	}
+	if len(a.putErrors) > 0 {
+		err := a.putErrors[0]
+		a.putErrors = a.putErrors[1:]
+		return nil, err
+	}
+	if !a.streams[aws.ToString(params.LogStreamName)] {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The specified log stream does not exist.")}
+	}
+	a.requests = append(a.requests, params)
+	a.token++
+	return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String(string(rune('a' + a.token)))}, nil
+}
+
+func (a *fakeAPI) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.created++
+	a.streams[aws.ToString(params.LogStreamName)] = true
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func newTestLogger(api *fakeAPI, errWriter *bytes.Buffer) (*log.Logger, *cloudwatchlog.Target) {
+	target := cloudwatchlog.NewTarget(api)
+	target.LogGroup = "group"
+	target.LogStream = "stream"
+	target.RetryInterval = time.Millisecond
+	logger := log.NewLogger()
+	logger.ErrorWriter = errWriter
+	logger.Formatter = func(l *log.Logger, e *log.Entry) string {
+		return e.Message
+	}
+	logger.Targets = append(logger.Targets, target)
+	return logger, target
+}
+
+func TestTargetOpen(t *testing.T) {
+	target := cloudwatchlog.NewTarget(nil)
+	if err := target.Open(nil); err == nil || !strings.Contains(err.Error(), "Client must be set") {
+		t.Errorf("Open() = %v, expected an error about Client", err)
+	}
+	target = cloudwatchlog.NewTarget(&fakeAPI{})
+	if err := target.Open(nil); err == nil || !strings.Contains(err.Error(), "LogGroup and LogStream") {
+		t.Errorf("Open() = %v, expected an error about LogGroup and LogStream", err)
+	}
+	target.LogGroup, target.LogStream, target.BatchSize = "group", "stream", 10001
+	if err := target.Open(nil); err == nil || !strings.Contains(err.Error(), "BatchSize") {
+		t.Errorf("Open() = %v, expected an error about BatchSize", err)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	var buf bytes.Buffer
+	api := &fakeAPI{streams: map[string]bool{}}
+	logger, target := newTestLogger(api, &buf)
+	target.BatchSize = 2
+	target.MaxLevel = log.LevelInfo
+	logger.Open()
+	logger.Info("t1")
+	logger.Debug("t2")
+	logger.Info("")
+	logger.Error("t3")
+	logger.Info("t4")
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("CloseError() = %v, expected no error", err)
+	}
+
+	if api.created != 1 {
+		t.Errorf("created streams = %v, expected 1", api.created)
+	}
+	if len(api.requests) != 2 {
+		t.Fatalf("requests = %v, expected 2", len(api.requests))
+	}
+	var messages []string
+	for _, r := range api.requests {
+		if aws.ToString(r.LogGroupName) != "group" || aws.ToString(r.LogStreamName) != "stream" {
+			t.Errorf("request to %v/%v, expected group/stream", aws.ToString(r.LogGroupName), aws.ToString(r.LogStreamName))
+		}
+		for _, event := range r.LogEvents {
+			messages = append(messages, aws.ToString(event.Message))
+			if event.Timestamp == nil || *event.Timestamp <= 0 {
+				t.Errorf("event %q has no timestamp", aws.ToString(event.Message))
+			}
+		}
+	}
+	if strings.Join(messages, ",") != "t1,t3,t4" {
+		t.Errorf("messages = %v, expected [t1 t3 t4]", messages)
+	}
+	if api.requests[0].SequenceToken != nil || aws.ToString(api.requests[1].SequenceToken) != "b" {
+		t.Errorf("sequence tokens = %v, %v, expected none then b", api.requests[0].SequenceToken, aws.ToString(api.requests[1].SequenceToken))
+	}
+	if buf.Len() != 0 {
+		t.Errorf("ErrorWriter = %q, expected nothing", buf.String())
+	}
+}
+
+func TestTargetStreamDeleted(t *testing.T) {
+	var buf bytes.Buffer
+	api := &fakeAPI{streams: map[string]bool{"stream": true}, deleteAt: 2}
+	logger, target := newTestLogger(api, &buf)
+	target.BatchSize = 1
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("CloseError() = %v, expected no error", err)
+	}
+	if api.created != 1 || len(api.requests) != 2 {
+		t.Fatalf("created streams, requests = %v, %v, expected 1, 2", api.created, len(api.requests))
+	}
+	if token := api.requests[1].SequenceToken; token != nil {
+		t.Errorf("the request to the new stream has the token %q of the deleted one, expected none", *token)
+	}
+}
+
+func TestTargetLimits(t *testing.T) {
+	var buf bytes.Buffer
+	api := &fakeAPI{streams: map[string]bool{"stream": true}}
+	logger, _ := newTestLogger(api, &buf)
+	logger.Open()
+	// 5 messages of 300KB are truncated to 256KB events, so that 4 fit in the 1MB of a request
+	big := strings.Repeat("é", 150000)
+	for i := 0; i < 5; i++ {
+		logger.Info(big)
+	}
+	logger.Close()
+
+	if len(api.requests) != 2 || len(api.requests[0].LogEvents) != 4 || len(api.requests[1].LogEvents) != 1 {
+		t.Fatalf("requests = %v, expected 2 of 4 and 1 events", len(api.requests))
+	}
+	msg := aws.ToString(api.requests[0].LogEvents[0].Message)
+	if len(msg) > 262144-26 || !strings.HasPrefix(big, msg) || len(msg)%2 != 0 {
+		t.Errorf("truncated message has %v bytes, expected at most 262118 bytes cut at a character boundary", len(msg))
+	}
+}
+
+func TestTargetRetry(t *testing.T) {
+	var buf bytes.Buffer
+	api := &fakeAPI{
+		streams: map[string]bool{"stream": true},
+		putErrors: []error{
+			&smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"},
+			&types.InvalidSequenceTokenException{ExpectedSequenceToken: aws.String("expected")},
+			&types.ServiceUnavailableException{},
+		},
+	}
+	logger, _ := newTestLogger(api, &buf)
+	logger.Open()
+	logger.Info("t1")
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("CloseError() = %v, expected no error", err)
+	}
+	if len(api.requests) != 1 || aws.ToString(api.requests[0].SequenceToken) != "expected" {
+		t.Errorf("requests = %v, expected one with the expected sequence token", len(api.requests))
+	}
+}
+
+func TestTargetDrop(t *testing.T) {
+	var buf bytes.Buffer
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	api := &fakeAPI{
+		streams:   map[string]bool{"stream": true},
+		putErrors: []error{throttled, throttled, throttled},
+	}
+	logger, target := newTestLogger(api, &buf)
+	target.MaxRetries = 2
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "unable to deliver 2 events") {
+		t.Errorf("CloseError() = %v, expected 2 undelivered events", err)
+	}
+	if len(api.requests) != 0 {
+		t.Errorf("requests = %v, expected none", len(api.requests))
+	}
+	if !strings.Contains(buf.String(), "dropped a batch of 2 events") {
+		t.Errorf("ErrorWriter = %q, expected the dropped batch to be reported", buf.String())
+	}
+}
+
+func TestTargetDescribe(t *testing.T) {
+	target := cloudwatchlog.NewTarget(&fakeAPI{})
+	target.Name = "cw"
+	info := target.Describe()
+	if info.Type != "cloudwatchlog.Target" || info.Name != "cw" || info.MaxLevel != log.LevelDebug {
+		t.Errorf("Describe() = %+v", info)
+	}
+}
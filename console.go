@@ -5,11 +5,15 @@
 package log
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 type consoleBrush func(string) string
@@ -32,22 +36,63 @@ var brushes = map[Level]consoleBrush{
 }
 
 // ConsoleTarget writes filtered log messages to console window.
+//
+// By default, every message is written to Writer immediately, which is what interactive use needs
+// (e.g. so that messages appear before a prompt). When the output is redirected to a file or a pipe,
+// writing every message individually is slow; setting BufferSize enables buffering, and the buffered
+// messages are flushed when the buffer is full, every FlushInterval, and when the target is closed.
+//
+// When Writer is a terminal, the messages whose TransientKey param or field is true, e.g. progress updates,
+// rewrite the current line instead of adding new ones, and the next message that is not transient starts
+// on a new line, leaving the last transient message visible. This is TTY-only: when the output is redirected
+// to a file or a pipe, the transient messages are written as ordinary ones.
 type ConsoleTarget struct {
 	*Filter
-	ColorMode bool      // whether to use colors to differentiate log levels
-	Writer    io.Writer // the writer to write log messages
-	close     chan bool
+	Name      string            // the name identifying the target in diagnostics
+	Tags      map[string]string // the tags identifying the target in diagnostics
+	ColorMode bool              // whether to use colors to differentiate log levels
+	Writer    io.Writer         // the writer to write log messages
+	// whether to append a newline to every message. Disable it if the writer adds its own record terminator.
+	TrailingNewline bool
+	// the size of the output buffer in bytes. 0 disables buffering.
+	BufferSize int
+	// how often the buffered messages are flushed when BufferSize is positive. 0 disables the periodic flush.
+	FlushInterval time.Duration
+	// the param or field marking the transient messages. Empty disables rewriting the current line.
+	TransientKey string
+	// the prefix of the continuation lines of multi-line messages, such as call stacks, e.g. "    ",
+	// which groups them visually under the first line. Empty, the default, writes the messages as is.
+	Indent string
+	// whether to remove the ANSI escape sequences, e.g. colors, already in the messages when Writer is not
+	// a terminal, such as those of the output forwarded from a colorized subprocess. The colors of ColorMode
+	// are added afterwards, so disable ColorMode as well for a plain output.
+	StripANSI bool
+
+	terminal bool // whether the transient messages rewrite the current line
+	strip    bool // whether the ANSI escape sequences are removed from the messages
+	pending  bool // whether the current line holds a transient message to be terminated
+	buf      *bufio.Writer
+	lock     sync.Mutex
+	flushErr error
+	failures writeFailures
+	closeErr error
+	stop     chan bool
+	close    chan bool
 }
 
 // NewConsoleTarget creates a ConsoleTarget.
 // The new ConsoleTarget takes these default options:
-// MaxLevel: LevelDebug, ColorMode: true, Writer: os.Stdout
+// MaxLevel: LevelDebug, ColorMode: true, Writer: os.Stdout, TrailingNewline: true, BufferSize: 0, FlushInterval: 1s,
+// TransientKey: Transient
 func NewConsoleTarget() *ConsoleTarget {
 	return &ConsoleTarget{
-		Filter:    &Filter{MaxLevel: LevelDebug},
-		ColorMode: true,
-		Writer:    os.Stdout,
-		close:     make(chan bool, 0),
+		Filter:          &Filter{MaxLevel: LevelDebug},
+		ColorMode:       true,
+		Writer:          os.Stdout,
+		TrailingNewline: true,
+		FlushInterval:   time.Second,
+		TransientKey:    Transient,
+		close:           make(chan bool, 0),
 	}
 }
 
@@ -57,15 +102,65 @@ func (t *ConsoleTarget) Open(io.Writer) error {
 	if t.Writer == nil {
 		return errors.New("ConsoleTarget.Writer cannot be nil")
 	}
+	if t.BufferSize < 0 {
+		return errors.New("ConsoleTarget.BufferSize must be no less than 0")
+	}
 	if runtime.GOOS == "windows" {
 		t.ColorMode = false
 	}
+	t.terminal = t.TransientKey != "" && isTerminal(t.Writer)
+	t.strip = t.StripANSI && !isTerminal(t.Writer)
+	t.pending = false
+	t.buf = nil
+	t.flushErr = nil
+	t.failures = writeFailures{}
+	if t.BufferSize > 0 {
+		t.buf = bufio.NewWriterSize(t.Writer, t.BufferSize)
+		if t.FlushInterval > 0 {
+			t.stop = make(chan bool, 0)
+			go t.flushPeriodically(t.stop)
+		}
+	}
 	return nil
 }
 
+// flushPeriodically flushes the buffered messages every FlushInterval until stop is closed.
+func (t *ConsoleTarget) flushPeriodically(stop chan bool) {
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.lock.Lock()
+			t.flush()
+			t.lock.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
 // Process writes a log message using Writer.
 func (t *ConsoleTarget) Process(e *Entry) {
 	if e == nil {
+		if t.pending {
+			t.write("\n")
+			t.pending = false
+		}
+		if t.buf != nil {
+			if t.stop != nil {
+				close(t.stop)
+				t.stop = nil
+			}
+			t.lock.Lock()
+			t.flush()
+			t.lock.Unlock()
+		}
+		var flushErr error
+		if t.flushErr != nil {
+			flushErr = fmt.Errorf("%v was unable to flush the buffered messages: %v", t.label(), t.flushErr)
+		}
+		t.closeErr = joinErrors(t.failures.err(t.label()), flushErr)
 		t.close <- true
 		return
 	}
@@ -73,16 +168,81 @@ func (t *ConsoleTarget) Process(e *Entry) {
 		return
 	}
 	msg := e.String()
+	if t.strip {
+		msg = stripANSI(msg)
+	}
+	if t.Indent != "" {
+		msg = strings.Replace(msg, "\n", "\n"+t.Indent, -1)
+	}
 	if t.ColorMode {
 		brush, ok := brushes[e.Level]
 		if ok {
 			msg = brush(msg)
 		}
 	}
-	fmt.Fprintln(t.Writer, msg)
+	if t.terminal && isTransient(e, t.TransientKey) {
+		// clear the rest of the line in case the previous message was longer
+		t.write("\r" + msg + "\033[K")
+		t.pending = true
+		return
+	}
+	if t.pending {
+		msg = "\n" + msg
+		t.pending = false
+	}
+	if t.TrailingNewline {
+		msg += "\n"
+	}
+	t.write(msg)
+}
+
+// write writes a message to Writer, or to the buffer when buffering is enabled.
+func (t *ConsoleTarget) write(msg string) {
+	if t.buf == nil {
+		if _, err := io.WriteString(t.Writer, msg); err != nil {
+			t.failures.add(err)
+		} else {
+			t.failures.ok()
+		}
+		return
+	}
+	t.lock.Lock()
+	t.buf.WriteString(msg)
+	t.lock.Unlock()
+}
+
+// flush writes the buffered messages to Writer and keeps the first error occurred.
+// The caller must hold the lock.
+func (t *ConsoleTarget) flush() {
+	if err := t.buf.Flush(); err != nil && t.flushErr == nil {
+		t.flushErr = err
+	}
 }
 
 // Close closes the console target.
 func (t *ConsoleTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the console target and returns the errors occurred when writing
+// or flushing messages to Writer.
+func (t *ConsoleTarget) CloseError() error {
 	<-t.close
+	return t.closeErr
+}
+
+// FailureStreak returns the number of consecutive messages that could not be written to Writer.
+// The failures of the buffered writes are only detected when the buffer is flushed on close.
+func (t *ConsoleTarget) FailureStreak() int {
+	return t.failures.failureStreak()
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *ConsoleTarget) Describe() TargetInfo {
+	return newTargetInfo("ConsoleTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *ConsoleTarget) label() string {
+	return targetLabel("ConsoleTarget", t.Name, t.Tags)
 }
@@ -5,6 +5,7 @@
 package log_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -70,3 +71,137 @@ func TestConsoleTarget(t *testing.T) {
 		t.Errorf("Expected %q not found", "t2: 3")
 	}
 }
+
+func TestConsoleTargetBuffered(t *testing.T) {
+	logger := log.NewLogger()
+	target := &ConsoleTargetMock{
+		done:          make(chan bool, 0),
+		ConsoleTarget: log.NewConsoleTarget(),
+	}
+	writer := &MemoryWriter{}
+	target.Writer = writer
+	target.ColorMode = false
+	target.BufferSize = 4096
+	target.FlushInterval = 0
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Info("t1: %v", 2)
+	logger.Info("t2: %v", 3)
+
+	logger.Close()
+	<-target.done
+
+	if !strings.Contains(string(writer.bytes), "t1: 2") || !strings.Contains(string(writer.bytes), "t2: 3") {
+		t.Errorf("buffered messages were not flushed on close: %q", string(writer.bytes))
+	}
+}
+
+func TestConsoleTargetCustomLevel(t *testing.T) {
+	level, err := log.RegisterLevel(-2, "Audit")
+	if err != nil {
+		t.Fatalf("RegisterLevel(-2) returned error: %v", err)
+	}
+	defer delete(log.LevelNames, level)
+	logger := log.NewLogger()
+	target := &ConsoleTargetMock{
+		done:          make(chan bool, 0),
+		ConsoleTarget: log.NewConsoleTarget(),
+	}
+	writer := &MemoryWriter{}
+	target.Writer = writer
+	target.ColorMode = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Log(level, "t1")
+
+	logger.Close()
+	<-target.done
+
+	if !strings.Contains(string(writer.bytes), "[Audit][app] t1") {
+		t.Errorf("the message of a negative custom level was dropped: %q", string(writer.bytes))
+	}
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestConsoleTargetCloseError(t *testing.T) {
+	logger := log.NewLogger()
+	target := log.NewConsoleTarget()
+	target.Writer = failingWriter{}
+	target.ColorMode = false
+	target.BufferSize = 4096
+	target.FlushInterval = 0
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Info("t1")
+
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "ConsoleTarget was unable to flush the buffered messages: disk full") {
+		t.Errorf("logger.CloseError() = %v, expected the flush error to be reported", err)
+	}
+}
+
+func TestConsoleTargetTrailingNewline(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Formatter = func(l *log.Logger, e *log.Entry) string {
+		return e.Message
+	}
+	target := &ConsoleTargetMock{
+		done:          make(chan bool, 0),
+		ConsoleTarget: log.NewConsoleTarget(),
+	}
+	writer := &MemoryWriter{}
+	target.Writer = writer
+	target.ColorMode = false
+	target.TrailingNewline = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Info("t1")
+	logger.Info("t2")
+
+	logger.Close()
+	<-target.done
+
+	if string(writer.bytes) != "t1t2" {
+		t.Errorf("writer.bytes = %q, expected %q", string(writer.bytes), "t1t2")
+	}
+}
+
+func TestConsoleTargetIndent(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Formatter = func(l *log.Logger, e *log.Entry) string {
+		return e.Message
+	}
+	target := &ConsoleTargetMock{
+		done:          make(chan bool, 0),
+		ConsoleTarget: log.NewConsoleTarget(),
+	}
+	if target.Indent != "" {
+		t.Errorf("NewConsoleTarget.Indent = %q, expected it to be empty", target.Indent)
+	}
+	writer := &MemoryWriter{}
+	target.Writer = writer
+	target.ColorMode = false
+	target.Indent = "    "
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Error("failed\nmain.go:10")
+	logger.Info("done")
+
+	logger.Close()
+	<-target.done
+
+	expected := "failed\n    main.go:10\ndone\n"
+	if string(writer.bytes) != expected {
+		t.Errorf("writer.bytes = %q, expected %q", string(writer.bytes), expected)
+	}
+}
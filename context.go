@@ -0,0 +1,56 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContextKey names the field of a context value extracted by Logger.WithContext.
+type ContextKey struct {
+	Key  interface{} // the key of the value in the context
+	Name string      // the name of the field
+}
+
+// WithContextKeys returns a logger extracting the values of the given keys from the contexts passed to WithContext,
+// which is useful to propagate request-scoped values, such as request IDs, without adding them in every call.
+// A key is either a ContextKey, or any other context key, in which case the field is named by fmt.Sprint(key).
+// For example,
+//
+//	logger = logger.WithContextKeys(requestIDKey, log.ContextKey{Key: userKey{}, Name: "user"})
+//	...
+//	logger.WithContext(ctx).Info("request handled")
+//
+// The keys are added to those of the logger.
+func (l *Logger) WithContextKeys(keys ...interface{}) *Logger {
+	ret := l.Dup()
+	ret.contextKeys = append(l.contextKeys[:len(l.contextKeys):len(l.contextKeys)], keys...)
+	return ret
+}
+
+// WithContext returns a logger with the values of the context keys (see WithContextKeys) added as fields,
+// like WithFields. Keys missing from the context are skipped. If no value is found, the logger itself is returned.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	var fields Fields
+	for _, key := range l.contextKeys {
+		name := ""
+		if k, ok := key.(ContextKey); ok {
+			key, name = k.Key, k.Name
+		} else {
+			name = fmt.Sprint(key)
+		}
+		if value := ctx.Value(key); value != nil {
+			if fields == nil {
+				fields = make(Fields, len(l.contextKeys))
+			}
+			fields[name] = value
+		}
+	}
+	if fields == nil {
+		return l
+	}
+	return l.WithFields(fields)
+}
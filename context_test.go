@@ -0,0 +1,40 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type testContextKey string
+
+type testUserKey struct{}
+
+func TestLoggerWithContext(t *testing.T) {
+	logger := NewLogger()
+	ctx := context.WithValue(context.Background(), testContextKey("request_id"), "r1")
+	ctx = context.WithValue(ctx, testUserKey{}, "bob")
+
+	if l := logger.WithContext(ctx); l != logger {
+		t.Errorf("WithContext() without context keys should return the logger itself")
+	}
+	l1 := logger.WithContextKeys(testContextKey("request_id"), testContextKey("missing"))
+	l2 := l1.WithGroup("http").WithContextKeys(ContextKey{Key: testUserKey{}, Name: "user"})
+
+	if fields := fmt.Sprint(l1.WithContext(ctx).Fields); fields != "map[request_id:r1]" {
+		t.Errorf("l1.WithContext().Fields = %v, expected %v", fields, "map[request_id:r1]")
+	}
+	if fields := fmt.Sprint(l2.WithContext(ctx).Fields); fields != "map[http:map[request_id:r1 user:bob]]" {
+		t.Errorf("l2.WithContext().Fields = %v, expected %v", fields, "map[http:map[request_id:r1 user:bob]]")
+	}
+	if l := l2.WithContext(context.Background()); l != l2 {
+		t.Errorf("WithContext() without values should return the logger itself")
+	}
+	if logger.Fields != nil || l1.Fields != nil {
+		t.Errorf("WithContext() should not modify the original loggers")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCorrelatedErrors is the maximum number of errors remembered by an ErrorCorrelator.
+const maxCorrelatedErrors = 1000
+
+// ErrorCorrelator correlates the entries following an error with that error. Every entry of LevelError or above
+// gets a short random ID as the "error_id" field, unless it has one, and the less severe entries logged within
+// Window after it by the same goroutine with the same category get that ID as the "caused_by" field, e.g.
+//
+//	2016-01-02T03:04:05Z [Error][app.db] query failed error_id=5f2a9c1e
+//	2016-01-02T03:04:05Z [Warning][app.db] retrying the query caused_by=5f2a9c1e
+//
+// Only the most recent error of a goroutine and category is referred to, and the entries having a "caused_by"
+// field keep it. The entries logged by other goroutines are not correlated, even if they handle the error, nor are
+// the entries logged before the logger is opened, which are sent by Open. The goroutines are identified as
+// by Logger.IncludeGoroutineID, which takes about a microsecond per entry, and Go reuses the IDs of the goroutines
+// that have exited, so an entry may refer to an error of an earlier goroutine within Window.
+//
+// At most 1000 errors are remembered, one per goroutine and category: when a new one would exceed it,
+// the errors whose Window has expired are forgotten, or an arbitrary error if there is none.
+// An ErrorCorrelator is safe for concurrent use.
+type ErrorCorrelator struct {
+	Window time.Duration // how long after an error the entries refer to it. It must be positive.
+
+	lock   sync.Mutex
+	errors map[correlationKey]*correlatedError
+}
+
+// correlationKey identifies the entries that may refer to the same error.
+type correlationKey struct {
+	goroutine uint64
+	category  string
+}
+
+// correlatedError is the last error of a correlationKey.
+type correlatedError struct {
+	id interface{} // the "error_id" field of the error
+	at time.Time   // the time at which the error was logged
+}
+
+// NewErrorCorrelator creates an ErrorCorrelator correlating the entries logged within window after an error with it.
+func NewErrorCorrelator(window time.Duration) *ErrorCorrelator {
+	return &ErrorCorrelator{
+		Window: window,
+	}
+}
+
+// correlate adds the "error_id" field to an error entry, or the "caused_by" field to a less severe entry
+// following an error. The fields of the entry must not be shared.
+func (c *ErrorCorrelator) correlate(e *Entry, goroutine uint64, now time.Time) {
+	key := correlationKey{goroutine, e.Category}
+	if e.Level <= LevelError {
+		if e.Fields == nil {
+			e.Fields = make(Fields, 1)
+		}
+		id, ok := e.Fields["error_id"]
+		if !ok {
+			id = newErrorID()
+			e.Fields["error_id"] = id
+		}
+		c.remember(key, id, now)
+		return
+	}
+	if _, ok := e.Fields["caused_by"]; ok {
+		return
+	}
+	if id, ok := c.recall(key, now); ok {
+		if e.Fields == nil {
+			e.Fields = make(Fields, 1)
+		}
+		e.Fields["caused_by"] = id
+	}
+}
+
+// remember records the ID of the error of a key logged at the given time.
+func (c *ErrorCorrelator) remember(key correlationKey, id interface{}, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.errors == nil {
+		c.errors = make(map[correlationKey]*correlatedError)
+	}
+	if err, ok := c.errors[key]; ok {
+		err.id, err.at = id, now
+		return
+	}
+	if len(c.errors) >= maxCorrelatedErrors {
+		c.evict(now)
+	}
+	c.errors[key] = &correlatedError{id: id, at: now}
+}
+
+// recall returns the ID of the error of a key logged within Window before the given time.
+func (c *ErrorCorrelator) recall(key correlationKey, now time.Time) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	err, ok := c.errors[key]
+	if !ok {
+		return nil, false
+	}
+	if now.Sub(err.at) >= c.Window {
+		delete(c.errors, key)
+		return nil, false
+	}
+	return err.id, true
+}
+
+// evict forgets the errors whose Window has expired, or an arbitrary error if there is none.
+// The caller must hold the lock.
+func (c *ErrorCorrelator) evict(now time.Time) {
+	for key, err := range c.errors {
+		if now.Sub(err.at) >= c.Window {
+			delete(c.errors, key)
+		}
+	}
+	if len(c.errors) < maxCorrelatedErrors {
+		return
+	}
+	for key := range c.errors {
+		delete(c.errors, key)
+		break
+	}
+}
+
+// errorIDs counts the error IDs generated when the random source fails.
+var errorIDs uint32
+
+// newErrorID returns a short random ID for an error entry, e.g. "5f2a9c1e".
+func newErrorID() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		n := atomic.AddUint32(&errorIDs, 1)
+		b = [4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return hex.EncodeToString(b[:])
+}
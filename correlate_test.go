@@ -0,0 +1,82 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorCorrelator(t *testing.T) {
+	logger := NewLogger()
+	logger.ErrorCorrelation = NewErrorCorrelator(time.Hour)
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	db := logger.GetLogger("db")
+	logger.Info("t1")
+	db.Error("t2")
+	db.Warning("t3")
+	logger.Info("t4")
+	db.WithField("caused_by", "other").Info("t5")
+	done := make(chan bool)
+	go func() {
+		db.Info("t6")
+		done <- true
+	}()
+	<-done
+	db.WithField("error_id", "custom").Critical("t7")
+	db.Debug("t8")
+	logger.Close()
+
+	if len(target.entries) != 8 {
+		t.Fatalf("number of entries = %v, expected 8", len(target.entries))
+	}
+	id := target.entries[1].Fields["error_id"]
+	if s, ok := id.(string); !ok || len(s) != 8 {
+		t.Fatalf("error_id = %v, expected an ID of 8 characters", id)
+	}
+	expected := []interface{}{nil, nil, id, nil, "other", nil, nil, "custom"}
+	for i, e := range target.entries {
+		if causedBy := e.Fields["caused_by"]; causedBy != expected[i] {
+			t.Errorf("entries[%v] caused_by = %v, expected %v", i, causedBy, expected[i])
+		}
+	}
+	if _, ok := target.entries[0].Fields["error_id"]; ok {
+		t.Errorf("entries[0] has an error_id, expected none for an Info entry")
+	}
+}
+
+func TestErrorCorrelatorWindow(t *testing.T) {
+	c := NewErrorCorrelator(time.Second)
+	now := time.Now()
+	c.correlate(&Entry{Level: LevelError, Category: "app"}, 1, now)
+	tests := []struct {
+		goroutine uint64
+		category  string
+		offset    time.Duration
+		expected  bool
+	}{
+		{1, "app", 500 * time.Millisecond, true},
+		{2, "app", 500 * time.Millisecond, false},
+		{1, "db", 500 * time.Millisecond, false},
+		{1, "app", time.Second, false},
+		{1, "app", 500 * time.Millisecond, false}, // the expired error is forgotten
+	}
+	for i, test := range tests {
+		e := &Entry{Level: LevelInfo, Category: test.category}
+		c.correlate(e, test.goroutine, now.Add(test.offset))
+		if _, ok := e.Fields["caused_by"]; ok != test.expected {
+			t.Errorf("%v: caused_by set = %v, expected %v", i, ok, test.expected)
+		}
+	}
+
+	for i := 0; i < maxCorrelatedErrors+10; i++ {
+		c.correlate(&Entry{Level: LevelError, Category: "app"}, uint64(i), now)
+	}
+	if len(c.errors) != maxCorrelatedErrors {
+		t.Errorf("number of errors = %v, expected %v", len(c.errors), maxCorrelatedErrors)
+	}
+}
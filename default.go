@@ -0,0 +1,82 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "sync"
+
+var (
+	defaultLock   sync.Mutex
+	defaultLogger *Logger
+)
+
+// Default returns the default logger used by the package-level log functions, such as Info and Error.
+// Unless set by SetDefault, it is created and opened on first use, and writes messages of all levels
+// to a ConsoleTarget. Like any other logger, it should be closed before the application exits so that
+// the pending messages are written, e.g. with "defer log.Default().Close()".
+func Default() *Logger {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	if defaultLogger == nil {
+		logger := NewLogger()
+		logger.Targets = append(logger.Targets, NewConsoleTarget())
+		logger.Open()
+		defaultLogger = logger
+	}
+	return defaultLogger
+}
+
+// SetDefault sets the default logger used by the package-level log functions. The logger should be open.
+// The previous default logger is not closed. If the logger is nil, a new default logger is created on next use.
+func SetDefault(l *Logger) {
+	defaultLock.Lock()
+	defaultLogger = l
+	defaultLock.Unlock()
+}
+
+// Emergency logs a message indicating the system is unusable using the default logger.
+func Emergency(format string, a ...interface{}) {
+	Default().log(3, LevelEmergency, format, a...)
+}
+
+// Alert logs a message indicating action must be taken immediately using the default logger.
+func Alert(format string, a ...interface{}) {
+	Default().log(3, LevelAlert, format, a...)
+}
+
+// Critical logs a message indicating critical conditions using the default logger.
+func Critical(format string, a ...interface{}) {
+	Default().log(3, LevelCritical, format, a...)
+}
+
+// Error logs a message indicating an error condition using the default logger.
+// Please refer to Logger.Error() for how to use this function.
+func Error(format string, a ...interface{}) {
+	Default().log(3, LevelError, format, a...)
+}
+
+// Warning logs a message indicating a warning condition using the default logger.
+func Warning(format string, a ...interface{}) {
+	Default().log(3, LevelWarning, format, a...)
+}
+
+// Notice logs a message meaning normal but significant condition using the default logger.
+func Notice(format string, a ...interface{}) {
+	Default().log(3, LevelNotice, format, a...)
+}
+
+// Info logs a message for informational purpose using the default logger.
+func Info(format string, a ...interface{}) {
+	Default().log(3, LevelInfo, format, a...)
+}
+
+// Debug logs a message for debugging purpose using the default logger.
+func Debug(format string, a ...interface{}) {
+	Default().log(3, LevelDebug, format, a...)
+}
+
+// Log logs a message of a specified severity level using the default logger.
+func Log(level Level, format string, a ...interface{}) {
+	Default().log(3, level, format, a...)
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	SetDefault(logger)
+	defer SetDefault(nil)
+	if Default() != logger {
+		t.Fatalf("Default() did not return the logger set by SetDefault()")
+	}
+
+	Emergency("t%v", 0)
+	Alert("t1")
+	Critical("t2")
+	Error("t3")
+	Warning("t4")
+	Notice("t5")
+	Info("t6")
+	Debug("t7")
+	Log(LevelInfo, "t8")
+	logger.Close()
+
+	if len(target.entries) != 9 {
+		t.Fatalf("got %v entries, expected 9", len(target.entries))
+	}
+	for i, e := range target.entries {
+		if i < 8 && e.Level != Level(i) {
+			t.Errorf("entry %v level = %v, expected %v", i, e.Level, Level(i))
+		}
+		if !strings.Contains(e.CallStack, "default_test.go") {
+			t.Errorf("entry %v call stack = %q, expected the caller of the log function", i, e.CallStack)
+		}
+	}
+
+	SetDefault(nil)
+	l := Default()
+	defer l.Close()
+	if l == logger || len(l.Targets) != 1 {
+		t.Errorf("Default() after SetDefault(nil) should create a logger with a console target")
+	}
+	if _, ok := l.Targets[0].(*ConsoleTarget); !ok {
+		t.Errorf("Default().Targets[0] = %T, expected *ConsoleTarget", l.Targets[0])
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// TargetInfo describes a target and its filter settings, e.g. for an admin or status endpoint.
+type TargetInfo struct {
+	Type            string            // the type of the target, e.g. "FileTarget"
+	Name            string            // the name identifying the target in diagnostics
+	Tags            map[string]string // the tags identifying the target in diagnostics
+	Enabled         bool              // whether the filter of the target is enabled (see Filter.SetEnabled)
+	MaxLevel        Level             // the maximum severity level allowed by the filter of the target
+	MinLevel        Level             // the least severe level allowed by the filter of the target. 0 means no bound.
+	MostSevereLevel Level             // the most severe level allowed by the filter of the target. 0 means no bound.
+	Categories      []string          // the categories allowed by the filter of the target. Empty means all.
+	Wrapped         *TargetInfo       // the description of the target wrapped by this one, such as that of an AsyncTarget
+}
+
+// Describer is implemented by targets describing themselves with a TargetInfo. All built-in targets implement it.
+type Describer interface {
+	Describe() TargetInfo
+}
+
+// DescribeTargets returns the descriptions of the targets of the logger (the method cannot be named Targets,
+// which is the name of the field holding them). The targets not implementing Describer are described by their
+// Go type only. DescribeTargets should not be called while Targets is being modified.
+func (l *coreLogger) DescribeTargets() []TargetInfo {
+	result := make([]TargetInfo, len(l.Targets))
+	for i, target := range l.Targets {
+		result[i] = describeTarget(target)
+	}
+	return result
+}
+
+// describeTarget describes a target using its Describe method if it implements Describer, or its Go type otherwise.
+func describeTarget(target Target) TargetInfo {
+	if d, ok := target.(Describer); ok {
+		return d.Describe()
+	}
+	return TargetInfo{Type: fmt.Sprintf("%T", target), Enabled: true}
+}
+
+// newTargetInfo describes a target identified by the given type, name and tags using its filter.
+// The tags and the categories are copied so that modifying the description does not change the target.
+func newTargetInfo(kind, name string, tags map[string]string, filter *Filter) TargetInfo {
+	info := TargetInfo{Type: kind, Name: name, Enabled: true}
+	if tags != nil {
+		info.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			info.Tags[k] = v
+		}
+	}
+	if filter != nil {
+		info.Enabled = filter.Enabled()
+		info.MaxLevel = filter.MaxLevel
+		info.MinLevel = filter.MinLevel
+		info.MostSevereLevel = filter.MostSevereLevel
+		info.Categories = append([]string(nil), filter.Categories...)
+	}
+	return info
+}
@@ -0,0 +1,37 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoggerDescribeTargets(t *testing.T) {
+	file := NewFileTarget()
+	file.Name = "errors"
+	file.Tags = map[string]string{"env": "prod"}
+	file.MaxLevel = LevelError
+	file.Categories = []string{"app.*"}
+	console := NewConsoleTarget()
+	console.SetEnabled(false)
+	logger := NewLogger()
+	logger.Targets = append(logger.Targets, file, NewAsyncTarget(console), &MemoryTarget{})
+
+	expected := []TargetInfo{
+		{Type: "FileTarget", Name: "errors", Tags: map[string]string{"env": "prod"}, Enabled: true, MaxLevel: LevelError, Categories: []string{"app.*"}},
+		{Type: "AsyncTarget", Enabled: true, Wrapped: &TargetInfo{Type: "ConsoleTarget", MaxLevel: LevelDebug}},
+		{Type: "*log.MemoryTarget", Enabled: true},
+	}
+	if infos := logger.DescribeTargets(); !reflect.DeepEqual(infos, expected) {
+		t.Errorf("logger.DescribeTargets() = %+v, expected %+v", infos, expected)
+	}
+
+	infos := logger.DescribeTargets()
+	infos[0].Categories[0] = "changed"
+	if file.Categories[0] != "app.*" {
+		t.Errorf("modifying the description changed the target categories to %v", file.Categories)
+	}
+}
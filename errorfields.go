@@ -0,0 +1,72 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// ErrorFielder is implemented by the errors carrying structured details, e.g. an error code or the ID
+// of a failed operation, which Logger.WithError and Event.Err add to the entry:
+//
+//	func (e *QueryError) Fields() map[string]interface{} {
+//		return map[string]interface{}{"table": e.Table, "code": e.Code}
+//	}
+type ErrorFielder interface {
+	Fields() map[string]interface{}
+}
+
+// ErrorDetails is the name of the field grouping the details of an error added by Logger.WithError and Event.Err,
+// so that they do not collide with the other fields of the entry.
+const ErrorDetails = "error_details"
+
+// WithError returns a logger with the error added as the "error" field. The details of the errors implementing
+// ErrorFielder in its chain (see errors.Unwrap, including the errors joined by errors.Join) are merged into
+// the ErrorDetails group field; when several errors have a detail of the same name, the outermost one wins.
+// Nil and empty details are skipped, and the group field is only added if there is a detail.
+// If the error is nil, the logger itself is returned.
+func (l *Logger) WithError(err error) *Logger {
+	if err == nil {
+		return l
+	}
+	fields := Fields{"error": err}
+	if details := errorDetails(err); len(details) > 0 {
+		fields[ErrorDetails] = details
+	}
+	return l.WithFields(fields)
+}
+
+// errorDetails merges the details of the errors implementing ErrorFielder in the chain of err, visiting
+// the outer errors first so that their details take precedence. It returns nil if there is no detail.
+func errorDetails(err error) Fields {
+	var details Fields
+	// the errors still to visit, the next one last
+	stack := []error{err}
+	// at most 100 errors are visited, in case an error wraps itself
+	for n := 0; len(stack) > 0 && n < 100; n++ {
+		err := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err == nil {
+			continue
+		}
+		if f, ok := err.(ErrorFielder); ok {
+			for name, value := range f.Fields() {
+				if _, ok := details[name]; ok {
+					continue
+				}
+				if details == nil {
+					details = make(Fields)
+				}
+				details[name] = value
+			}
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			stack = append(stack, e.Unwrap())
+		case interface{ Unwrap() []error }:
+			errs := e.Unwrap()
+			for i := len(errs) - 1; i >= 0; i-- {
+				stack = append(stack, errs[i])
+			}
+		}
+	}
+	return details
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type queryError struct {
+	table string
+	code  int
+}
+
+func (e *queryError) Error() string {
+	return "query failed"
+}
+
+func (e *queryError) Fields() map[string]interface{} {
+	if e.table == "" {
+		return nil
+	}
+	return map[string]interface{}{"table": e.table, "code": e.code}
+}
+
+func TestLoggerWithError(t *testing.T) {
+	logger := NewLogger()
+	if logger.WithError(nil) != logger {
+		t.Errorf("WithError(nil) returned a new logger, expected the logger itself")
+	}
+
+	inner := &queryError{table: "users", code: 42}
+	outer := &queryError{table: "orders"}
+	tests := []struct {
+		err      error
+		expected Fields
+	}{
+		{errors.New("plain"), nil},
+		{&queryError{}, nil},
+		{inner, Fields{"table": "users", "code": 42}},
+		{fmt.Errorf("saving: %w", inner), Fields{"table": "users", "code": 42}},
+		{fmt.Errorf("retrying: %w", errors.Join(outer, inner)), Fields{"table": "orders", "code": 0}},
+	}
+	for _, test := range tests {
+		fields := logger.WithError(test.err).Fields
+		if fields["error"] != test.err {
+			t.Errorf("WithError(%v): error field = %v, expected the error", test.err, fields["error"])
+		}
+		details, ok := fields[ErrorDetails]
+		if test.expected == nil {
+			if ok {
+				t.Errorf("WithError(%v): details = %v, expected none", test.err, details)
+			}
+		} else if !reflect.DeepEqual(details, test.expected) {
+			t.Errorf("WithError(%v): details = %v, expected %v", test.err, details, test.expected)
+		}
+	}
+}
+
+func TestEventErrDetails(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	err := fmt.Errorf("saving: %w", &queryError{table: "users", code: 42})
+	logger.NewEvent(LevelError).Str("table", "audit").Err(err).Msg("failed")
+	logger.Close()
+
+	e := target.entries[0]
+	if e.Fields["table"] != "audit" || e.Fields["error"] != err {
+		t.Errorf("fields = %v, expected the details not to collide with the other fields", e.Fields)
+	}
+	if expected := (Fields{"table": "users", "code": 42}); !reflect.DeepEqual(e.Fields[ErrorDetails], expected) {
+		t.Errorf("details = %v, expected %v", e.Fields[ErrorDetails], expected)
+	}
+}
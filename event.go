@@ -0,0 +1,131 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event builds a log entry with fields added by chainable typed setters. For example,
+//
+//	logger.NewEvent(log.LevelInfo).Str("user", user).Int("count", n).Msg("done")
+//
+// An Event is created by Logger.NewEvent and emitted by Msg or Msgf. It must not be used after it is emitted.
+// If the level is filtered out, NewEvent returns nil, on which all methods are no-ops, so that
+// a filtered event allocates nothing (the arguments of the setters are still evaluated, though).
+type Event struct {
+	logger *Logger
+	level  Level
+	fields Fields
+	at     time.Time // the time set by Time
+}
+
+// NewEvent creates an Event of the given severity level.
+// It returns nil if messages of the level are not logged by the logger.
+func (l *Logger) NewEvent(level Level) *Event {
+	if l.discards(level) || !l.accepting() {
+		return nil
+	}
+	return &Event{logger: l, level: level}
+}
+
+// Str adds a string field.
+func (e *Event) Str(name, value string) *Event {
+	return e.set(name, value)
+}
+
+// Int adds an integer field.
+func (e *Event) Int(name string, value int) *Event {
+	return e.set(name, value)
+}
+
+// Bool adds a boolean field.
+func (e *Event) Bool(name string, value bool) *Event {
+	return e.set(name, value)
+}
+
+// Float adds a floating-point field.
+func (e *Event) Float(name string, value float64) *Event {
+	return e.set(name, value)
+}
+
+// Dur adds a duration field.
+func (e *Event) Dur(name string, value time.Duration) *Event {
+	return e.set(name, value)
+}
+
+// Time sets the time of the entry instead of the current time, e.g. for an event timestamped by its source.
+func (e *Event) Time(t time.Time) *Event {
+	if e != nil {
+		e.at = t
+	}
+	return e
+}
+
+// Err adds the error as the "error" field, and its details as the ErrorDetails group field like Logger.WithError.
+// A nil error is ignored.
+func (e *Event) Err(err error) *Event {
+	if err == nil {
+		return e
+	}
+	if details := errorDetails(err); len(details) > 0 {
+		e.set(ErrorDetails, details)
+	}
+	return e.set("error", err)
+}
+
+// Msg emits the event with the given message.
+func (e *Event) Msg(message string) {
+	if e != nil {
+		e.emit(message)
+	}
+}
+
+// Msgf emits the event with the message formatted by fmt.Sprintf.
+func (e *Event) Msgf(format string, a ...interface{}) {
+	if e != nil {
+		e.emit(fmt.Sprintf(format, a...))
+	}
+}
+
+// set adds a field to the event.
+func (e *Event) set(name string, value interface{}) *Event {
+	if e == nil {
+		return nil
+	}
+	if e.fields == nil {
+		e.fields = make(Fields, 4)
+	}
+	e.fields[name] = value
+	return e
+}
+
+// emit logs the entry of the event. It must be called directly by Msg or Msgf
+// so that the call stack of the entry starts at the caller of those methods.
+func (e *Event) emit(message string) {
+	l := e.logger
+	entry := &Entry{
+		Level:   e.level,
+		Message: message,
+	}
+	if len(l.groups) > 0 {
+		// nest the event fields in the logger's groups
+		l = l.WithFields(e.fields)
+	} else {
+		entry.Fields = e.fields
+	}
+	if !l.accept(entry) {
+		return
+	}
+	entry.Time = e.at
+	if e.at.IsZero() {
+		entry.Time = time.Now()
+	}
+	if depth := l.callStackDepth(entry.Level, entry.Category); depth > 0 {
+		entry.CallStack = GetCallStack(3+l.callerSkip(), depth, l.CallStackFilter)
+	}
+	l.enqueue(entry)
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	if e := logger.NewEvent(LevelDebug); e != nil {
+		t.Errorf("NewEvent(LevelDebug) = %v, expected nil", e)
+	}
+	logger.NewEvent(LevelDebug).Str("user", "bob").Err(errors.New("ignored")).Msg("filtered")
+	logger.NewEvent(LevelInfo).Str("user", "bob").Int("count", 3).Bool("ok", true).Float("ratio", 0.5).
+		Dur("elapsed", time.Second).Err(nil).Msg("done")
+	logger.WithGroup("req").NewEvent(LevelError).Err(errors.New("timeout")).Msgf("failed: %v", 1)
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	e := target.entries[0]
+	if e.Level != LevelInfo || e.Message != "done" {
+		t.Errorf("entries[0] = %v %q, expected %v %q", e.Level, e.Message, LevelInfo, "done")
+	}
+	expected := Fields{"user": "bob", "count": 3, "ok": true, "ratio": 0.5, "elapsed": time.Second}
+	for name, value := range expected {
+		if e.Fields[name] != value {
+			t.Errorf("entries[0].Fields[%v] = %v, expected %v", name, e.Fields[name], value)
+		}
+	}
+	if _, ok := e.Fields["error"]; ok || len(e.Fields) != len(expected) {
+		t.Errorf("entries[0].Fields = %v, expected %v", e.Fields, expected)
+	}
+	if !strings.Contains(e.CallStack, "event_test.go") {
+		t.Errorf("entries[0].CallStack = %q, expected the frame of the caller", e.CallStack)
+	}
+
+	e = target.entries[1]
+	if req, _ := e.Fields["req"].(Fields); e.Message != "failed: 1" || req == nil || req["error"].(error).Error() != "timeout" {
+		t.Errorf("entries[1] = %q %v, expected the error nested in the group", e.Message, e.Fields)
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	logger.NewEvent(LevelInfo).Time(at).Str("source", "import").Msg("historical")
+	logger.NewEvent(LevelDebug + 1).Time(at).Msg("filtered")
+	logger.Close()
+
+	if len(target.entries) != 1 || !target.entries[0].Time.Equal(at) {
+		t.Errorf("entries = %v, expected a single entry at %v", target.entries, at)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "io"
+
+// Windows Event Log entry types.
+const (
+	eventLogErrorType       = 0x0001
+	eventLogWarningType     = 0x0002
+	eventLogInformationType = 0x0004
+)
+
+// WindowsEventLogTarget writes filtered log messages to the Windows Event Log.
+//
+// The event source named by Source should be registered in the registry under
+// HKLM\SYSTEM\CurrentControlSet\Services\EventLog\Application before the target is used,
+// which requires administrator privileges (e.g. done once by the installer via
+// "eventcreate" or golang.org/x/sys/windows/svc/eventlog.InstallAsEventCreate).
+// Writing events through a registered source requires no special privileges.
+// If the source is not registered, events are still written, but Event Viewer
+// will report that the event description cannot be found.
+//
+// On non-Windows platforms, Open always returns an error.
+type WindowsEventLogTarget struct {
+	*Filter
+	Name    string            // the name identifying the target in diagnostics
+	Tags    map[string]string // the tags identifying the target in diagnostics
+	Source  string            // the event source name. Defaults to "ozzo-log".
+	EventID uint32            // the event identifier reported with every event
+
+	handle    uintptr
+	errWriter io.Writer
+	close     chan bool
+}
+
+// NewWindowsEventLogTarget creates a WindowsEventLogTarget.
+// The new WindowsEventLogTarget takes these default options:
+// MaxLevel: LevelDebug, Source: "ozzo-log", EventID: 1
+func NewWindowsEventLogTarget() *WindowsEventLogTarget {
+	return &WindowsEventLogTarget{
+		Filter:  &Filter{MaxLevel: LevelDebug},
+		Source:  "ozzo-log",
+		EventID: 1,
+		close:   make(chan bool, 0),
+	}
+}
+
+// eventLogType maps a log level to a Windows Event Log entry type.
+func eventLogType(level Level) uint16 {
+	switch {
+	case level <= LevelError:
+		return eventLogErrorType
+	case level == LevelWarning:
+		return eventLogWarningType
+	}
+	return eventLogInformationType
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *WindowsEventLogTarget) Describe() TargetInfo {
+	return newTargetInfo("WindowsEventLogTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *WindowsEventLogTarget) label() string {
+	return targetLabel("WindowsEventLogTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,26 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"errors"
+	"io"
+)
+
+// Open always fails because the Windows Event Log is not available on this platform.
+func (t *WindowsEventLogTarget) Open(io.Writer) error {
+	return errors.New("WindowsEventLogTarget is only supported on Windows")
+}
+
+// Process does nothing on this platform.
+func (t *WindowsEventLogTarget) Process(*Entry) {
+}
+
+// Close does nothing on this platform.
+func (t *WindowsEventLogTarget) Close() {
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestNewWindowsEventLogTarget(t *testing.T) {
+	target := NewWindowsEventLogTarget()
+	if target.MaxLevel != LevelDebug {
+		t.Errorf("NewWindowsEventLogTarget.MaxLevel = %v, expected %v", target.MaxLevel, LevelDebug)
+	}
+	if target.Source != "ozzo-log" {
+		t.Errorf("NewWindowsEventLogTarget.Source = %v, expected %v", target.Source, "ozzo-log")
+	}
+	if runtime.GOOS != "windows" {
+		if err := target.Open(nil); err == nil {
+			t.Errorf("WindowsEventLogTarget.Open() should fail on %v", runtime.GOOS)
+		}
+	}
+}
+
+func TestEventLogType(t *testing.T) {
+	tests := []struct {
+		level    Level
+		expected uint16
+	}{
+		{LevelEmergency, eventLogErrorType},
+		{LevelCritical, eventLogErrorType},
+		{LevelError, eventLogErrorType},
+		{LevelWarning, eventLogWarningType},
+		{LevelNotice, eventLogInformationType},
+		{LevelInfo, eventLogInformationType},
+		{LevelDebug, eventLogInformationType},
+	}
+	for _, test := range tests {
+		if eventLogType(test.level) != test.expected {
+			t.Errorf("eventLogType(%v) = %v, expected %v", test.level, eventLogType(test.level), test.expected)
+		}
+	}
+}
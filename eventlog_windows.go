@@ -0,0 +1,80 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSource   = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEvent           = advapi32.NewProc("ReportEventW")
+)
+
+// Open registers the event source and prepares WindowsEventLogTarget for processing log messages.
+func (t *WindowsEventLogTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Source == "" {
+		return errors.New("WindowsEventLogTarget.Source must be specified")
+	}
+	source, err := syscall.UTF16PtrFromString(t.Source)
+	if err != nil {
+		return fmt.Errorf("WindowsEventLogTarget.Source is invalid: %v", err)
+	}
+	handle, _, err := procRegisterEventSource.Call(0, uintptr(unsafe.Pointer(source)))
+	if handle == 0 {
+		return fmt.Errorf("%v was unable to register event source %q: %v", t.label(), t.Source, err)
+	}
+	t.handle = handle
+	t.errWriter = errWriter
+	return nil
+}
+
+// Process writes an allowed log message to the Windows Event Log.
+func (t *WindowsEventLogTarget) Process(e *Entry) {
+	if e == nil {
+		procDeregisterEventSource.Call(t.handle)
+		t.handle = 0
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	msg, err := syscall.UTF16PtrFromString(e.String())
+	if err != nil {
+		fmt.Fprintf(t.errWriter, "%v write error: %v\n", t.label(), err)
+		return
+	}
+	strs := []*uint16{msg}
+	ok, _, err := procReportEvent.Call(
+		t.handle,
+		uintptr(eventLogType(e.Level)),
+		0,
+		uintptr(t.EventID),
+		0,
+		uintptr(len(strs)),
+		0,
+		uintptr(unsafe.Pointer(&strs[0])),
+		0,
+	)
+	if ok == 0 {
+		fmt.Fprintf(t.errWriter, "%v write error: %v\n", t.label(), err)
+	}
+}
+
+// Close closes the Windows Event Log target.
+func (t *WindowsEventLogTarget) Close() {
+	<-t.close
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// maxEveryKeys is the maximum number of keys tracked by a logger returned by Logger.Every.
+const maxEveryKeys = 1000
+
+// Every returns a logger logging each recurring message at most once per the given duration, e.g. a message
+// logged in a tight loop, in addition to the level filtering and sampling:
+//
+//	throttled := logger.Every(time.Second)
+//	for {
+//		...
+//		throttled.Warning("queue is full, dropping %v", item)
+//	}
+//
+// The messages are keyed by their level, their category and their format (or the message itself for LogEntry
+// and events), so that a message formatted with varying arguments is limited as a whole. The entries logged
+// within the duration of the last kept entry of the same key are suppressed, and the next kept entry has
+// their number as the "suppressed" field. The loggers derived from the returned logger share its limit.
+//
+// At most 1000 keys are tracked: when a new key would exceed it, the keys whose duration has expired are
+// dropped, or an arbitrary key if there is none (the number of entries it suppressed is then lost).
+// A duration no greater than 0 returns a logger without the limit.
+func (l *Logger) Every(d time.Duration) *Logger {
+	ret := l.Dup()
+	ret.every = nil
+	if d > 0 {
+		ret.every = &everyLimiter{interval: d, keys: make(map[everyKey]*everyState)}
+	}
+	return ret
+}
+
+// everyLimiter keeps at most one entry of each key per interval for Logger.Every.
+type everyLimiter struct {
+	interval time.Duration
+
+	lock sync.Mutex
+	keys map[everyKey]*everyState
+}
+
+// everyKey identifies the recurring messages limited by Logger.Every.
+type everyKey struct {
+	level    Level
+	category string
+	message  string
+}
+
+// everyState is the state of a key of an everyLimiter.
+type everyState struct {
+	last       time.Time // the time of the last entry kept
+	suppressed int       // the number of entries suppressed since then
+}
+
+// check reports whether an entry may be logged, keying it by the given message. If the entry follows suppressed
+// entries, their number is added to its fields.
+func (r *everyLimiter) check(e *Entry, message string) bool {
+	ok, suppressed := r.allow(everyKey{e.Level, e.Category, message}, time.Now())
+	if ok && suppressed > 0 {
+		// the fields of a pre-built entry may be shared by the caller, so they are copied
+		fields := make(Fields, len(e.Fields)+1)
+		for dn, d := range e.Fields {
+			fields[dn] = d
+		}
+		fields["suppressed"] = suppressed
+		e.Fields = fields
+	}
+	return ok
+}
+
+// allow reports whether an entry of the given key may be logged at the given time, and the number of entries
+// of the key suppressed since the last one kept.
+func (r *everyLimiter) allow(key everyKey, now time.Time) (bool, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s, ok := r.keys[key]; ok {
+		if now.Sub(s.last) < r.interval {
+			s.suppressed++
+			return false, 0
+		}
+		suppressed := s.suppressed
+		s.last, s.suppressed = now, 0
+		return true, suppressed
+	}
+	if len(r.keys) >= maxEveryKeys {
+		r.evict(now)
+	}
+	r.keys[key] = &everyState{last: now}
+	return true, 0
+}
+
+// evict drops the keys whose interval has expired, or an arbitrary key if there is none.
+// The caller must hold the lock.
+func (r *everyLimiter) evict(now time.Time) {
+	for key, s := range r.keys {
+		if now.Sub(s.last) >= r.interval {
+			delete(r.keys, key)
+		}
+	}
+	if len(r.keys) < maxEveryKeys {
+		return
+	}
+	for key := range r.keys {
+		delete(r.keys, key)
+		break
+	}
+}
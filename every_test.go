@@ -0,0 +1,79 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoggerEvery(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	throttled := logger.Every(time.Hour)
+	for i := 0; i < 100; i++ {
+		throttled.Info("tick %v", i)
+		throttled.Error("tick %v", i)
+		throttled.GetLogger("db").Info("tick %v", i)
+		throttled.WithField("i", i).LogEntry(&Entry{Level: LevelInfo, Message: "entry"})
+		throttled.NewEvent(LevelInfo).Int("i", i).Msg("event")
+	}
+	logger.Info("tick %v", 1)
+	if logger.Every(0).every != nil {
+		t.Errorf("Every(0) returned a limited logger, expected no limit")
+	}
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, fmt.Sprintf("%v %v %v", e.Level, e.Category, e.Message))
+	}
+	expected := []string{"Info app tick 0", "Error app tick 0", "Info db tick 0", "Info app entry", "Info app event", "Info app tick 1"}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("entries = %q, expected %q", messages, expected)
+	}
+}
+
+func TestLoggerEverySuppressed(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	throttled := logger.Every(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		throttled.Info("retrying")
+	}
+	time.Sleep(60 * time.Millisecond)
+	throttled.Info("retrying")
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("number of entries = %v, expected 2", len(target.entries))
+	}
+	if _, ok := target.entries[0].Fields["suppressed"]; ok {
+		t.Errorf("first entry fields = %v, expected no suppressed count", target.entries[0].Fields)
+	}
+	if n := target.entries[1].Fields["suppressed"]; n != 2 {
+		t.Errorf("suppressed = %v, expected 2", n)
+	}
+}
+
+func TestEveryLimiterMaxKeys(t *testing.T) {
+	r := &everyLimiter{interval: time.Second, keys: make(map[everyKey]*everyState)}
+	now := time.Now()
+	for i := 0; i < maxEveryKeys; i++ {
+		r.allow(everyKey{LevelInfo, "app", fmt.Sprint(i)}, now)
+	}
+	r.allow(everyKey{LevelInfo, "app", "0"}, now)
+	if ok, _ := r.allow(everyKey{LevelInfo, "app", "new"}, now.Add(time.Millisecond)); !ok || len(r.keys) != maxEveryKeys {
+		t.Errorf("allow() = %v with %v keys, expected a key to be evicted for the new one", ok, len(r.keys))
+	}
+	if ok, _ := r.allow(everyKey{LevelInfo, "app", "later"}, now.Add(2*time.Second)); !ok || len(r.keys) != 1 {
+		t.Errorf("allow() = %v with %v keys, expected the expired keys to be evicted", ok, len(r.keys))
+	}
+}
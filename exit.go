@@ -0,0 +1,43 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// closeBeforeExit closes the logger before the process panics or exits, waiting at most ExitFlushTimeout
+// for the targets. The targets closed in time have persisted the pending entries; if the timeout expires,
+// the remaining ones are abandoned and reported to ErrorWriter, and the close goes on in the background
+// until the process ends.
+func (l *coreLogger) closeBeforeExit() {
+	if l.ExitFlushTimeout <= 0 {
+		l.Close()
+		return
+	}
+	closed := make(chan bool)
+	go func() {
+		l.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(l.ExitFlushTimeout):
+		// Targets is not changed after the logger is opened, so it can be read while CloseError runs
+		n := int(atomic.LoadInt32(&l.closing))
+		if n >= len(l.Targets) {
+			return
+		}
+		names := make([]string, 0, len(l.Targets)-n)
+		for _, target := range l.Targets[n:] {
+			names = append(names, targetDescription(target))
+		}
+		fmt.Fprintf(l.ErrorWriter, "Logger abandoned %v target(s) not closed within %v before exiting: %v\n",
+			len(names), l.ExitFlushTimeout, strings.Join(names, ", "))
+	}
+}
@@ -5,16 +5,35 @@
 package log
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 // FileTarget writes filtered log messages to a file.
 // FileTarget supports file rotation by keeping certain number of backup log files.
+//
+// By default, every message is written to the file immediately. Setting BufferSize enables buffering:
+// the buffered messages are written when the buffer is full, when the file is rotated, when the target
+// is closed, and right after a message of FlushOnLevel or above, so that a crash following an error does
+// not lose the error message, while the messages of lower levels are still written in batches.
+//
+// By default, Open fails if the log file cannot be created, e.g. because its directory does not exist yet.
+// Setting OpenRetryInterval makes Open succeed anyway and create the log file in the background instead,
+// which suits the containers whose volumes are mounted after the application starts: every attempt is
+// reported to the error writer, and up to MaxPendingEntries messages are kept and written once the log
+// file is created.
 type FileTarget struct {
 	*Filter
+	// the name identifying the target in diagnostics.
+	Name string
+	// the tags identifying the target in diagnostics.
+	Tags map[string]string
 	// the log file name. When Rotate is true, log file name will be suffixed
 	// to differentiate different backup copies (e.g. app.log.1)
 	FileName string
@@ -26,24 +45,68 @@ type FileTarget struct {
 	// maximum number of bytes allowed for a log file. Zero means no limit.
 	// This field is ignored when Rotate is false.
 	MaxBytes int64
+	// maximum number of bytes allowed for the log file and its backups together. Zero means no limit.
+	// The oldest backups are deleted when the backups and a log file of MaxBytes would exceed it, so whichever
+	// of BackupCount and MaxTotalBytes is stricter wins. This field is ignored when Rotate is false.
+	MaxTotalBytes int64
+	// whether to append RecordSeparator to every message.
+	TrailingNewline bool
+	// the separator appended to every message when TrailingNewline is true, e.g. "\x00" for the consumers reading
+	// NUL-delimited records or "\r\n". An empty separator means a newline. A message and its separator are always
+	// written together, to the same log file.
+	RecordSeparator string
+	// the size of the write buffer in bytes. 0 disables buffering.
+	BufferSize int
+	// the least severe level whose messages flush the buffer immediately.
+	// This field is ignored when BufferSize is 0.
+	FlushOnLevel Level
+	// how often the buffered messages are written even if the buffer is not full. 0 disables the periodic flush.
+	// This field is ignored when BufferSize is 0.
+	FlushInterval time.Duration
+	// whether to remove the ANSI escape sequences, e.g. colors, already in the messages, such as those
+	// of the output forwarded from a colorized subprocess, which are garbage in a file.
+	StripANSI bool
+	// how often the log file is created again if it cannot be created by Open. 0 makes Open fail instead.
+	OpenRetryInterval time.Duration
+	// the maximum number of attempts to create the log file after Open, after which the target gives up
+	// and drops the messages. 0 means no limit. This field is ignored when OpenRetryInterval is 0.
+	OpenMaxAttempts int
+	// the maximum number of messages kept until the log file is created; the subsequent ones are dropped.
+	// This field is ignored when OpenRetryInterval is 0.
+	MaxPendingEntries int
 
+	lock         sync.Mutex // guards the fields below against ForceRotate and the attempts to create the log file
+	opened       bool
 	fd           *os.File
+	buf          *bufio.Writer
 	currentBytes int64
 	errWriter    io.Writer
+	closeErr     error
+	failures     writeFailures
 	close        chan bool
+	stop         chan bool
+	retry        chan bool // closed to stop the attempts to create the log file, or nil if there are none
+	pending      []*Entry  // the messages kept until the log file is created
+	dropped      int       // the number of messages dropped because the log file could not be created
 }
 
 // NewFileTarget creates a FileTarget.
 // The new FileTarget takes these default options:
-// MaxLevel: LevelDebug, Rotate: true, BackupCount: 10, MaxBytes: 1 << 20
+// MaxLevel: LevelDebug, Rotate: true, BackupCount: 10, MaxBytes: 1 << 20, TrailingNewline: true, RecordSeparator: "\n",
+// BufferSize: 0, FlushOnLevel: LevelError, FlushInterval: 1s, OpenRetryInterval: 0, MaxPendingEntries: 1000
 // You must specify the FileName field.
 func NewFileTarget() *FileTarget {
 	return &FileTarget{
-		Filter:      &Filter{MaxLevel: LevelDebug},
-		Rotate:      true,
-		BackupCount: 10,
-		MaxBytes:    1 << 20, // 1MB
-		close:       make(chan bool, 0),
+		Filter:            &Filter{MaxLevel: LevelDebug},
+		Rotate:            true,
+		BackupCount:       10,
+		MaxBytes:          1 << 20, // 1MB
+		TrailingNewline:   true,
+		RecordSeparator:   "\n",
+		FlushOnLevel:      LevelError,
+		FlushInterval:     time.Second,
+		MaxPendingEntries: 1000,
+		close:             make(chan bool, 0),
 	}
 }
 
@@ -60,47 +123,259 @@ func (t *FileTarget) Open(errWriter io.Writer) error {
 		if t.MaxBytes <= 0 {
 			return errors.New("FileTarget.MaxBytes must be no less than 0")
 		}
+		if t.MaxTotalBytes < 0 {
+			return errors.New("FileTarget.MaxTotalBytes must be no less than 0")
+		}
+	}
+	if t.BufferSize < 0 {
+		return errors.New("FileTarget.BufferSize must be no less than 0")
+	}
+	if t.OpenRetryInterval < 0 {
+		return errors.New("FileTarget.OpenRetryInterval must be no less than 0")
 	}
+	t.errWriter = errWriter
+	t.failures = writeFailures{}
 
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pending = nil
+	t.dropped = 0
+	err := t.openFile()
+	if err != nil && t.OpenRetryInterval > 0 {
+		fmt.Fprintf(errWriter, "%v, retrying every %v\n", err, t.OpenRetryInterval)
+		t.retry = make(chan bool, 0)
+		go t.retryOpen(t.retry)
+		return nil
+	}
+	return err
+}
+
+// openFile creates or opens the log file and prepares the target for writing messages to it.
+// The caller must hold the lock.
+func (t *FileTarget) openFile() error {
 	fd, err := os.OpenFile(t.FileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
 	if err != nil {
-		return fmt.Errorf("FileTarget was unable to create a log file: %v", err)
+		return fmt.Errorf("%v was unable to create a log file: %v", t.label(), err)
 	}
 	t.fd = fd
-	t.errWriter = errWriter
-
+	t.buf = nil
+	if t.BufferSize > 0 {
+		t.buf = bufio.NewWriterSize(fd, t.BufferSize)
+		if t.FlushInterval > 0 && t.stop == nil {
+			t.stop = make(chan bool, 0)
+			go t.flushPeriodically(t.stop)
+		}
+	}
+	t.currentBytes = 0
+	t.opened = true
+	if t.Rotate {
+		t.pruneBackups()
+	}
 	return nil
 }
 
+// retryOpen creates the log file every OpenRetryInterval until it succeeds, OpenMaxAttempts is reached
+// or stop is closed. The pending messages are written once the log file is created.
+func (t *FileTarget) retryOpen(stop chan bool) {
+	ticker := time.NewTicker(t.OpenRetryInterval)
+	defer ticker.Stop()
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		t.lock.Lock()
+		if t.retry != stop {
+			// the target was closed while waiting for the lock
+			t.lock.Unlock()
+			return
+		}
+		err := t.openFile()
+		if err == nil {
+			fmt.Fprintf(t.errWriter, "%v created the log file after %v attempt(s)\n", t.label(), attempt)
+			t.retry = nil
+			for _, e := range t.pending {
+				t.write(e)
+			}
+			t.pending = nil
+			t.lock.Unlock()
+			return
+		}
+		if t.OpenMaxAttempts > 0 && attempt >= t.OpenMaxAttempts {
+			fmt.Fprintf(t.errWriter, "%v, giving up after %v attempt(s)\n", err, attempt)
+			t.failures.add(err)
+			t.retry = nil
+			t.dropped += len(t.pending)
+			t.pending = nil
+			t.lock.Unlock()
+			return
+		}
+		fmt.Fprintf(t.errWriter, "%v, attempt %v\n", err, attempt)
+		t.lock.Unlock()
+	}
+}
+
 // Process saves an allowed log message into the log file.
 func (t *FileTarget) Process(e *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	if e == nil {
-		t.fd.Close()
+		var err error
+		if t.stop != nil {
+			close(t.stop)
+			t.stop = nil
+		}
+		if t.retry != nil {
+			close(t.retry)
+			t.retry = nil
+			t.dropped += len(t.pending)
+			t.pending = nil
+		}
+		if t.fd != nil {
+			t.flush()
+			if err = t.fd.Close(); err != nil {
+				err = fmt.Errorf("%v was unable to close the log file: %v", t.label(), err)
+			}
+			t.fd = nil
+		}
+		t.opened = false
+		var dropErr error
+		if t.dropped > 0 {
+			dropErr = fmt.Errorf("%v dropped %v entries because the log file could not be created", t.label(), t.dropped)
+		}
+		t.closeErr = joinErrors(t.failures.err(t.label()), err, dropErr)
 		t.close <- true
 		return
 	}
+	if t.retry != nil && t.Allow(e) {
+		if len(t.pending) < t.MaxPendingEntries {
+			t.pending = append(t.pending, e)
+		} else {
+			t.dropped++
+		}
+		return
+	}
 	if t.fd != nil && t.Allow(e) {
-		if t.Rotate {
-			t.rotate(int64(len(e.String()) + 1))
+		t.write(e)
+	}
+}
+
+// write writes a message to the log file, rotating it if needed. The caller must hold the lock.
+func (t *FileTarget) write(e *Entry) {
+	msg := e.String()
+	if t.StripANSI {
+		msg = stripANSI(msg)
+	}
+	if t.TrailingNewline {
+		if t.RecordSeparator == "" {
+			msg += "\n"
+		} else {
+			msg += t.RecordSeparator
 		}
-		n, err := t.fd.Write([]byte(e.String() + "\n"))
-		t.currentBytes += int64(n)
-		if err != nil {
-			fmt.Fprintf(t.errWriter, "FileTarge write error: %v\n", err)
+	}
+	if t.Rotate {
+		t.rotate(int64(len(msg)))
+	}
+	var n int
+	var err error
+	if t.buf != nil && t.fd != nil {
+		n, err = t.buf.WriteString(msg)
+		if err == nil && e.Level <= t.FlushOnLevel {
+			err = t.buf.Flush()
 		}
+	} else {
+		n, err = t.fd.Write([]byte(msg))
+	}
+	t.currentBytes += int64(n)
+	if err != nil {
+		t.failures.add(err)
+		fmt.Fprintf(t.errWriter, "%v write error: %v\n", t.label(), err)
+	} else {
+		t.failures.ok()
+	}
+}
+
+// flushPeriodically writes the buffered messages every FlushInterval until stop is closed.
+func (t *FileTarget) flushPeriodically(stop chan bool) {
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.lock.Lock()
+			if t.fd != nil {
+				t.flush()
+			}
+			t.lock.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flush writes the buffered messages to the log file, if buffering is enabled.
+// The caller must hold the lock.
+func (t *FileTarget) flush() {
+	if t.buf == nil {
+		return
+	}
+	if err := t.buf.Flush(); err != nil {
+		t.failures.add(err)
+		fmt.Fprintf(t.errWriter, "%v write error: %v\n", t.label(), err)
 	}
 }
 
 // Close closes the file target.
 func (t *FileTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the file target and returns the errors occurred when writing messages
+// to the log file or when closing it.
+func (t *FileTarget) CloseError() error {
 	<-t.close
+	return t.closeErr
+}
+
+// FailureStreak returns the number of consecutive failures to write messages to the log file or to rotate it.
+func (t *FileTarget) FailureStreak() int {
+	return t.failures.failureStreak()
+}
+
+// ForceRotate rotates the log file regardless of its size, in the same way as the automatic rotation
+// (the method cannot be named Rotate, which is the name of the field enabling the automatic rotation).
+// The current log file becomes the first backup file and a new log file is created. At most
+// BackupCount backup files are kept; if BackupCount is 0, the current log file is discarded.
+// ForceRotate may be called while messages are being logged. It returns an error if the target is
+// not open or the new log file cannot be created (the target then stops writing messages until
+// the next rotation succeeds).
+func (t *FileTarget) ForceRotate() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.opened {
+		return fmt.Errorf("%v is not open", t.label())
+	}
+	return t.rotateFile()
 }
 
 func (t *FileTarget) rotate(bytes int64) {
 	if t.currentBytes+bytes <= t.MaxBytes || bytes > t.MaxBytes {
 		return
 	}
-	t.fd.Close()
+	if err := t.rotateFile(); err != nil {
+		t.failures.add(err)
+		fmt.Fprintf(t.errWriter, "%v\n", err)
+	}
+}
+
+// rotateFile closes the log file, renames it and the backup files, and creates a new log file.
+// The caller must hold the lock.
+func (t *FileTarget) rotateFile() error {
+	if t.fd != nil {
+		t.flush()
+		t.fd.Close()
+	}
 	t.currentBytes = 0
 
 	var err error
@@ -119,9 +394,59 @@ func (t *FileTarget) rotate(bytes int64) {
 			os.Rename(path, fmt.Sprintf("%v.%v", t.FileName, i+1))
 		}
 	}
+	t.pruneBackups()
 	t.fd, err = os.OpenFile(t.FileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
 	if err != nil {
 		t.fd = nil
-		fmt.Fprintf(t.errWriter, "FileTarget was unable to create a log file: %v", err)
+		return fmt.Errorf("%v was unable to create a log file: %v", t.label(), err)
+	}
+	if t.buf != nil {
+		t.buf.Reset(t.fd)
+	}
+	return nil
+}
+
+// pruneBackups deletes the oldest backup files that do not fit in MaxTotalBytes together with
+// the newer backups and a log file of MaxBytes. The caller must hold the lock.
+func (t *FileTarget) pruneBackups() {
+	if t.MaxTotalBytes <= 0 {
+		return
+	}
+	total := t.MaxBytes
+	for i := 1; i <= t.BackupCount; i++ {
+		path := fmt.Sprintf("%v.%v", t.FileName, i)
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		if total += info.Size(); total > t.MaxTotalBytes {
+			os.Remove(path)
+		}
 	}
 }
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *FileTarget) Describe() TargetInfo {
+	return newTargetInfo("FileTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *FileTarget) label() string {
+	return targetLabel("FileTarget", t.Name, t.Tags)
+}
+
+// NewLeveledFileTargets creates a conventional set of FileTargets writing to the given directory:
+//
+//	debug.log: messages of all levels
+//	error.log: messages of LevelError or above (Error, Critical, Alert and Emergency)
+//
+// Both targets take the default options of NewFileTarget, which rotate the files
+// when they reach 1MB and keep 10 backup files (e.g. error.log.1).
+func NewLeveledFileTargets(dir string) []Target {
+	debug := NewFileTarget()
+	debug.FileName = filepath.Join(dir, "debug.log")
+	errs := NewFileTarget()
+	errs.FileName = filepath.Join(dir, "error.log")
+	errs.MinLevel = LevelError
+	return []Target{debug, errs}
+}
@@ -7,8 +7,11 @@ package log_test
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-ozzo/ozzo-log"
 )
@@ -55,3 +58,378 @@ func TestFileTarget(t *testing.T) {
 		t.Errorf("Expected %q not found", "t2: 3")
 	}
 }
+
+func TestFileTargetName(t *testing.T) {
+	target := log.NewFileTarget()
+	target.Name = "audit"
+	target.FileName = "no-such-dir/app.log"
+	err := target.Open(os.Stderr)
+	if err == nil || !strings.Contains(err.Error(), `FileTarget "audit" was unable`) {
+		t.Errorf("target.Open() = %v, expected an error mentioning the target name", err)
+	}
+}
+
+func TestNewLeveledFileTargets(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewLogger()
+	logger.Targets = append(logger.Targets, log.NewLeveledFileTargets(dir)...)
+	logger.Open()
+	logger.Info("t1")
+	logger.Error("t2")
+	logger.Critical("t3")
+	logger.Close()
+
+	debug, err := ioutil.ReadFile(filepath.Join(dir, "debug.log"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	errs, err := ioutil.ReadFile(filepath.Join(dir, "error.log"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(debug), "t1") || !strings.Contains(string(debug), "t2") {
+		t.Errorf("debug.log = %q, expected both messages", string(debug))
+	}
+	if strings.Contains(string(errs), "t1") || !strings.Contains(string(errs), "t2") || !strings.Contains(string(errs), "t3") {
+		t.Errorf("error.log = %q, expected only the error and critical messages", string(errs))
+	}
+}
+
+func TestFileTargetMinLevel(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewLogger()
+	errorLog := log.NewFileTarget()
+	errorLog.FileName = filepath.Join(dir, "error.log")
+	errorLog.MinLevel = log.LevelError
+	logger.Targets = append(logger.Targets, errorLog)
+	logger.Open()
+	logger.Warning("t1")
+	logger.Error("t2")
+	logger.Critical("t3")
+	logger.Close()
+
+	errs, err := ioutil.ReadFile(errorLog.FileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(string(errs), "t1") || !strings.Contains(string(errs), "t2") || !strings.Contains(string(errs), "t3") {
+		t.Errorf("error.log = %q, expected the error and critical messages only", string(errs))
+	}
+}
+
+func TestFileTargetCloseError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is not available")
+	}
+	logger := log.NewLogger()
+	logger.ErrorWriter = ioutil.Discard
+	target := log.NewFileTarget()
+	target.FileName = "/dev/full"
+	target.Rotate = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "FileTarget failed to write 2 message(s)") {
+		t.Errorf("logger.CloseError() = %v, expected the write failures to be reported", err)
+	}
+}
+
+func TestFileTargetForceRotate(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	if err := target.ForceRotate(); err == nil {
+		t.Errorf("target.ForceRotate() should fail when the target is not open")
+	}
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t1"})
+	if err := target.ForceRotate(); err != nil {
+		t.Fatalf("target.ForceRotate(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t2"})
+	go target.Process(nil)
+	if err := target.CloseError(); err != nil {
+		t.Fatalf("target.CloseError(): %v", err)
+	}
+
+	for file, expected := range map[string]string{logFile + ".1": "t1\n", logFile: "t2\n"} {
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(bytes) != expected {
+			t.Errorf("%v = %q, expected %q", filepath.Base(file), string(bytes), expected)
+		}
+	}
+}
+
+func TestFileTargetFlushOnLevel(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	target := log.NewFileTarget()
+	if target.FlushOnLevel != log.LevelError {
+		t.Errorf("NewFileTarget.FlushOnLevel = %v, expected %v", target.FlushOnLevel, log.LevelError)
+	}
+	target.FileName = logFile
+	target.BufferSize = 4096
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	read := func() string {
+		bytes, err := ioutil.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return string(bytes)
+	}
+
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t1"})
+	if content := read(); content != "" {
+		t.Errorf("after an Info entry, the file = %q, expected it to be empty", content)
+	}
+	target.Process(&log.Entry{Level: log.LevelError, FormattedMessage: "t2"})
+	if content := read(); content != "t1\nt2\n" {
+		t.Errorf("after an Error entry, the file = %q, expected %q", content, "t1\nt2\n")
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t3"})
+	go target.Process(nil)
+	if err := target.CloseError(); err != nil {
+		t.Fatalf("target.CloseError(): %v", err)
+	}
+	if content := read(); content != "t1\nt2\nt3\n" {
+		t.Errorf("after closing, the file = %q, expected %q", content, "t1\nt2\nt3\n")
+	}
+}
+
+func TestFileTargetFlushInterval(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	target := log.NewFileTarget()
+	if target.FlushInterval != time.Second {
+		t.Errorf("NewFileTarget.FlushInterval = %v, expected %v", target.FlushInterval, time.Second)
+	}
+	target.FileName = logFile
+	target.BufferSize = 4096
+	target.FlushInterval = 20 * time.Millisecond
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t1"})
+	var content string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		bytes, err := ioutil.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if content = string(bytes); content != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if content != "t1\n" {
+		t.Errorf("after the flush interval, the file = %q, expected %q", content, "t1\n")
+	}
+
+	go target.Process(nil)
+	if err := target.CloseError(); err != nil {
+		t.Fatalf("target.CloseError(): %v", err)
+	}
+}
+
+func TestFileTargetStripANSI(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.StripANSI = true
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "build \x1b[31mfailed\x1b[0m: \x1b[1mmain.go\x1b[22m"})
+	go target.Process(nil)
+	if err := target.CloseError(); err != nil {
+		t.Fatalf("target.CloseError(): %v", err)
+	}
+	bytes, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "build failed: main.go\n"; string(bytes) != expected {
+		t.Errorf("the file = %q, expected %q", string(bytes), expected)
+	}
+}
+
+func TestFileTargetMaxTotalBytes(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.MaxBytes = 10
+	target.MaxTotalBytes = 30
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	// every message fills a log file, so the file rotates before each of the next messages
+	for _, msg := range []string{"message 1", "message 2", "message 3", "message 4", "message 5"} {
+		target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: msg})
+	}
+	go target.Process(nil)
+	if err := target.CloseError(); err != nil {
+		t.Fatalf("target.CloseError(): %v", err)
+	}
+
+	// the log file of MaxBytes and 2 backups of 10 bytes fit in 30 bytes, although BackupCount is 10
+	for file, expected := range map[string]string{logFile: "message 5\n", logFile + ".1": "message 4\n", logFile + ".2": "message 3\n"} {
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(bytes) != expected {
+			t.Errorf("%v = %q, expected %q", filepath.Base(file), string(bytes), expected)
+		}
+	}
+	if _, err := os.Stat(logFile + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%v exists, expected it to be deleted", filepath.Base(logFile+".3"))
+	}
+}
+
+// lockedBuffer is a writer that can be read while a target writes to it from another goroutine.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  strings.Builder
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestFileTargetOpenRetry(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "volume")
+	logFile := filepath.Join(dir, "app.log")
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Fatalf("target.Open() = nil, expected an error without OpenRetryInterval")
+	}
+
+	var errs lockedBuffer
+	target.OpenRetryInterval = 10 * time.Millisecond
+	target.MaxPendingEntries = 1
+	if err := target.Open(&errs); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t1"})
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t2"})
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if strings.Contains(errs.String(), "created the log file") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t3"})
+	go target.Process(nil)
+	err := target.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "FileTarget dropped 1 entries") {
+		t.Errorf("target.CloseError() = %v, expected the entry beyond MaxPendingEntries to be reported", err)
+	}
+
+	bytes, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "t1\nt3\n"; string(bytes) != expected {
+		t.Errorf("the file = %q, expected %q", string(bytes), expected)
+	}
+	if !strings.Contains(errs.String(), ", retrying every 10ms\n") {
+		t.Errorf("ErrorWriter = %q, expected the retries to be reported", errs.String())
+	}
+}
+
+func TestFileTargetOpenMaxAttempts(t *testing.T) {
+	var errs lockedBuffer
+	target := log.NewFileTarget()
+	target.FileName = filepath.Join(t.TempDir(), "missing", "app.log")
+	target.OpenRetryInterval = 10 * time.Millisecond
+	target.OpenMaxAttempts = 2
+	if err := target.Open(&errs); err != nil {
+		t.Fatalf("target.Open(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t1"})
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if strings.Contains(errs.String(), "giving up") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(errs.String(), ", attempt 1\n") || !strings.Contains(errs.String(), ", giving up after 2 attempt(s)\n") {
+		t.Errorf("ErrorWriter = %q, expected 2 attempts to be reported", errs.String())
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "t2"})
+	go target.Process(nil)
+	err := target.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "FileTarget dropped 1 entries") || !strings.Contains(err.Error(), "unable to create a log file") {
+		t.Errorf("target.CloseError() = %v, expected the failure and the dropped entry to be reported", err)
+	}
+}
+
+func TestFileTargetRecordSeparator(t *testing.T) {
+	tests := []struct {
+		separator  string
+		bufferSize int
+	}{
+		{"\x00", 0},
+		{"\r\n", 0},
+		{"\x00", 4096},
+		{"", 0},
+	}
+	for _, test := range tests {
+		logFile := filepath.Join(t.TempDir(), "app.log")
+		target := log.NewFileTarget()
+		target.FileName = logFile
+		target.RecordSeparator = test.separator
+		target.BufferSize = test.bufferSize
+		target.MaxBytes = 8
+		if err := target.Open(os.Stderr); err != nil {
+			t.Fatalf("target.Open(): %v", err)
+		}
+		for _, msg := range []string{"t1", "t2", "t3"} {
+			target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: msg})
+		}
+		go target.Process(nil)
+		if err := target.CloseError(); err != nil {
+			t.Fatalf("target.CloseError(): %v", err)
+		}
+
+		separator := test.separator
+		if separator == "" {
+			separator = "\n"
+		}
+		// the rotation happens before a record that does not fit, so that every file has whole records
+		expected := map[string]string{
+			logFile + ".1": "t1" + separator + "t2" + separator,
+			logFile:        "t3" + separator,
+		}
+		for file, content := range expected {
+			bytes, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(bytes) != content {
+				t.Errorf("with the separator %q and BufferSize %v, %v = %q, expected %q", test.separator, test.bufferSize, filepath.Base(file), string(bytes), content)
+			}
+		}
+	}
+}
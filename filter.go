@@ -5,21 +5,119 @@
 package log
 
 import (
+	"fmt"
+	"math"
 	"strings"
+	"sync/atomic"
 )
 
 // Filter checks if a log message meets the level and category requirements.
 type Filter struct {
+	suppressed  uint64 // the number of rejected messages. Must be the first field for 64-bit alignment.
 	catNames    map[string]bool
 	catPrefixes []string
+	disabled    int32     // 1 if the filter rejects all messages. Accessed atomically.
+	and         []*Filter // the filters that must all allow a message as well (see And)
+	or          []*Filter // the filters of which one must allow a message as well (see Or)
 
-	MaxLevel   Level    // the maximum severity level that is allowed
-	Categories []string // the allowed message categories. Categories can use "*" as a suffix for wildcard matching.
+	MaxLevel Level // the maximum severity level that is allowed
+	// the least severe level that is allowed, e.g. LevelError for a target receiving the errors and the more severe
+	// messages (an "error.log"). Like MaxLevel, it drops the less severe messages: whichever of them is more severe
+	// wins. The zero value sets no bound, so LevelEmergency alone is selected with MaxLevel instead.
+	MinLevel Level
+	// the most severe level that is allowed, e.g. LevelWarning to keep the errors out of a target.
+	// The levels are the numeric RFC5424 levels, where the more severe ones are lower, so this drops the messages
+	// whose level is lower than it. The zero value sets no bound, so it also allows the custom levels more severe
+	// than LevelEmergency (see RegisterLevel).
+	MostSevereLevel Level
+	Categories      []string // the allowed message categories. Categories can use "*" as a suffix for wildcard matching.
 }
 
-// Init initializes the filter.
+// ParseFilter creates a Filter from a compact spec made of a maximum level, optionally followed by a colon
+// and a comma-separated list of categories, which is handy to configure targets from environment variables:
+//
+//	target.Filter, err = log.ParseFilter("warning:db.*,http.*") // MaxLevel: LevelWarning, Categories: db.*, http.*
+//
+// The level name is compared case-insensitively, and spaces around the level and the categories are ignored.
+// An error is returned if the level is unknown or a category is empty.
+func ParseFilter(spec string) (*Filter, error) {
+	name, cats := spec, ""
+	i := strings.IndexByte(spec, ':')
+	if i >= 0 {
+		name, cats = spec[:i], spec[i+1:]
+	}
+	level, err := LevelFromString(strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter spec %q: %v", spec, err)
+	}
+	filter := &Filter{MaxLevel: level}
+	if i >= 0 {
+		for _, cat := range strings.Split(cats, ",") {
+			if cat = strings.TrimSpace(cat); cat == "" {
+				return nil, fmt.Errorf("invalid filter spec %q: empty category", spec)
+			}
+			filter.Categories = append(filter.Categories, cat)
+		}
+	}
+	return filter, nil
+}
+
+// Clone returns a deep copy of the filter, including the categories and the filters it is composed of,
+// whose enabled state is copied too. The copy rejected no message so far, and it must be initialized
+// with Init before it is used, e.g. by the Open method of the target it is assigned to.
+func (t *Filter) Clone() *Filter {
+	c := &Filter{
+		disabled:        atomic.LoadInt32(&t.disabled),
+		MaxLevel:        t.MaxLevel,
+		MinLevel:        t.MinLevel,
+		MostSevereLevel: t.MostSevereLevel,
+		Categories:      append([]string(nil), t.Categories...),
+	}
+	for _, f := range t.and {
+		c.and = append(c.and, f.Clone())
+	}
+	for _, f := range t.or {
+		c.or = append(c.or, f.Clone())
+	}
+	return c
+}
+
+// And returns a filter allowing the messages allowed by this filter and all the given ones, e.g. to restrict
+// a shared base filter. Or returns a filter allowing the messages allowed by any of them. For example,
+//
+//	// errors of any category, and warnings of the "db.*" categories
+//	filter := errs.Or(warnings.And(db))
+//
+// The returned filter allows all levels and categories by itself, but it may be restricted further by setting
+// its MaxLevel, MinLevel, MostSevereLevel and Categories. It refers to the given filters instead of copying them
+// (call Clone for copies), and its Init initializes them, while its Suppressed only counts the messages it rejects.
+func (t *Filter) And(filters ...*Filter) *Filter {
+	c := newCompositeFilter()
+	c.and = append([]*Filter{t}, filters...)
+	return c
+}
+
+// Or returns a filter allowing the messages allowed by this filter or any of the given ones. See And for details.
+func (t *Filter) Or(filters ...*Filter) *Filter {
+	c := newCompositeFilter()
+	c.or = append([]*Filter{t}, filters...)
+	return c
+}
+
+// newCompositeFilter creates a filter allowing all levels and categories, to be composed of other filters.
+func newCompositeFilter() *Filter {
+	return &Filter{MaxLevel: math.MaxInt32}
+}
+
+// Init initializes the filter, including the filters it is composed of.
 // Init must be called before Allow is called.
 func (t *Filter) Init() {
+	for _, f := range t.and {
+		f.Init()
+	}
+	for _, f := range t.or {
+		f.Init()
+	}
 	t.catNames = make(map[string]bool, 0)
 	t.catPrefixes = make([]string, 0)
 	for _, cat := range t.Categories {
@@ -31,12 +129,56 @@ func (t *Filter) Init() {
 	}
 }
 
+// SetEnabled enables or disables the messages passing the filter, e.g. to turn a verbose target on
+// only during a debugging session. A disabled filter rejects all messages. Filters are enabled initially.
+// SetEnabled may be called concurrently with Allow.
+func (t *Filter) SetEnabled(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&t.disabled, disabled)
+}
+
+// Enabled returns whether the filter is enabled (see SetEnabled).
+func (t *Filter) Enabled() bool {
+	return atomic.LoadInt32(&t.disabled) == 0
+}
+
+// Suppressed returns the number of messages rejected by the filter so far.
+// Suppressed may be called concurrently with Allow.
+func (t *Filter) Suppressed() uint64 {
+	return atomic.LoadUint64(&t.suppressed)
+}
+
 // Allow checks if a message meets the severity level and category requirements.
 func (t *Filter) Allow(e *Entry) bool {
 	if e == nil {
 		return true
 	}
-	if e.Level > t.MaxLevel {
+	if !t.allow(e) {
+		atomic.AddUint64(&t.suppressed, 1)
+		return false
+	}
+	return true
+}
+
+func (t *Filter) allow(e *Entry) bool {
+	if !t.Enabled() {
+		return false
+	}
+	if e.Level > t.MaxLevel || t.MostSevereLevel != 0 && e.Level < t.MostSevereLevel {
+		return false
+	}
+	if t.MinLevel != 0 && e.Level > t.MinLevel {
+		return false
+	}
+	for _, f := range t.and {
+		if !f.allow(e) {
+			return false
+		}
+	}
+	if len(t.or) > 0 && !t.anyAllows(e) {
 		return false
 	}
 	if t.catNames[e.Category] {
@@ -49,3 +191,13 @@ func (t *Filter) Allow(e *Entry) bool {
 	}
 	return len(t.catNames) == 0 && len(t.catPrefixes) == 0
 }
+
+// anyAllows checks if one of the filters added by Or allows a message.
+func (t *Filter) anyAllows(e *Entry) bool {
+	for _, f := range t.or {
+		if f.allow(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -36,3 +36,203 @@ func TestFilterAllow(t *testing.T) {
 		}
 	}
 }
+
+func TestFilterMinLevel(t *testing.T) {
+	filter := log.Filter{MaxLevel: log.LevelDebug, MinLevel: log.LevelError}
+	filter.Init()
+	tests := []struct {
+		level    log.Level
+		expected bool
+	}{
+		{log.LevelEmergency, true},
+		{log.LevelCritical, true},
+		{log.LevelError, true},
+		{log.LevelWarning, false},
+		{log.LevelDebug, false},
+	}
+	for _, test := range tests {
+		if filter.Allow(&log.Entry{Level: test.level}) != test.expected {
+			t.Errorf("filter.Allow(%v) = %v, expected %v", test.level, !test.expected, test.expected)
+		}
+	}
+}
+
+func TestFilterMostSevereLevel(t *testing.T) {
+	filter := log.Filter{MaxLevel: log.LevelInfo, MostSevereLevel: log.LevelWarning}
+	filter.Init()
+	tests := []struct {
+		level    log.Level
+		expected bool
+	}{
+		{log.LevelError, false},
+		{log.LevelWarning, true},
+		{log.LevelInfo, true},
+		{log.LevelDebug, false},
+	}
+	for _, test := range tests {
+		if filter.Allow(&log.Entry{Level: test.level}) != test.expected {
+			t.Errorf("filter.Allow(%v) = %v, expected %v", test.level, !test.expected, test.expected)
+		}
+	}
+}
+
+func TestFilterSetEnabled(t *testing.T) {
+	filter := &log.Filter{MaxLevel: log.LevelDebug}
+	filter.Init()
+	e := &log.Entry{Level: log.LevelInfo}
+	if !filter.Enabled() || !filter.Allow(e) {
+		t.Errorf("a filter should be enabled initially")
+	}
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			filter.SetEnabled(i%2 == 0)
+		}
+		filter.SetEnabled(false)
+		close(done)
+	}()
+	for i := 0; i < 1000; i++ {
+		filter.Allow(e)
+	}
+	<-done
+
+	if filter.Allow(e) {
+		t.Errorf("a disabled filter should reject all messages")
+	}
+	if !filter.Allow(nil) {
+		t.Errorf("a disabled filter should still allow the nil entry closing the target")
+	}
+	filter.SetEnabled(true)
+	if !filter.Allow(e) {
+		t.Errorf("a re-enabled filter should allow the message")
+	}
+}
+
+func TestFilterSuppressed(t *testing.T) {
+	filter := &log.Filter{MaxLevel: log.LevelInfo, Categories: []string{"app"}}
+	filter.Init()
+	filter.Allow(&log.Entry{Level: log.LevelInfo, Category: "app"})
+	filter.Allow(&log.Entry{Level: log.LevelDebug, Category: "app"})
+	filter.Allow(&log.Entry{Level: log.LevelInfo, Category: "db"})
+	filter.Allow(nil)
+	filter.SetEnabled(false)
+	filter.Allow(&log.Entry{Level: log.LevelInfo, Category: "app"})
+	if filter.Suppressed() != 3 {
+		t.Errorf("filter.Suppressed() = %v, expected %v", filter.Suppressed(), 3)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		spec  string
+		level log.Level
+		cats  []string
+	}{
+		{"warning:db.*,http.*", log.LevelWarning, []string{"db.*", "http.*"}},
+		{"Error:audit", log.LevelError, []string{"audit"}},
+		{" info : app , system.* ", log.LevelInfo, []string{"app", "system.*"}},
+		{"debug", log.LevelDebug, nil},
+	}
+	for _, test := range tests {
+		filter, err := log.ParseFilter(test.spec)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) = %v", test.spec, err)
+			continue
+		}
+		if filter.MaxLevel != test.level || strings.Join(filter.Categories, "|") != strings.Join(test.cats, "|") {
+			t.Errorf("ParseFilter(%q) = %v %q, expected %v %q", test.spec, filter.MaxLevel, filter.Categories, test.level, test.cats)
+		}
+	}
+
+	filter, _ := log.ParseFilter("warning:db.*")
+	filter.Init()
+	if !filter.Allow(&log.Entry{Level: log.LevelError, Category: "db.users"}) {
+		t.Errorf("filter.Allow() = false for an error of db.users, expected true")
+	}
+	if filter.Allow(&log.Entry{Level: log.LevelInfo, Category: "db.users"}) || filter.Allow(&log.Entry{Level: log.LevelError, Category: "http"}) {
+		t.Errorf("filter.Allow() = true for an entry out of the spec, expected false")
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		spec, err string
+	}{
+		{"", `invalid filter spec "": unknown level name ""`},
+		{"loud:app", `invalid filter spec "loud:app": unknown level name "loud"`},
+		{"error:", `invalid filter spec "error:": empty category`},
+		{"error:app,,db", `invalid filter spec "error:app,,db": empty category`},
+	}
+	for _, test := range tests {
+		filter, err := log.ParseFilter(test.spec)
+		if filter != nil || err == nil || err.Error() != test.err {
+			t.Errorf("ParseFilter(%q) = %v, expected %q", test.spec, err, test.err)
+		}
+	}
+}
+
+func TestFilterClone(t *testing.T) {
+	base := &log.Filter{MaxLevel: log.LevelWarning, Categories: []string{"app.*"}}
+	base.SetEnabled(false)
+	clone := base.Clone()
+	clone.Categories[0] = "db.*"
+	clone.MaxLevel = log.LevelDebug
+	if base.Categories[0] != "app.*" || base.MaxLevel != log.LevelWarning {
+		t.Errorf("modifying the clone changed the base filter: %v %v", base.MaxLevel, base.Categories)
+	}
+	if clone.Enabled() {
+		t.Errorf("clone.Enabled() = true, expected the disabled state to be copied")
+	}
+	clone.SetEnabled(true)
+	clone.Init()
+	if !clone.Allow(&log.Entry{Level: log.LevelDebug, Category: "db.users"}) || clone.Allow(&log.Entry{Level: log.LevelDebug, Category: "app"}) {
+		t.Errorf("clone.Allow() does not use the categories of the clone")
+	}
+	if base.Enabled() || clone.Suppressed() != 1 || base.Suppressed() != 0 {
+		t.Errorf("the clone shares its state with the base filter")
+	}
+}
+
+func TestFilterAndOr(t *testing.T) {
+	errs := &log.Filter{MaxLevel: log.LevelError}
+	warnings := &log.Filter{MaxLevel: log.LevelWarning}
+	db := &log.Filter{MaxLevel: log.LevelDebug, Categories: []string{"db.*"}}
+	filter := errs.Or(warnings.And(db))
+	filter.Init()
+	tests := []struct {
+		level    log.Level
+		cat      string
+		expected bool
+	}{
+		{log.LevelError, "app", true},
+		{log.LevelError, "db.users", true},
+		{log.LevelWarning, "app", false},
+		{log.LevelWarning, "db.users", true},
+		{log.LevelInfo, "db.users", false},
+	}
+	for _, test := range tests {
+		if result := filter.Allow(&log.Entry{Level: test.level, Category: test.cat}); result != test.expected {
+			t.Errorf("filter.Allow(%v, %q) = %v, expected %v", test.level, test.cat, result, test.expected)
+		}
+	}
+	if filter.Suppressed() != 2 || db.Suppressed() != 0 {
+		t.Errorf("Suppressed() = %v, %v, expected %v, %v", filter.Suppressed(), db.Suppressed(), 2, 0)
+	}
+
+	// the composite filter may be restricted further, and refers to the composed filters
+	filter.Categories = []string{"db.*"}
+	filter.Init()
+	if filter.Allow(&log.Entry{Level: log.LevelError, Category: "app"}) {
+		t.Errorf("filter.Allow() = true for a category excluded by the composite filter")
+	}
+	db.SetEnabled(false)
+	if filter.Allow(&log.Entry{Level: log.LevelWarning, Category: "db.users"}) {
+		t.Errorf("filter.Allow() = true for a warning after disabling the db filter")
+	}
+	clone := filter.Clone()
+	clone.Init()
+	if !clone.Allow(&log.Entry{Level: log.LevelError, Category: "db.users"}) || clone.Allow(&log.Entry{Level: log.LevelWarning, Category: "db.users"}) {
+		t.Errorf("the clone of the composite filter does not allow the same messages")
+	}
+}
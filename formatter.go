@@ -0,0 +1,507 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultFormatterOptions configures the human-readable formatters created by NewDefaultFormatter.
+type DefaultFormatterOptions struct {
+	// whether to render the category in the fields section (e.g. "category=app") instead of the "[app]" segment.
+	CategoryAsField bool
+	// the key of the category when CategoryAsField is true. Defaults to "category".
+	CategoryKey string
+	// whether to render the time elapsed since the previous entry of the logger (e.g. "+1.2s") instead of the
+	// absolute time, which is handy in long development sessions. The loggers derived from the same root logger
+	// share the previous entry. The first entry is rendered as "+0s".
+	RelativeTime bool
+	// the number of fractional second digits of the time, from 0 (the default) to 9. Values out of range are clamped.
+	// For example, 3 renders the time with millisecond precision as "2016-01-02T03:04:05.123Z".
+	TimePrecision int
+	// the location in which the time is rendered, e.g. time.UTC. Nil renders the time in its own location,
+	// which is time.Local for the messages logged by a Logger. Only the rendering changes, not the instant.
+	Location *time.Location
+	// whether to start every message with the uppercase level followed by a colon (e.g. "ERROR: "),
+	// which many log viewers highlight when they do not support ANSI colors.
+	LevelPrefix bool
+	// whether to replace the "{name}" tokens in the message with the fields (see Interpolate).
+	Interpolate bool
+	// whether to render the entry fields after the message. This is off by default so that
+	// DefaultFormatter keeps producing the same lines as before fields were introduced.
+	Fields bool
+	// whether to render field values (when Fields is true) that are structs, maps, slices or arrays (or pointers to them) as compact JSON.
+	// This relies on reflection and encoding/json for such values, which is noticeably slower than fmt.Sprint,
+	// so it is off by default. Values that cannot be encoded (e.g. cyclic references) are rendered as "!(error)".
+	NestedJSON bool
+}
+
+// NewDefaultFormatter creates a human-readable formatter that formats a log message like the following:
+//
+//	2016-01-02T03:04:05Z [Error][app.db] message key1=value1 key2=value2
+//
+// The fields are rendered only if options.Fields is true. They are sorted by name and rendered
+// in the logfmt style after the message, followed by the call stack (if any).
+// An empty category is omitted, with its brackets, e.g. for the applications setting the Category of their
+// root logger to "" because they do not use categories.
+func NewDefaultFormatter(options DefaultFormatterOptions) Formatter {
+	if options.CategoryKey == "" {
+		options.CategoryKey = "category"
+	}
+	timeFormat := time.RFC3339
+	if options.TimePrecision > 0 {
+		if options.TimePrecision > 9 {
+			options.TimePrecision = 9
+		}
+		// unlike "9", "0" keeps the trailing zeros so that the times are aligned
+		timeFormat = "2006-01-02T15:04:05." + strings.Repeat("0", options.TimePrecision) + "Z07:00"
+	}
+	// the time of the previous entry when the formatter is called without a logger
+	var lastTime int64
+	return func(l *Logger, e *Entry) string {
+		buf := new(bytes.Buffer)
+		if options.LevelPrefix {
+			buf.WriteString(strings.ToUpper(l.levelName(e.Level)))
+			buf.WriteString(": ")
+		}
+		if !e.Time.IsZero() {
+			if options.RelativeTime {
+				last := &lastTime
+				if l != nil && l.coreLogger != nil {
+					last = &l.coreLogger.lastTime
+				}
+				buf.WriteString(relativeTime(last, e.Time))
+			} else {
+				buf.WriteString(inLocation(e.Time, options.Location).Format(timeFormat))
+			}
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(buf, "[%v]", l.levelName(e.Level))
+		if !options.CategoryAsField && e.Category != "" {
+			fmt.Fprintf(buf, "[%v]", e.Category)
+		}
+		buf.WriteByte(' ')
+		if options.Interpolate {
+			buf.WriteString(Interpolate(e.Message, e.Fields))
+		} else {
+			buf.WriteString(e.Message)
+		}
+		if options.CategoryAsField && e.Category != "" {
+			writeLogfmtField(buf, options.CategoryKey, e.Category)
+		}
+		if options.Fields {
+			for _, f := range sortedFields(e.Fields, true) {
+				if options.NestedJSON && isNested(f.value) {
+					buf.WriteByte(' ')
+					buf.WriteString(logfmtValue(f.key))
+					buf.WriteByte('=')
+					buf.WriteString(nestedJSON(f.value))
+				} else {
+					writeLogfmtField(buf, f.key, f.value)
+				}
+			}
+		}
+		buf.WriteString(e.CallStack)
+		return buf.String()
+	}
+}
+
+// relativeTime records t as the time of the last entry and returns the time elapsed since the previous one,
+// e.g. "+1.234s". The elapsed time is rounded to milliseconds, or to microseconds if it is shorter than a millisecond.
+func relativeTime(last *int64, t time.Time) string {
+	now := t.UnixNano()
+	var d time.Duration
+	// the entries formatted concurrently may be out of order, in which case the elapsed time is 0
+	if prev := atomic.SwapInt64(last, now); prev != 0 && now > prev {
+		d = time.Duration(now - prev)
+	}
+	if d >= time.Millisecond {
+		d = d.Round(time.Millisecond)
+	} else {
+		d = d.Round(time.Microsecond)
+	}
+	return "+" + d.String()
+}
+
+// inLocation returns the time in the given location, or the time as is if the location is nil.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return t
+	}
+	return t.In(loc)
+}
+
+// isNested checks if a value is a struct, map, slice or array (or a pointer to one of them)
+// which does not provide its own string representation.
+func isNested(value interface{}) bool {
+	switch value.(type) {
+	case nil, error, fmt.Stringer, []byte:
+		return false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// nestedJSON encodes a nested value as compact JSON.
+// encoding/json detects cyclic references and reports them as errors, which are rendered instead of the value.
+func nestedJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return logfmtValue(fmt.Sprintf("!(%v)", err))
+	}
+	return string(data)
+}
+
+// Interpolate replaces the "{name}" tokens in a message with the values of the named fields, e.g.
+// "login by {user}" becomes "login by bob" if the field "user" is "bob". The fields of groups
+// are named with dots, e.g. "{http.status}". Tokens not matching any field are left as is.
+func Interpolate(message string, fields Fields) string {
+	if len(fields) == 0 || !strings.Contains(message, "{") {
+		return message
+	}
+	var buf bytes.Buffer
+	for {
+		start := strings.IndexByte(message, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(message[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		buf.WriteString(message[:start])
+		if value, ok := lookupField(fields, message[start+1:end]); ok {
+			fmt.Fprint(&buf, logValue(value))
+		} else {
+			buf.WriteString(message[start : end+1])
+		}
+		message = message[end+1:]
+	}
+	buf.WriteString(message)
+	return buf.String()
+}
+
+// lookupField returns the value of a field whose name may refer to the fields of groups with dots.
+func lookupField(fields Fields, name string) (interface{}, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if group, ok := logValue(fields[name[:i]]).(Fields); ok {
+			return lookupField(group, name[i+1:])
+		}
+	}
+	return nil, false
+}
+
+// writeLogfmtField writes a space followed by a logfmt key=value pair.
+func writeLogfmtField(buf *bytes.Buffer, key string, value interface{}) {
+	buf.WriteByte(' ')
+	buf.WriteString(logfmtValue(key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(fmt.Sprint(value)))
+}
+
+// FormatterOptions configures the structured formatters created by NewJSONFormatter and NewLogfmtFormatter.
+// Empty fields take their default values.
+type FormatterOptions struct {
+	TimeKey     string // the key of the entry time. Defaults to "time".
+	LevelKey    string // the key of the entry level. Defaults to "level".
+	CategoryKey string // the key of the entry category. Defaults to "category".
+	MessageKey  string // the key of the entry message. Defaults to "message".
+	StackKey    string // the key of the entry call stack. Defaults to "stack".
+	SeqKey      string // the key of the entry sequence number. Defaults to "seq".
+	TimeFormat  string // the layout used to format the entry time. Defaults to time.RFC3339Nano.
+	Interpolate bool   // whether to replace the "{name}" tokens in the message with the fields (see Interpolate)
+	OmitEmpty   bool   // whether to omit the custom fields whose value is nil or an empty string
+	OmitZero    bool   // whether to omit the custom fields whose value is the zero value of its type, e.g. 0 or false
+	// the location in which the entry time is rendered, e.g. time.UTC. Nil renders the time in its own location.
+	Location *time.Location
+}
+
+// JSONFormatter formats a log message as a single-line JSON object using the default FormatterOptions.
+var JSONFormatter = NewJSONFormatter(FormatterOptions{})
+
+// LogfmtFormatter formats a log message as a logfmt line using the default FormatterOptions.
+var LogfmtFormatter = NewLogfmtFormatter(FormatterOptions{})
+
+// withDefaults returns a copy of the options with the empty fields set to their default values.
+func (o FormatterOptions) withDefaults() FormatterOptions {
+	if o.TimeKey == "" {
+		o.TimeKey = "time"
+	}
+	if o.LevelKey == "" {
+		o.LevelKey = "level"
+	}
+	if o.CategoryKey == "" {
+		o.CategoryKey = "category"
+	}
+	if o.MessageKey == "" {
+		o.MessageKey = "message"
+	}
+	if o.StackKey == "" {
+		o.StackKey = "stack"
+	}
+	if o.SeqKey == "" {
+		o.SeqKey = "seq"
+	}
+	if o.TimeFormat == "" {
+		o.TimeFormat = time.RFC3339Nano
+	}
+	return o
+}
+
+// formatterField is a key-value pair to be rendered by a structured formatter.
+type formatterField struct {
+	key   string
+	value interface{}
+}
+
+// fields returns the reserved attributes of an entry followed by its custom fields sorted by name
+// (see sortedFields for the meaning of flatten). The time, sequence number and call stack are omitted if they are not set.
+// Custom fields whose names collide with the reserved keys are prefixed with "fields.".
+func (o FormatterOptions) fields(l *Logger, e *Entry, flatten bool) []formatterField {
+	message := e.Message
+	if o.Interpolate {
+		message = Interpolate(message, e.Fields)
+	}
+	var result []formatterField
+	if !e.Time.IsZero() {
+		result = append(result, formatterField{o.TimeKey, inLocation(e.Time, o.Location).Format(o.TimeFormat)})
+	}
+	result = append(result, []formatterField{
+		{o.LevelKey, l.levelName(e.Level)},
+		{o.CategoryKey, e.Category},
+		{o.MessageKey, message},
+	}...)
+	if e.Seq != 0 {
+		result = append(result, formatterField{o.SeqKey, e.Seq})
+	}
+	if e.CallStack != "" {
+		result = append(result, formatterField{o.StackKey, strings.TrimPrefix(e.CallStack, "\n")})
+	}
+	reserved := map[string]bool{
+		o.TimeKey:     true,
+		o.LevelKey:    true,
+		o.CategoryKey: true,
+		o.MessageKey:  true,
+		o.SeqKey:      true,
+		o.StackKey:    true,
+	}
+	fields := e.Fields
+	if o.OmitEmpty || o.OmitZero {
+		fields = o.omitEmpty(fields)
+	}
+	for _, f := range sortedFields(fields, flatten) {
+		if reserved[f.key] {
+			f.key = "fields." + f.key
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// omitEmpty returns a copy of the fields without those that are empty according to OmitEmpty and OmitZero,
+// including the fields of groups. The groups left without fields are omitted too.
+func (o FormatterOptions) omitEmpty(fields Fields) Fields {
+	result := make(Fields, len(fields))
+	for name, value := range fields {
+		value = logValue(value)
+		if group, ok := value.(Fields); ok {
+			if group = o.omitEmpty(group); len(group) > 0 {
+				result[name] = group
+			}
+			continue
+		}
+		if value == nil || value == "" {
+			continue
+		}
+		if o.OmitZero && reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// FieldMarshaler is implemented by the field values that need a custom rendering, e.g. a UUID type
+// which should be logged as its canonical string rather than as a byte array. The formatters replace
+// such a value with the one returned by LogValue, which is then rendered normally: it may be a string,
+// a number, a Fields group, a value encoded by encoding/json, or another FieldMarshaler.
+// This mirrors slog.LogValuer.
+type FieldMarshaler interface {
+	LogValue() interface{}
+}
+
+// maxLogValueDepth is the maximum number of nested FieldMarshaler values resolved by logValue.
+const maxLogValueDepth = 100
+
+// logValue replaces a FieldMarshaler value with the value returned by its LogValue method, repeatedly.
+func logValue(value interface{}) interface{} {
+	for i := 0; i < maxLogValueDepth; i++ {
+		m, ok := value.(FieldMarshaler)
+		if !ok {
+			return value
+		}
+		value = m.LogValue()
+	}
+	return value
+}
+
+// sortedFields returns the fields sorted by name, with the FieldMarshaler values resolved. If flatten is true,
+// the fields of groups (see Logger.WithGroup) are returned with the group names joined by dots
+// as key prefixes, e.g. "http.status"; otherwise groups are returned as Fields values.
+func sortedFields(fields Fields, flatten bool) []formatterField {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]formatterField, 0, len(names))
+	for _, name := range names {
+		value := logValue(fields[name])
+		if group, ok := value.(Fields); ok {
+			if flatten {
+				for _, f := range sortedFields(group, true) {
+					result = append(result, formatterField{name + "." + f.key, f.value})
+				}
+				continue
+			}
+			value = logValues(group)
+		}
+		result = append(result, formatterField{name, value})
+	}
+	return result
+}
+
+// logValues returns a copy of a group with the FieldMarshaler values resolved, including those of nested groups.
+func logValues(group Fields) Fields {
+	result := make(Fields, len(group))
+	for name, value := range group {
+		value = logValue(value)
+		if nested, ok := value.(Fields); ok {
+			value = logValues(nested)
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// NewJSONFormatter creates a formatter that formats a log message as a single-line JSON object.
+// Field values are encoded with encoding/json; values that cannot be encoded are rendered using fmt.Sprint.
+// The output is always valid UTF-8: the invalid bytes of the message, the keys and the values, including
+// those produced by json.Marshaler implementations, are replaced with the Unicode replacement character.
+func NewJSONFormatter(options FormatterOptions) Formatter {
+	options = options.withDefaults()
+	return func(l *Logger, e *Entry) string {
+		buf := new(bytes.Buffer)
+		buf.WriteByte('{')
+		for i, f := range options.fields(l, e, false) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(jsonValue(f.key))
+			buf.WriteByte(':')
+			buf.Write(jsonValue(f.value))
+		}
+		buf.WriteByte('}')
+		return buf.String()
+	}
+}
+
+// NewLogfmtFormatter creates a formatter that formats a log message as a logfmt line (key=value pairs).
+// Values containing spaces, quotes, equal signs or control characters are quoted.
+// The fields of groups are flattened into keys named with dots, e.g. "http.status=200". The slices, arrays and
+// other maps are encoded as JSON, e.g. "ids=[1,2,3]", including the values nested in them, so that they can
+// be parsed; since JSON strings have quotes, a value such as ["a","b"] is then quoted as a logfmt string.
+// The values providing their own string representation (errors, fmt.Stringer values and []byte) keep it.
+func NewLogfmtFormatter(options FormatterOptions) Formatter {
+	options = options.withDefaults()
+	return func(l *Logger, e *Entry) string {
+		buf := new(bytes.Buffer)
+		for _, f := range options.fields(l, e, true) {
+			if isLogfmtJSON(f.value) {
+				buf.WriteByte(' ')
+				buf.WriteString(logfmtValue(f.key))
+				buf.WriteByte('=')
+				buf.WriteString(logfmtValue(string(jsonValue(f.value))))
+			} else {
+				writeLogfmtField(buf, f.key, f.value)
+			}
+		}
+		return buf.String()[1:]
+	}
+}
+
+// isLogfmtJSON checks if a value is a slice, array or map (or a pointer to one of them) rendered as JSON
+// by the logfmt formatters. The groups are not, since their fields are flattened.
+func isLogfmtJSON(value interface{}) bool {
+	switch value.(type) {
+	case nil, error, fmt.Stringer, []byte, Fields:
+		return false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// jsonValue encodes a value as JSON, falling back to a JSON string of its fmt.Sprint representation.
+// The invalid UTF-8 bytes are replaced with the Unicode replacement character.
+func jsonValue(value interface{}) []byte {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	// encoding/json coerces strings to valid UTF-8, but copies the output of json.Marshaler implementations as is
+	if !utf8.Valid(data) {
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	}
+	return data
+}
+
+// logfmtValue quotes a logfmt key or value if needed.
+func logfmtValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,421 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newFormatterTestEntry() *Entry {
+	return &Entry{
+		Level:    LevelWarning,
+		Category: "app.db",
+		Message:  "slow query",
+		Time:     time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:   Fields{"table": "users", "ms": 120},
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	result := JSONFormatter(nil, newFormatterTestEntry())
+	expected := `{"time":"2016-01-02T03:04:05Z","level":"Warning","category":"app.db","message":"slow query","ms":120,"table":"users"}`
+	if result != expected {
+		t.Errorf("JSONFormatter() = %v, expected %v", result, expected)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(result), &m); err != nil {
+		t.Errorf("JSONFormatter() produced invalid JSON: %v", err)
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	result := LogfmtFormatter(nil, newFormatterTestEntry())
+	expected := `time=2016-01-02T03:04:05Z level=Warning category=app.db message="slow query" ms=120 table=users`
+	if result != expected {
+		t.Errorf("LogfmtFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestLogfmtFormatterSlices(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	e := &Entry{Level: LevelInfo, Category: "app", Message: "batch", Fields: Fields{
+		"ids":    []int{1, 2, 3},
+		"tags":   []string{"a", "b c"},
+		"matrix": [2][]int{{1}, {2, 3}},
+		"points": []interface{}{point{1, 2}, Fields{"z": nil}},
+		"counts": map[string]int{"ok": 2},
+		"empty":  []int{},
+		"raw":    []byte("ab"),
+		"http":   Fields{"codes": []int{200, 404}},
+	}}
+	result := LogfmtFormatter(nil, e)
+	expected := `level=Info category=app message=batch counts="{\"ok\":2}" empty=[] http.codes=[200,404] ids=[1,2,3] matrix=[[1],[2,3]] ` +
+		`points="[{\"X\":1,\"Y\":2},{\"z\":null}]" raw="[97 98]" tags="[\"a\",\"b c\"]"`
+	if result != expected {
+		t.Errorf("LogfmtFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestFormatterOptions(t *testing.T) {
+	options := FormatterOptions{
+		TimeKey:     "ts",
+		LevelKey:    "severity",
+		CategoryKey: "logger",
+		MessageKey:  "msg",
+		TimeFormat:  "2006-01-02",
+	}
+	e := newFormatterTestEntry()
+	e.Fields["msg"] = "collision"
+
+	result := NewJSONFormatter(options)(nil, e)
+	expected := `{"ts":"2016-01-02","severity":"Warning","logger":"app.db","msg":"slow query","ms":120,"fields.msg":"collision","table":"users"}`
+	if result != expected {
+		t.Errorf("NewJSONFormatter() = %v, expected %v", result, expected)
+	}
+
+	result = NewLogfmtFormatter(options)(nil, e)
+	expected = `ts=2016-01-02 severity=Warning logger=app.db msg="slow query" ms=120 fields.msg=collision table=users`
+	if result != expected {
+		t.Errorf("NewLogfmtFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestDefaultFormatter(t *testing.T) {
+	e := newFormatterTestEntry()
+	result := DefaultFormatter(nil, e)
+	expected := `2016-01-02T03:04:05Z [Warning][app.db] slow query`
+	if result != expected {
+		t.Errorf("DefaultFormatter() = %v, expected %v", result, expected)
+	}
+
+	result = NewDefaultFormatter(DefaultFormatterOptions{Fields: true})(nil, e)
+	expected = `2016-01-02T03:04:05Z [Warning][app.db] slow query ms=120 table=users`
+	if result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+
+	e.Fields = nil
+	e.CallStack = "\nmain.go:10"
+	result = DefaultFormatter(nil, e)
+	expected = "2016-01-02T03:04:05Z [Warning][app.db] slow query\nmain.go:10"
+	if result != expected {
+		t.Errorf("DefaultFormatter() = %q, expected %q", result, expected)
+	}
+}
+
+func TestDefaultFormatterCategoryAsField(t *testing.T) {
+	formatter := NewDefaultFormatter(DefaultFormatterOptions{CategoryAsField: true, CategoryKey: "logger", Fields: true})
+	result := formatter(nil, newFormatterTestEntry())
+	expected := `2016-01-02T03:04:05Z [Warning] slow query logger=app.db ms=120 table=users`
+	if result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestDefaultFormatterEmptyCategory(t *testing.T) {
+	logger := NewLogger()
+	logger.Category = ""
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("started")
+	logger.Close()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("entries = %v, expected 1", len(target.entries))
+	}
+	result := target.entries[0].FormattedMessage
+	if strings.Contains(result, "[]") || !strings.HasSuffix(result, " [Info] started") {
+		t.Errorf("FormattedMessage = %q, expected no category brackets", result)
+	}
+
+	formatter := NewDefaultFormatter(DefaultFormatterOptions{CategoryAsField: true})
+	e := newFormatterTestEntry()
+	e.Category = ""
+	expected := `2016-01-02T03:04:05Z [Warning] slow query`
+	if result := formatter(nil, e); result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestDefaultFormatterLevelPrefix(t *testing.T) {
+	formatter := NewDefaultFormatter(DefaultFormatterOptions{LevelPrefix: true})
+	result := formatter(nil, newFormatterTestEntry())
+	expected := `WARNING: 2016-01-02T03:04:05Z [Warning][app.db] slow query`
+	if result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestDefaultFormatterTimePrecision(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Time = e.Time.Add(120 * time.Millisecond)
+	tests := []struct {
+		precision int
+		expected  string
+	}{
+		{0, "2016-01-02T03:04:05Z"},
+		{-1, "2016-01-02T03:04:05Z"},
+		{3, "2016-01-02T03:04:05.120Z"},
+		{6, "2016-01-02T03:04:05.120000Z"},
+		{12, "2016-01-02T03:04:05.120000000Z"},
+	}
+	for _, test := range tests {
+		result := NewDefaultFormatter(DefaultFormatterOptions{TimePrecision: test.precision})(nil, e)
+		if expected := test.expected + " [Warning][app.db] slow query"; result != expected {
+			t.Errorf("NewDefaultFormatter(%v) = %v, expected %v", test.precision, result, expected)
+		}
+	}
+}
+
+func TestDefaultFormatterRelativeTime(t *testing.T) {
+	formatter := NewDefaultFormatter(DefaultFormatterOptions{RelativeTime: true})
+	l1, l2 := NewLogger(), NewLogger()
+	e := newFormatterTestEntry()
+	tests := []struct {
+		logger   *Logger
+		elapsed  time.Duration
+		expected string
+	}{
+		{l1, 0, "+0s"},
+		{l1, 1200 * time.Millisecond, "+1.2s"},
+		{l2, 1500 * time.Millisecond, "+0s"},
+		{l1.GetLogger("db"), 1515 * time.Millisecond, "+315ms"},
+		{l1, 1515*time.Millisecond + 20*time.Microsecond, "+20µs"},
+		{l1, 1000 * time.Millisecond, "+0s"},
+	}
+	for i, test := range tests {
+		e.Time = newFormatterTestEntry().Time.Add(test.elapsed)
+		result := formatter(test.logger, e)
+		if expected := test.expected + " [Warning][app.db] slow query"; result != expected {
+			t.Errorf("%v: formatter() = %v, expected %v", i, result, expected)
+		}
+	}
+}
+
+func TestLevelFormatter(t *testing.T) {
+	logger := NewLogger()
+	logger.LevelFormatter = func(level Level) string {
+		return fmt.Sprintf("%-5v", strings.ToUpper(level.String()))
+	}
+	l := logger.GetLogger("app.db")
+	e := newFormatterTestEntry()
+	e.Time = time.Time{}
+	e.Fields = nil
+	tests := []struct {
+		formatter Formatter
+		expected  string
+	}{
+		{DefaultFormatter, "[WARNING][app.db] slow query"},
+		{NewDefaultFormatter(DefaultFormatterOptions{LevelPrefix: true}), "WARNING: [WARNING][app.db] slow query"},
+		{JSONFormatter, `{"level":"WARNING","category":"app.db","message":"slow query"}`},
+		{LogfmtFormatter, `level=WARNING category=app.db message="slow query"`},
+	}
+	for i, test := range tests {
+		if result := test.formatter(l, e); result != test.expected {
+			t.Errorf("%v: formatter() = %v, expected %v", i, result, test.expected)
+		}
+	}
+	e.Level = LevelInfo
+	if result := DefaultFormatter(l, e); result != "[INFO ][app.db] slow query" {
+		t.Errorf("DefaultFormatter() = %v, expected %v", result, "[INFO ][app.db] slow query")
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	fields := Fields{"user": "bob", "n": 2, "http": Fields{"status": 200}}
+	tests := []struct {
+		message, expected string
+	}{
+		{"login by {user}", "login by bob"},
+		{"{user}: {n} of {total} {http.status}", "bob: 2 of {total} 200"},
+		{"{user", "{user"},
+		{"{} {http}x", "{} map[status:200]x"},
+		{"no tokens", "no tokens"},
+	}
+	for _, test := range tests {
+		if result := Interpolate(test.message, fields); result != test.expected {
+			t.Errorf("Interpolate(%q) = %q, expected %q", test.message, result, test.expected)
+		}
+	}
+
+	e := newFormatterTestEntry()
+	e.Message = "slow query on {table}"
+	result := NewJSONFormatter(FormatterOptions{Interpolate: true})(nil, e)
+	expected := `{"time":"2016-01-02T03:04:05Z","level":"Warning","category":"app.db","message":"slow query on users","ms":120,"table":"users"}`
+	if result != expected {
+		t.Errorf("NewJSONFormatter() = %v, expected %v", result, expected)
+	}
+	result = NewDefaultFormatter(DefaultFormatterOptions{Interpolate: true})(nil, e)
+	expected = `2016-01-02T03:04:05Z [Warning][app.db] slow query on users`
+	if result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestJSONFormatterSeq(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Fields = nil
+	e.Seq = 42
+	result := JSONFormatter(nil, e)
+	expected := `{"time":"2016-01-02T03:04:05Z","level":"Warning","category":"app.db","message":"slow query","seq":42}`
+	if result != expected {
+		t.Errorf("JSONFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestJSONFormatterZeroTime(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Fields = Fields{"time": "later"}
+	e.Time = time.Time{}
+	result := JSONFormatter(nil, e)
+	expected := `{"level":"Warning","category":"app.db","message":"slow query","fields.time":"later"}`
+	if result != expected {
+		t.Errorf("JSONFormatter() = %v, expected %v", result, expected)
+	}
+}
+
+func TestFormatterOptionsOmitEmpty(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Time = time.Time{}
+	e.Fields = Fields{"nil": nil, "empty": "", "zero": 0, "false": false, "ok": "yes", "group": Fields{"nil": nil}}
+	tests := []struct {
+		options  FormatterOptions
+		expected string
+	}{
+		{FormatterOptions{}, `{"level":"Warning","category":"app.db","message":"slow query","empty":"","false":false,"group":{"nil":null},"nil":null,"ok":"yes","zero":0}`},
+		{FormatterOptions{OmitEmpty: true}, `{"level":"Warning","category":"app.db","message":"slow query","false":false,"ok":"yes","zero":0}`},
+		{FormatterOptions{OmitZero: true}, `{"level":"Warning","category":"app.db","message":"slow query","ok":"yes"}`},
+	}
+	for _, test := range tests {
+		if result := NewJSONFormatter(test.options)(nil, e); result != test.expected {
+			t.Errorf("NewJSONFormatter(%+v) = %v, expected %v", test.options, result, test.expected)
+		}
+	}
+	result := NewLogfmtFormatter(FormatterOptions{OmitEmpty: true})(nil, e)
+	expected := `level=Warning category=app.db message="slow query" false=false ok=yes zero=0`
+	if result != expected {
+		t.Errorf("NewLogfmtFormatter() = %v, expected %v", result, expected)
+	}
+	if len(e.Fields) != 6 {
+		t.Errorf("the entry fields were modified: %v", e.Fields)
+	}
+}
+
+func TestFormatterLocation(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Fields = nil
+	plus2 := time.FixedZone("", 2*60*60)
+	tests := []struct {
+		formatter Formatter
+		expected  string
+	}{
+		{NewDefaultFormatter(DefaultFormatterOptions{Location: time.UTC}), `2016-01-02T03:04:05Z [Warning][app.db] slow query`},
+		{NewDefaultFormatter(DefaultFormatterOptions{Location: plus2}), `2016-01-02T05:04:05+02:00 [Warning][app.db] slow query`},
+		{NewJSONFormatter(FormatterOptions{Location: plus2}), `{"time":"2016-01-02T05:04:05+02:00","level":"Warning","category":"app.db","message":"slow query"}`},
+		{NewLogfmtFormatter(FormatterOptions{Location: time.UTC}), `time=2016-01-02T03:04:05Z level=Warning category=app.db message="slow query"`},
+	}
+	for i, test := range tests {
+		if result := test.formatter(nil, e); result != test.expected {
+			t.Errorf("%v: formatter() = %v, expected %v", i, result, test.expected)
+		}
+	}
+	if e.Time.Location() != time.UTC {
+		t.Errorf("the entry time was modified: %v", e.Time)
+	}
+}
+
+type invalidUTF8Marshaler struct{}
+
+func (invalidUTF8Marshaler) MarshalJSON() ([]byte, error) {
+	return []byte("\"raw\xff\""), nil
+}
+
+func TestJSONFormatterInvalidUTF8(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Message = "bad \xff\xfe bytes"
+	e.Fields = Fields{"key\xc3": "value\x80", "custom": invalidUTF8Marshaler{}, "err": errors.New("failed \xff")}
+	result := JSONFormatter(nil, e)
+	if !utf8.ValidString(result) || !json.Valid([]byte(result)) {
+		t.Fatalf("JSONFormatter() = %q, expected valid UTF-8 JSON", result)
+	}
+	var m map[string]interface{}
+	json.Unmarshal([]byte(result), &m)
+	expected := map[string]interface{}{"message": "bad \uFFFD\uFFFD bytes", "key\uFFFD": "value\uFFFD", "custom": "raw\uFFFD", "err": "failed \uFFFD"}
+	for key, value := range expected {
+		if m[key] != value {
+			t.Errorf("%v = %q, expected %q", key, m[key], value)
+		}
+	}
+}
+
+type formatterTestNode struct {
+	Name string
+	Next *formatterTestNode `json:",omitempty"`
+}
+
+func TestDefaultFormatterNestedJSON(t *testing.T) {
+	e := newFormatterTestEntry()
+	e.Fields = Fields{
+		"ids":  []int{1, 2},
+		"node": &formatterTestNode{Name: "a"},
+		"map":  map[string]int{"x": 1},
+		"time": time.Duration(0),
+	}
+	result := NewDefaultFormatter(DefaultFormatterOptions{Fields: true, NestedJSON: true})(nil, e)
+	expected := `2016-01-02T03:04:05Z [Warning][app.db] slow query ids=[1,2] map={"x":1} node={"Name":"a"} time=0s`
+	if result != expected {
+		t.Errorf("NewDefaultFormatter() = %v, expected %v", result, expected)
+	}
+
+	// cyclic references must not cause infinite recursion
+	node := &formatterTestNode{Name: "a"}
+	node.Next = node
+	e.Fields = Fields{"node": node}
+	result = NewDefaultFormatter(DefaultFormatterOptions{Fields: true, NestedJSON: true})(nil, e)
+	if !strings.Contains(result, "node=\"!(json: unsupported value") {
+		t.Errorf("NewDefaultFormatter() = %v, expected an encoding error for the cyclic value", result)
+	}
+}
+
+type formatterTestUUID [4]byte
+
+func (id formatterTestUUID) LogValue() interface{} {
+	return fmt.Sprintf("%x-%x", id[:2], id[2:])
+}
+
+type formatterTestUser struct {
+	id   formatterTestUUID
+	name string
+}
+
+func (u formatterTestUser) LogValue() interface{} {
+	return Fields{"id": u.id, "name": u.name}
+}
+
+func TestFieldMarshaler(t *testing.T) {
+	id := formatterTestUUID{0xde, 0xad, 0xbe, 0xef}
+	e := newFormatterTestEntry()
+	e.Message = "hello {user.name}"
+	e.Fields = Fields{"id": id, "user": formatterTestUser{id, "bob"}}
+
+	expected := `{"time":"2016-01-02T03:04:05Z","level":"Warning","category":"app.db","message":"hello bob","id":"dead-beef","user":{"id":"dead-beef","name":"bob"}}`
+	if result := NewJSONFormatter(FormatterOptions{Interpolate: true})(nil, e); result != expected {
+		t.Errorf("JSONFormatter() = %v, expected %v", result, expected)
+	}
+	expected = `time=2016-01-02T03:04:05Z level=Warning category=app.db message="slow query" id=dead-beef user.id=dead-beef user.name=bob`
+	e.Message = "slow query"
+	if result := LogfmtFormatter(nil, e); result != expected {
+		t.Errorf("LogfmtFormatter() = %v, expected %v", result, expected)
+	}
+}
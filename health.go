@@ -0,0 +1,44 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Healthy reports whether the logging subsystem works, which is cheap enough to be called by readiness probes.
+// It returns false if a target implementing FailureReporter (such as ConsoleTarget, FileTarget, NetworkTarget,
+// JournaldTarget, or an AsyncTarget wrapping one of them) has failed to write at least HealthFailureStreak
+// messages in a row, or if the entry channel of the logger (see BufferSize) has stayed full for at least
+// HealthSaturation, which means that the targets cannot keep up and the log calls block.
+// A target recovers once it writes a message again, and the logger once its channel is no longer full.
+func (l *coreLogger) Healthy() bool {
+	if l.HealthFailureStreak > 0 {
+		for _, target := range l.Targets {
+			if reporter, ok := target.(FailureReporter); ok && reporter.FailureStreak() >= l.HealthFailureStreak {
+				return false
+			}
+		}
+	}
+	if l.HealthSaturation > 0 && l.isOpen() && cap(l.entries) > 0 && len(l.entries) == cap(l.entries) {
+		if since := atomic.LoadInt64(&l.fullAt); since != 0 && time.Since(time.Unix(0, since)) >= l.HealthSaturation {
+			return false
+		}
+	}
+	return true
+}
+
+// observeSaturation records the time since which the entry channel has been full before an entry is sent.
+// An unbuffered channel is never considered full.
+func (l *coreLogger) observeSaturation() {
+	if cap(l.entries) == 0 || len(l.entries) < cap(l.entries) {
+		if atomic.LoadInt64(&l.fullAt) != 0 {
+			atomic.StoreInt64(&l.fullAt, 0)
+		}
+		return
+	}
+	atomic.CompareAndSwapInt64(&l.fullAt, 0, time.Now().UnixNano())
+}
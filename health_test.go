@@ -0,0 +1,111 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type toggleWriter struct {
+	fail bool
+}
+
+func (w *toggleWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestLoggerHealthyFailureStreak(t *testing.T) {
+	writer := &toggleWriter{fail: true}
+	target := NewConsoleTarget()
+	target.Writer = writer
+	target.Open(nil)
+	logger := NewLogger()
+	logger.HealthFailureStreak = 3
+	logger.Targets = append(logger.Targets, NewAsyncTarget(target))
+
+	e := &Entry{Level: LevelError, FormattedMessage: "failed"}
+	for i := 0; i < 2; i++ {
+		target.Process(e)
+	}
+	if !logger.Healthy() {
+		t.Errorf("logger.Healthy() = false after %v failures, expected true", 2)
+	}
+	target.Process(e)
+	if logger.Healthy() {
+		t.Errorf("logger.Healthy() = true after %v failures, expected false", 3)
+	}
+	writer.fail = false
+	target.Process(e)
+	if !logger.Healthy() {
+		t.Errorf("logger.Healthy() = false after the target recovered, expected true")
+	}
+	if target.FailureStreak() != 0 {
+		t.Errorf("target.FailureStreak() = %v, expected 0", target.FailureStreak())
+	}
+}
+
+type blockingTarget struct {
+	release chan bool
+	done    chan bool
+}
+
+func (t *blockingTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *blockingTarget) Process(e *Entry) {
+	if e == nil {
+		t.done <- true
+		return
+	}
+	<-t.release
+}
+
+func (t *blockingTarget) Close() {
+	<-t.done
+}
+
+func TestLoggerHealthySaturation(t *testing.T) {
+	logger := NewLogger()
+	logger.BufferSize = 1
+	logger.HealthSaturation = 50 * time.Millisecond
+	target := &blockingTarget{release: make(chan bool), done: make(chan bool)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	if !logger.Healthy() {
+		t.Errorf("logger.Healthy() = false before logging, expected true")
+	}
+
+	logged := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			logger.Info("t%v", i)
+		}
+		close(logged)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logger.Healthy() {
+		t.Errorf("logger.Healthy() = true while the channel is full, expected false")
+	}
+
+	close(target.release)
+	<-logged
+	for !logger.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !logger.Healthy() {
+		t.Errorf("logger.Healthy() = false after the channel was drained, expected true")
+	}
+	logger.Close()
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPStatusLevel returns the level of an HTTP request logged by Logger.HTTPRequest:
+// LevelError for 5xx statuses, LevelWarning for 4xx statuses and LevelInfo otherwise.
+func HTTPStatusLevel(status int) Level {
+	switch {
+	case status >= 500:
+		return LevelError
+	case status >= 400:
+		return LevelWarning
+	}
+	return LevelInfo
+}
+
+// HTTPRequest logs a handled HTTP request as an access log entry such as "GET /users 200", with the fields
+// "method", "path", "protocol", "status", "duration", "remote_addr", "referer" and "user_agent". The level is
+// chosen from the status by Logger.HTTPStatusLevel, which defaults to HTTPStatusLevel. The entries can be
+// rendered as access log lines by CommonLogFormatter and CombinedLogFormatter.
+//
+// The request-scoped fields extracted from the request context (see WithContextKeys) are added as well,
+// so there is no need to call WithContext(r.Context()) first. Like the fields added by WithFields,
+// all the fields are nested in the groups of the logger, if any.
+func (l *Logger) HTTPRequest(r *http.Request, status int, dur time.Duration) {
+	statusLevel := l.HTTPStatusLevel
+	if statusLevel == nil {
+		statusLevel = HTTPStatusLevel
+	}
+	level := statusLevel(status)
+	if l.discards(level) || !l.accepting() {
+		return
+	}
+	l.WithContext(r.Context()).WithFields(Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"protocol":    r.Proto,
+		"status":      status,
+		"duration":    dur,
+		"remote_addr": r.RemoteAddr,
+		"referer":     r.Referer(),
+		"user_agent":  r.UserAgent(),
+	}).log(3, level, "%v %v %v", r.Method, r.URL.Path, status)
+}
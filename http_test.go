@@ -0,0 +1,89 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerHTTPRequest(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	r := httptest.NewRequest("GET", "/users?id=1", nil)
+	r.Header.Set("User-Agent", "test")
+	r = r.WithContext(context.WithValue(r.Context(), testContextKey("request_id"), "r1"))
+	l := logger.WithContextKeys(testContextKey("request_id"))
+	l.HTTPRequest(r, 200, time.Second)
+	l.HTTPRequest(r, 404, time.Second)
+	l.HTTPRequest(r, 503, time.Second)
+	l.HTTPStatusLevel = func(status int) Level { return LevelDebug }
+	l.HTTPRequest(r, 503, time.Second)
+	logger.Close()
+
+	if len(target.entries) != 4 {
+		t.Fatalf("got %v entries, expected 4", len(target.entries))
+	}
+	levels := []Level{LevelInfo, LevelWarning, LevelError, LevelDebug}
+	for i, e := range target.entries {
+		if e.Level != levels[i] {
+			t.Errorf("entry %v level = %v, expected %v", i, e.Level, levels[i])
+		}
+		if !strings.Contains(e.CallStack, "http_test.go") {
+			t.Errorf("entry %v call stack = %q, expected the caller of HTTPRequest", i, e.CallStack)
+		}
+	}
+	e := target.entries[0]
+	if e.Message != "GET /users 200" {
+		t.Errorf("entry.Message = %q, expected %q", e.Message, "GET /users 200")
+	}
+	expected := "map[duration:1s method:GET path:/users protocol:HTTP/1.1 referer: remote_addr:192.0.2.1:1234 request_id:r1 status:200 user_agent:test]"
+	if fields := fmt.Sprint(e.Fields); fields != expected {
+		t.Errorf("entry.Fields = %v, expected %v", fields, expected)
+	}
+}
+
+func TestAccessLogFormatters(t *testing.T) {
+	e := &Entry{
+		Time: time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		Fields: Fields{
+			"remote_addr": "192.0.2.1:1234",
+			"method":      "GET",
+			"path":        `/a"b`,
+			"protocol":    "HTTP/1.1",
+			"status":      200,
+			"bytes":       2326,
+			"referer":     "",
+			"user_agent":  "Mozilla/5.0",
+		},
+	}
+	tests := []struct {
+		formatter Formatter
+		entry     *Entry
+		expected  string
+	}{
+		{CommonLogFormatter, e, `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a\"b HTTP/1.1" 200 2326`},
+		{CombinedLogFormatter, e, `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a\"b HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`},
+		{CombinedLogFormatter, &Entry{}, `- - - - "- - -" - - "-" "-"`},
+	}
+	for i, test := range tests {
+		if result := test.formatter(nil, test.entry); result != test.expected {
+			t.Errorf("%v: formatter() = %v, expected %v", i, result, test.expected)
+		}
+	}
+	if GetFormatter("combined") == nil {
+		t.Errorf("the combined formatter is not registered")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package batching is shared by the targets sending the log entries in batches, such as log.AckTarget,
+// log.SQLTarget, log.OTLPTarget and cloudwatchlog.Target. It does not depend on the log package, which imports it,
+// so it is parameterized by the type of the entries.
+package batching
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Run receives the entries queued by a target until the nil entry closing the target is received.
+// It calls add for every entry, which may send the batch when it is full, and flush every interval
+// (unless the interval is not positive) and once more before returning, which sends the partial batch.
+func Run[E any](entries <-chan *E, interval time.Duration, add func(*E), flush func()) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case e := <-entries:
+			if e == nil {
+				flush()
+				return
+			}
+			add(e)
+		case <-tick:
+			flush()
+		}
+	}
+}
+
+// Drops counts the items of the batches that a target could not deliver and reports them to Writer.
+type Drops struct {
+	Count  int       // the number of items not delivered
+	Writer io.Writer // the error writer of the logger
+}
+
+// Drop records a batch of n items that could not be delivered because of err,
+// e.g. `SQLTarget dropped a batch of 10 entries: connection refused`.
+func (d *Drops) Drop(label string, n int, items string, err error) {
+	d.Count += n
+	fmt.Fprintf(d.Writer, "%v dropped a batch of %v %v: %v\n", label, n, items, err)
+}
+
+// Err returns the error of a target closed after failing to deliver some items,
+// e.g. `OTLPTarget was unable to deliver 10 entries`, or nil if all were delivered.
+func (d *Drops) Err(label, verb, items string) error {
+	if d.Count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v was unable to %v %v %v", label, verb, d.Count, items)
+}
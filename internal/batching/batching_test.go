@@ -0,0 +1,53 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package batching
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	entries := make(chan *int, 10)
+	for i := 1; i <= 5; i++ {
+		n := i
+		entries <- &n
+	}
+	entries <- nil
+
+	var batch []int
+	var batches [][]int
+	flush := func() {
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+		batch = nil
+	}
+	Run(entries, 0, func(n *int) {
+		if batch = append(batch, *n); len(batch) == 2 {
+			flush()
+		}
+	}, flush)
+
+	if len(batches) != 3 || len(batches[2]) != 1 || batches[2][0] != 5 {
+		t.Errorf("batches = %v, expected [[1 2] [3 4] [5]]", batches)
+	}
+}
+
+func TestDrops(t *testing.T) {
+	var buf bytes.Buffer
+	d := Drops{Writer: &buf}
+	if err := d.Err("SQLTarget", "insert", "entries"); err != nil {
+		t.Errorf("Err() = %v, expected nil", err)
+	}
+	d.Drop("SQLTarget", 3, "entries", errors.New("connection refused"))
+	if buf.String() != "SQLTarget dropped a batch of 3 entries: connection refused\n" {
+		t.Errorf("Writer = %q", buf.String())
+	}
+	if err := d.Err("SQLTarget", "insert", "entries"); err == nil || err.Error() != "SQLTarget was unable to insert 3 entries" {
+		t.Errorf("Err() = %v", err)
+	}
+}
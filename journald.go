@@ -0,0 +1,187 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JournaldTarget sends log messages to the systemd journal using its native protocol.
+// Each message is sent with a PRIORITY matching its severity level, a CATEGORY field
+// holding the message category, and the entry fields as additional journal fields.
+// Field names are uppercased and characters not allowed by the journal are replaced with "_",
+// and the fields of groups are named after the group path, e.g. "HTTP_STATUS". Fields whose names
+// collide with the fields set by the target (MESSAGE, PRIORITY, CATEGORY and SYSLOG_IDENTIFIER)
+// are prefixed with "FIELDS_", so that they do not duplicate or override them.
+//
+// Each message is sent as a single datagram; the memfd fallback used by sd_journal_send for messages
+// larger than the socket allows is not supported, so sending an oversized message fails (with EMSGSIZE)
+// and the message is reported to the logger's ErrorWriter. Use Logger.MaxMessageLen and Logger.MaxFieldLen
+// to keep the messages within the limit.
+type JournaldTarget struct {
+	*Filter
+	Name       string            // the name identifying the target in diagnostics
+	Tags       map[string]string // the tags identifying the target in diagnostics
+	SocketPath string            // the journal socket path. Defaults to "/run/systemd/journal/socket".
+	Identifier string            // the SYSLOG_IDENTIFIER of the messages. Defaults to the program name.
+
+	conn      *net.UnixConn
+	errWriter io.Writer
+	closeErr  error
+	failures  writeFailures
+	close     chan bool
+}
+
+// NewJournaldTarget creates a JournaldTarget.
+// The new JournaldTarget takes these default options:
+// MaxLevel: LevelDebug, SocketPath: "/run/systemd/journal/socket", Identifier: the program name
+func NewJournaldTarget() *JournaldTarget {
+	return &JournaldTarget{
+		Filter:     &Filter{MaxLevel: LevelDebug},
+		SocketPath: "/run/systemd/journal/socket",
+		Identifier: filepath.Base(os.Args[0]),
+		close:      make(chan bool, 0),
+	}
+}
+
+// Open connects to the journal socket.
+// An error is returned if the journal is not available, so that a different target may be used instead.
+func (t *JournaldTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.SocketPath == "" {
+		return errors.New("JournaldTarget.SocketPath must be specified")
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: t.SocketPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("%v was unable to connect to the journal: %v", t.label(), err)
+	}
+	t.conn = conn
+	t.errWriter = errWriter
+	t.failures = writeFailures{}
+	return nil
+}
+
+// Process sends an allowed log message to the journal.
+func (t *JournaldTarget) Process(e *Entry) {
+	if e == nil {
+		err := t.conn.Close()
+		if err != nil {
+			err = fmt.Errorf("%v was unable to close the connection: %v", t.label(), err)
+		}
+		t.closeErr = joinErrors(t.failures.err(t.label()), err)
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	if _, err := t.conn.Write(t.encode(e)); err != nil {
+		t.failures.add(err)
+		fmt.Fprintf(t.errWriter, "%v write error: %v\n", t.label(), err)
+	} else {
+		t.failures.ok()
+	}
+}
+
+// Close closes the journald target.
+func (t *JournaldTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the journald target and returns the errors occurred when sending messages
+// or when closing the connection.
+func (t *JournaldTarget) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// FailureStreak returns the number of consecutive messages that could not be sent to the journal.
+func (t *JournaldTarget) FailureStreak() int {
+	return t.failures.failureStreak()
+}
+
+// encode serializes a log entry using the journal native protocol.
+func (t *JournaldTarget) encode(e *Entry) []byte {
+	buf := new(bytes.Buffer)
+	writeJournalField(buf, "MESSAGE", e.Message+e.CallStack)
+	// RFC5424 levels share their numeric values with syslog priorities, to which custom levels are clamped
+	priority := e.Level
+	if priority < LevelEmergency {
+		priority = LevelEmergency
+	} else if priority > LevelDebug {
+		priority = LevelDebug
+	}
+	writeJournalField(buf, "PRIORITY", fmt.Sprint(int(priority)))
+	writeJournalField(buf, "CATEGORY", e.Category)
+	if t.Identifier != "" {
+		writeJournalField(buf, "SYSLOG_IDENTIFIER", t.Identifier)
+	}
+	for _, f := range sortedFields(e.Fields, true) {
+		name := journalFieldName(f.key)
+		if name == "" {
+			continue
+		}
+		if journalReservedFields[name] {
+			name = "FIELDS_" + name
+		}
+		writeJournalField(buf, name, fmt.Sprint(f.value))
+	}
+	return buf.Bytes()
+}
+
+// journalReservedFields are the journal fields set by JournaldTarget.
+var journalReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"CATEGORY":          true,
+	"SYSLOG_IDENTIFIER": true,
+}
+
+// writeJournalField writes a single field. Values containing newlines use the binary-safe form.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts a field name into a valid journal field name.
+// Journal field names may only contain uppercase letters, digits and underscores,
+// and must not start with an underscore or a digit.
+func journalFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.TrimLeft(name, "_0123456789")
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *JournaldTarget) Describe() TargetInfo {
+	return newTargetInfo("JournaldTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *JournaldTarget) label() string {
+	return targetLabel("JournaldTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewJournaldTarget(t *testing.T) {
+	target := NewJournaldTarget()
+	if target.MaxLevel != LevelDebug {
+		t.Errorf("NewJournaldTarget.MaxLevel = %v, expected %v", target.MaxLevel, LevelDebug)
+	}
+	if target.SocketPath != "/run/systemd/journal/socket" {
+		t.Errorf("NewJournaldTarget.SocketPath = %v, expected %v", target.SocketPath, "/run/systemd/journal/socket")
+	}
+}
+
+func TestJournaldTargetOpenError(t *testing.T) {
+	target := NewJournaldTarget()
+	target.SocketPath = filepath.Join(os.TempDir(), "ozzo-log-no-such-journal")
+	if err := target.Open(os.Stderr); err == nil {
+		t.Errorf("JournaldTarget.Open() should fail when the journal socket is missing")
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"user", "USER"},
+		{"request-id", "REQUEST_ID"},
+		{"_private", "PRIVATE"},
+		{"1st", "ST"},
+	}
+	for _, test := range tests {
+		if name := journalFieldName(test.name); name != test.expected {
+			t.Errorf("journalFieldName(%q) = %q, expected %q", test.name, name, test.expected)
+		}
+	}
+}
+
+func TestJournaldTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram is not supported: %v", err)
+	}
+	defer server.Close()
+
+	logger := NewLogger()
+	target := NewJournaldTarget()
+	target.SocketPath = path
+	target.Identifier = "test"
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.WithFields(Fields{"user": "bob", "message": "dup", "http": Fields{"status": 200}}).Warning("a\nb")
+	logger.Close()
+
+	buf := make([]byte, 1024)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("server.Read(): %v", err)
+	}
+	result := string(buf[:n])
+	for _, expected := range []string{"MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n", "PRIORITY=4\n", "CATEGORY=app\n", "SYSLOG_IDENTIFIER=test\n", "USER=bob\n", "FIELDS_MESSAGE=dup\n", "HTTP_STATUS=200\n"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %q not found in %q", expected, result)
+		}
+	}
+}
@@ -12,9 +12,13 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // RFC5424 log message levels.
@@ -52,11 +56,45 @@ func (l Level) String() string {
 	return "Unknown"
 }
 
+// RegisterLevel registers a custom level with the given numeric value and name, in addition to the RFC5424 levels.
+// Like those, lower values are more severe: a negative value is more severe than LevelEmergency, and a value above
+// LevelDebug is less severe than LevelDebug (so it is only logged if MaxLevel is raised accordingly).
+// The filters of the targets allow the negative levels unless their MostSevereLevel is set.
+// Messages of the new level can be logged via Logger.Log. For example,
+//
+//	LevelSecurity, _ := log.RegisterLevel(-1, "Security")
+//	logger.Log(LevelSecurity, "user %v was locked out", user)
+//
+// An error is returned if the value or the name (compared case-insensitively) is already registered.
+// RegisterLevel should be called during initialization, before any message is logged.
+func RegisterLevel(value int, name string) (Level, error) {
+	level := Level(value)
+	if existing, ok := LevelNames[level]; ok {
+		return level, fmt.Errorf("the level value %v is already registered as %q", value, existing)
+	}
+	if _, err := LevelFromString(name); err == nil {
+		return level, fmt.Errorf("the level name %q is already registered", name)
+	}
+	LevelNames[level] = name
+	return level, nil
+}
+
+// LevelFromString returns the level registered with the given name, which is compared case-insensitively.
+func LevelFromString(name string) (Level, error) {
+	for level, n := range LevelNames {
+		if strings.EqualFold(n, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level name %q", name)
+}
+
 // Fields is a map for custom fields or parameters
 type Fields map[string]interface{}
 
 // Entry represents a log entry.
 type Entry struct {
+	Seq       uint64 // the sequence number of the entry, starting from 1 for each root logger
 	Level     Level
 	Category  string
 	Message   string
@@ -70,6 +108,7 @@ type Entry struct {
 
 func (e *Entry) Dup() *Entry {
 	ret := &Entry{
+		Seq:              e.Seq,
 		Level:            e.Level,
 		Category:         e.Category,
 		Message:          e.Message,
@@ -105,6 +144,8 @@ type Target interface {
 	// errWriter should be used to write errors found while processing log messages.
 	Open(errWriter io.Writer) error
 	// Process processes an incoming log message.
+	// Unless the target implements ProcessCloser, Process is called with nil after the last message
+	// when the logger is closed.
 	Process(*Entry)
 	// Close closes a target.
 	// Close is called when Logger.Close() is called, which gives each target
@@ -112,42 +153,275 @@ type Target interface {
 	Close()
 }
 
+// targetLabel returns the description of a target used in diagnostics,
+// such as `NetworkTarget "audit-sink" [env=prod]`. The tags are sorted by name.
+func targetLabel(kind, name string, tags map[string]string) string {
+	label := kind
+	if name != "" {
+		label += fmt.Sprintf(" %q", name)
+	}
+	if len(tags) > 0 {
+		pairs := make([]string, 0, len(tags))
+		for k, v := range tags {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		label += " [" + strings.Join(pairs, " ") + "]"
+	}
+	return label
+}
+
+// ProcessCloser is implemented by targets notified of the close of the logger by an explicit call to ProcessClose
+// instead of the Process(nil) call received by the other targets. The logger (and AsyncTarget) then never call
+// Process with nil, so the target does not have to tell a close signal from an entry.
+//
+// The existing targets keep working unchanged. To migrate one, move the code handling the nil entry in Process
+// to ProcessClose, and keep Process(nil) calling it if the target may be used by code sending the nil entry.
+// The built-in targets still rely on Process(nil), so that the custom targets embedding them and overriding
+// Process keep receiving it. Note that a target embedding one implementing ProcessCloser implements it as well.
+type ProcessCloser interface {
+	// ProcessClose is called by the goroutine processing the entries after the last entry has been passed
+	// to Process, before Close is called. It should flush and release the resources of the target.
+	ProcessClose()
+}
+
+// processClose notifies a target of the close of the logger with ProcessClose, or with Process(nil)
+// if it does not implement ProcessCloser.
+func processClose(target Target) {
+	if closer, ok := target.(ProcessCloser); ok {
+		closer.ProcessClose()
+	} else {
+		target.Process(nil)
+	}
+}
+
+// ErrorCloser is implemented by targets that can report errors occurred while being closed,
+// such as failing to flush buffered messages or to release the underlying resources.
+// Like Target.Close, CloseError must wait until the target finishes processing the log messages.
+type ErrorCloser interface {
+	CloseError() error
+}
+
+// FailureReporter is implemented by targets reporting their failures to write log messages, which Logger.Healthy
+// checks. FailureStreak returns the number of consecutive failures, i.e. 0 if the last message was written, and
+// must be safe to call concurrently with Process.
+type FailureReporter interface {
+	FailureStreak() int
+}
+
+// bufferedTarget is implemented by targets queuing the log entries in a buffer of their own, such as NetworkTarget.
+// Logger.Open passes them Logger.BufferSize, which they use when their own BufferSize is 0.
+type bufferedTarget interface {
+	setDefaultBufferSize(size int)
+}
+
+// Errors represents the errors reported by multiple targets.
+type Errors []error
+
+// Error returns the error messages joined by semicolons.
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, err := range es {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// joinErrors returns nil if there is no non-nil error, the error itself if there is one, or Errors otherwise.
+func joinErrors(errs ...error) error {
+	var result Errors
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	switch len(result) {
+	case 0:
+		return nil
+	case 1:
+		return result[0]
+	}
+	return result
+}
+
+// writeFailures records the failures of a target writing log messages, so that they can be reported by CloseError
+// and by FailureReporter.
+type writeFailures struct {
+	count  int
+	first  error
+	streak int32 // the number of consecutive failures. Accessed atomically.
+}
+
+// add records a write failure.
+func (w *writeFailures) add(err error) {
+	if w.count == 0 {
+		w.first = err
+	}
+	w.count++
+	atomic.AddInt32(&w.streak, 1)
+}
+
+// ok records a successful write, which ends the streak of failures.
+func (w *writeFailures) ok() {
+	atomic.StoreInt32(&w.streak, 0)
+}
+
+// failureStreak returns the number of consecutive failures.
+func (w *writeFailures) failureStreak() int {
+	return int(atomic.LoadInt32(&w.streak))
+}
+
+// err returns an error summarizing the recorded failures, or nil if there is none.
+func (w *writeFailures) err(label string) error {
+	if w.count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v failed to write %v message(s), the first error: %v", label, w.count, w.first)
+}
+
 // coreLogger maintains the log messages in a channel and sends them to various targets.
 type coreLogger struct {
-	lock    sync.Mutex
-	open    bool        // whether the logger is open
-	entries chan *Entry // log entries
+	seq      uint64         // the sequence number of the last log entry. Must be the first field for 64-bit alignment.
+	lastTime int64          // the time of the last entry formatted with a relative time, in Unix nanoseconds. Accessed atomically.
+	fullAt   int64          // the time since which the entry channel has been found full, in Unix nanoseconds, or 0. Accessed atomically.
+	lock     sync.Mutex     // serializes Open, Close, HandleSignals, RemapLevel, SetCategoryFormatter, SetCategoryCallStackDepth and Use
+	sendLock sync.RWMutex   // held for reading while sending an entry and for writing while closing
+	open     int32          // 1 if the logger is open, 2 if it is closed. Accessed atomically.
+	entries  chan *Entry    // log entries
+	signals  chan os.Signal // signals handled by HandleSignals
+	remaps   atomic.Value   // the []levelRemap rules added by RemapLevel
+	formats  atomic.Value   // the []categoryFormatter formatters set by SetCategoryFormatter
+	depths   atomic.Value   // the []categoryDepth call stack depths set by SetCategoryCallStackDepth
+	captures atomic.Value   // the []*entryCapture installed by Capture
+	chain    atomic.Value   // the []Middleware added by Use
+	opened   time.Time      // the time of the last call to Open, with its monotonic clock reading for the uptime field
+	closing  int32          // the number of targets closed so far by CloseError. Accessed atomically.
+
+	earlyLock    sync.Mutex   // guards early and earlyDropped
+	early        []earlyEntry // the entries logged before the logger is opened, kept if EarlyBufferSize is positive
+	earlyDropped int          // the number of early entries dropped because EarlyBufferSize was reached
+
+	captureLock sync.Mutex // serializes the updates of captures
 
 	ErrorWriter     io.Writer // the writer used to write errors caused by log targets
-	BufferSize      int       // the size of the channel storing log entries
+	BufferSize      int       // the size of the channel storing log entries. 0 makes every log call wait until the entry is taken for processing.
 	CallStackDepth  int       // the number of call stack frames to be logged for each message. 0 means do not log any call stack frame.
 	CallStackFilter string    // a substring that a call stack frame file path should contain in order for the frame to be counted
 	MaxLevel        Level     // the maximum level of messages to be logged
 	Targets         []Target  // targets for sending log messages to
+	ExitOnSignal    bool      // whether to exit the process after the logger is closed by a signal handled by HandleSignals
+	MaxMessageLen   int       // the maximum number of bytes of a log message. Longer messages are truncated. 0 means no limit.
+	MaxFieldLen     int       // the maximum number of bytes of a string field value, including those in groups. Longer values are truncated. 0 means no limit.
+	Sampler         *Sampler  // the sampler used to reduce the number of logged messages. Nil means no sampling.
+	OnOpen          func()    // called after Open has opened the targets and started processing messages
+	OnClose         func()    // called by the processing goroutine when it stops after all messages are processed
+	// whether to report the messages logged after the logger is closed to ErrorWriter. Such messages are always dropped.
+	ReportLogAfterClose bool
+	// the detector logging a critical alert when errors are logged at a high rate. Nil means no detection.
+	ErrorBurst *BurstDetector
+	// the correlator adding the ID of every error entry to the entries following it in the same goroutine and
+	// category (see ErrorCorrelator). Nil means no correlation.
+	ErrorCorrelation *ErrorCorrelator
+	// whether to add the ID of the goroutine logging a message as the "goroutine" field. The ID is parsed
+	// from the output of runtime.Stack, which takes about a microsecond, so this should only be enabled for debugging.
+	// Go does not expose goroutine IDs officially; they are only meant to tell apart the goroutines in the logs.
+	IncludeGoroutineID bool
+	// whether to add the time elapsed since the logger was opened as the "uptime" field (see Uptime). It is measured
+	// with the monotonic clock, so it is not affected by the changes of the wall clock, unlike the entry times.
+	IncludeUptime bool
+	// the maximum number of bytes of the call stack of a log message. Longer call stacks are truncated. 0 means no limit.
+	MaxCallStackLen int
+	// the maximum level of messages whose call stacks are logged. Like MaxLevel, it selects the messages
+	// at or above a severity, e.g. LevelError logs the call stacks of errors only.
+	CallStackMaxLevel Level
+	// the function choosing the level of the entries logged by HTTPRequest from the response status.
+	// Nil means HTTPStatusLevel.
+	HTTPStatusLevel func(status int) Level
+	// the maximum number of messages logged before the logger is opened that are kept and sent to the targets
+	// when it is opened, so that the diagnostics of the startup code are not lost. When the limit is reached,
+	// the oldest messages are dropped (and their number reported to ErrorWriter). 0 means such messages are dropped.
+	EarlyBufferSize int
+	// the number of consecutive failures of a target (see FailureReporter) making Healthy return false. 0 disables the check.
+	HealthFailureStreak int
+	// how long the entry channel may stay full before Healthy returns false. 0 disables the check.
+	HealthSaturation time.Duration
+	// whether Recover swallows the panics it logs instead of closing the logger and panicking again.
+	SwallowPanics bool
+	// how long Recover and HandleSignals wait for the targets to be closed before the process panics or exits,
+	// so that a target stuck on a dead destination cannot keep a crashing process alive. 0 means no limit.
+	ExitFlushTimeout time.Duration
+	// whether to report, for every entry reaching the targets, which of them accept or reject it and why
+	// (see FilterDecision), to diagnose routing issues. The trace is written to ErrorWriter unless FilterTracer
+	// is set. It formats several lines for every entry, so it should only be enabled for debugging.
+	TraceFilters bool
+	// the function receiving the filter trace of every entry when TraceFilters is true. Nil means ErrorWriter.
+	// It is called by the goroutine processing the entries, before the entry is sent to the targets.
+	FilterTracer func(e *Entry, decisions []FilterDecision)
+}
+
+// earlyEntry is an entry logged before the logger is opened, together with the logger that logged it.
+type earlyEntry struct {
+	logger *Logger
+	entry  *Entry
 }
 
 // Formatter formats a log message into an appropriate string.
 type Formatter func(*Logger, *Entry) string
 
+// levelName renders a level with the LevelFormatter of the logger, which may be nil.
+func (l *Logger) levelName(level Level) string {
+	if l != nil && l.LevelFormatter != nil {
+		return l.LevelFormatter(level)
+	}
+	return level.String()
+}
+
 // Logger records log messages and dispatches them to various targets for further processing.
 type Logger struct {
 	*coreLogger
-	Category  string    // the category associated with this logger
-	Formatter Formatter // message formatter
-	Fields    Fields    // custom fields
-	Params    Fields    // custom params
+	Category      string    // the category associated with this logger
+	Formatter     Formatter // message formatter
+	FormatterName string    // the name of a registered formatter which replaces Formatter when the logger is opened or derived
+	Fields        Fields    // custom fields added to every entry. Those added by WithFields or set in an entry take precedence.
+	Params        Fields    // custom params
+	Version       string    // the build version or commit added as the "version" field to every entry, unless the entry has one. Empty means none.
+	// the function rendering the levels in the built-in formatters (DefaultFormatter, JSONFormatter, LogfmtFormatter
+	// and those created by NewDefaultFormatter, NewJSONFormatter and NewLogfmtFormatter). Nil means Level.String.
+	LevelFormatter func(Level) string
+	// the number of additional frames skipped when capturing the call stack of an entry, so that a library wrapping
+	// the logger in its own helper functions can make the call stack start at the code calling those helpers,
+	// e.g. 1 for helpers calling the log methods directly. Negative values are treated as 0.
+	CallerSkip int
+
+	groups      []string      // the groups that the fields added by WithFields are nested in
+	levelFields []levelFields // the fields added by WithFieldsAtLevel
+	contextKeys []interface{} // the context keys extracted by WithContext
+	every       *everyLimiter // the limit set by Every, or nil
+}
+
+// levelFields are fields added only to the entries at or above a severity level.
+type levelFields struct {
+	level  Level
+	fields Fields
 }
 
 // NewLogger creates a root logger.
 // The new logger takes these default options:
-// ErrorWriter: os.Stderr, BufferSize: 1024, MaxLevel: LevelDebug,
-// Category: app, Formatter: DefaultFormatter
+// ErrorWriter: os.Stderr, BufferSize: 1024, MaxLevel: LevelDebug, ExitOnSignal: true,
+// MaxCallStackLen: 16384, CallStackMaxLevel: LevelDebug, HealthFailureStreak: 5, HealthSaturation: 10s,
+// ExitFlushTimeout: 10s, Category: app, Formatter: DefaultFormatter
 func NewLogger() *Logger {
 	logger := &coreLogger{
-		ErrorWriter: os.Stderr,
-		BufferSize:  1024,
-		MaxLevel:    LevelDebug,
-		Targets:     make([]Target, 0),
+		ErrorWriter:         os.Stderr,
+		BufferSize:          1024,
+		MaxLevel:            LevelDebug,
+		Targets:             make([]Target, 0),
+		ExitOnSignal:        true,
+		MaxCallStackLen:     16384,
+		CallStackMaxLevel:   LevelDebug,
+		HealthFailureStreak: 5,
+		HealthSaturation:    10 * time.Second,
+		ExitFlushTimeout:    10 * time.Second,
 	}
 	return &Logger{
 		coreLogger: logger,
@@ -158,14 +432,28 @@ func NewLogger() *Logger {
 
 func (l *Logger) Dup() *Logger {
 	ret := &Logger{
-		coreLogger: l.coreLogger,
-		Category:   l.Category,
-		Formatter:  l.Formatter,
+		coreLogger:     l.coreLogger,
+		Category:       l.Category,
+		Formatter:      l.Formatter,
+		FormatterName:  l.FormatterName,
+		LevelFormatter: l.LevelFormatter,
+		Version:        l.Version,
+		CallerSkip:     l.CallerSkip,
+		groups:         l.groups,
+		levelFields:    l.levelFields,
+		contextKeys:    l.contextKeys,
+		every:          l.every,
+	}
+	// resolve FormatterName so that the loggers derived before the root logger is opened use the named formatter
+	if l.FormatterName != "" {
+		if formatter := GetFormatter(l.FormatterName); formatter != nil {
+			ret.Formatter = formatter
+		}
 	}
 	if l.Fields != nil {
 		ret.Fields = make(Fields, 0)
 		for dn, d := range l.Fields {
-			ret.Fields[dn] = d
+			ret.Fields[dn] = normalizeField(d)
 		}
 	}
 	if l.Params != nil {
@@ -181,11 +469,16 @@ func (l *Logger) Dup() *Logger {
 // Messages logged through this logger will carry the same category name.
 // The formatter, if not specified, will inherit from the calling logger.
 // It will be used to format all messages logged through this logger.
+// The new logger also inherits the fields and params of the calling logger, and it shares the targets,
+// the dispatching goroutine and the settings (such as MaxLevel) of the root logger: it needs no Open or Close
+// of its own, its messages are sent to the targets once the root logger is opened, and changing the shared
+// settings of any of them affects all of them.
 func (l *Logger) GetLogger(category string, formatter ...Formatter) *Logger {
 	ret := l.Dup()
 	ret.Category = category
 	if len(formatter) > 0 {
 		ret.Formatter = formatter[0]
+		ret.FormatterName = ""
 	}
 	return ret
 }
@@ -197,18 +490,109 @@ func (l *Logger) WithField(name string, value interface{}) *Logger {
 	})
 }
 
-// WithFields returns a logger with multiple fields added
+// WithFields returns a logger with multiple fields added.
+// If the logger has groups (see WithGroup), the fields are nested in the innermost group.
 func (l *Logger) WithFields(fields Fields) *Logger {
 	ret := l.Dup()
 	if ret.Fields == nil {
 		ret.Fields = make(Fields, 0)
 	}
+	target := ret.Fields
+	for _, name := range l.groups {
+		// copy the group so that it is not shared with the original logger
+		group, _ := target[name].(Fields)
+		copied := make(Fields, len(group)+len(fields))
+		for dn, d := range group {
+			copied[dn] = d
+		}
+		target[name] = copied
+		target = copied
+	}
 	for dn, d := range fields {
-		ret.Fields[dn] = d
+		target[dn] = d
 	}
 	return ret
 }
 
+// WithFieldsAtLevel returns a logger with multiple fields added only to the entries whose severity
+// is at or above the given level (i.e. whose level value is no greater than it), which is useful for
+// heavy diagnostic fields that are only worth logging with errors. For example,
+//
+//	l := logger.WithFieldsAtLevel(log.LevelError, log.Fields{"body": body})
+//	l.Info("request received")  // without the body
+//	l.Error("request failed")   // with the body
+//
+// The fields are merged when an entry is logged, with the values given to this method, and they
+// take precedence over the fields added by WithFields. If the logger has groups (see WithGroup),
+// the fields are nested in the innermost group.
+func (l *Logger) WithFieldsAtLevel(level Level, fields Fields) *Logger {
+	nested := make(Fields, len(fields))
+	for dn, d := range fields {
+		nested[dn] = d
+	}
+	for i := len(l.groups) - 1; i >= 0; i-- {
+		nested = Fields{l.groups[i]: nested}
+	}
+	ret := l.Dup()
+	ret.levelFields = append(l.levelFields[:len(l.levelFields):len(l.levelFields)], levelFields{level, nested})
+	return ret
+}
+
+// fieldsAtLevel returns the fields of the logger to be added to an entry of the given level,
+// including those added by WithFieldsAtLevel.
+func (l *Logger) fieldsAtLevel(level Level) Fields {
+	fields := l.Fields
+	copied := false
+	for _, lf := range l.levelFields {
+		if level > lf.level {
+			continue
+		}
+		if !copied {
+			fields = make(Fields, len(l.Fields)+len(lf.fields))
+			for dn, d := range l.Fields {
+				fields[dn] = d
+			}
+			copied = true
+		}
+		mergeGroups(fields, lf.fields)
+	}
+	return fields
+}
+
+// mergeGroups merges the fields into dst. Groups present in both are merged recursively;
+// they are copied so that the groups of dst are not modified.
+func mergeGroups(dst, fields Fields) {
+	for dn, d := range fields {
+		if group, ok := d.(Fields); ok {
+			if existing, ok := dst[dn].(Fields); ok {
+				copied := make(Fields, len(existing)+len(group))
+				for gn, g := range existing {
+					copied[gn] = g
+				}
+				mergeGroups(copied, group)
+				dst[dn] = copied
+				continue
+			}
+		}
+		dst[dn] = d
+	}
+}
+
+// WithGroup returns a logger whose subsequently added fields are nested in a group with the given name.
+// Nested groups compose, e.g. logger.WithGroup("http").WithGroup("req").WithField("method", "GET")
+// yields the field {"http": {"req": {"method": "GET"}}}.
+// A group is stored as a Fields value. JSONFormatter renders it as a nested object, while
+// LogfmtFormatter and DefaultFormatter render its fields with dotted keys, e.g. "http.req.method=GET".
+// If the name is empty, the logger itself is returned.
+func (l *Logger) WithGroup(name string) *Logger {
+	if name == "" {
+		return l
+	}
+	ret := l.Dup()
+	ret.groups = append(l.groups[:len(l.groups):len(l.groups)], name)
+	return ret
+}
+
 // WithParam returns a logger with a single param added
 func (l *Logger) WithParam(name string, value interface{}) *Logger {
 	return l.WithParams(Fields{
@@ -228,6 +612,84 @@ func (l *Logger) WithParams(params Fields) *Logger {
 	return ret
 }
 
+// LoggerConfig is the configuration of a logger saved by Logger.Snapshot and restored by Logger.Restore.
+type LoggerConfig struct {
+	MaxLevel       Level
+	Category       string
+	Formatter      Formatter
+	FormatterName  string
+	LevelFormatter func(Level) string
+	Fields         Fields
+	Params         Fields
+	Version        string
+	CallerSkip     int
+
+	groups      []string
+	levelFields []levelFields
+	contextKeys []interface{}
+	every       *everyLimiter
+}
+
+// Snapshot saves the configuration of the logger: its MaxLevel, Category, Formatter, FormatterName,
+// LevelFormatter, Fields, Params and Version, as well as the groups, fields and keys added by WithGroup, WithFieldsAtLevel and WithContextKeys.
+// This is useful for tests modifying a shared logger, which may restore it in a defer:
+//
+//	defer logger.Restore(logger.Snapshot())
+//
+// The targets are intentionally excluded, because they hold live resources such as open files and
+// connections that cannot be restored; the other settings of the root logger are not saved either.
+// The fields and params are copied, so modifying them afterwards does not change the snapshot.
+func (l *Logger) Snapshot() LoggerConfig {
+	return LoggerConfig{
+		MaxLevel:       l.MaxLevel,
+		Category:       l.Category,
+		Formatter:      l.Formatter,
+		FormatterName:  l.FormatterName,
+		LevelFormatter: l.LevelFormatter,
+		Fields:         copyFields(l.Fields),
+		Params:         copyFields(l.Params),
+		Version:        l.Version,
+		CallerSkip:     l.CallerSkip,
+		groups:         l.groups,
+		levelFields:    l.levelFields,
+		contextKeys:    l.contextKeys,
+		every:          l.every,
+	}
+}
+
+// Restore restores the configuration saved by Snapshot. A snapshot may be restored multiple times.
+// Note that MaxLevel is shared by the root logger and the loggers derived from it, so restoring it affects all of them.
+func (l *Logger) Restore(config LoggerConfig) {
+	l.MaxLevel = config.MaxLevel
+	l.Category = config.Category
+	l.Formatter = config.Formatter
+	l.FormatterName = config.FormatterName
+	l.LevelFormatter = config.LevelFormatter
+	l.Version = config.Version
+	l.CallerSkip = config.CallerSkip
+	l.Fields = copyFields(config.Fields)
+	l.Params = copyFields(config.Params)
+	l.groups = config.groups
+	l.levelFields = config.levelFields
+	l.contextKeys = config.contextKeys
+	l.every = config.every
+}
+
+// copyFields returns a copy of the fields, including the groups nested in them.
+func copyFields(fields Fields) Fields {
+	if fields == nil {
+		return nil
+	}
+	ret := make(Fields, len(fields))
+	for dn, d := range fields {
+		if group, ok := d.(Fields); ok {
+			d = copyFields(group)
+		}
+		ret[dn] = d
+	}
+	return ret
+}
+
 // Emergency logs a message indicating the system is unusable.
 // Please refer to Error() for how to use this method.
 func (l *Logger) Emergency(format string, a ...interface{}) {
@@ -279,46 +741,287 @@ func (l *Logger) Debug(format string, a ...interface{}) {
 }
 
 // Log logs a message of a specified severity level.
+// The level is checked before anything else, so a message filtered out by MaxLevel is never formatted
+// and costs no allocation, although converting the arguments to interface{} values may still allocate.
 func (l *Logger) Log(level Level, format string, a ...interface{}) {
-	if level > l.MaxLevel || !l.open {
+	l.log(4, level, format, a...)
+}
+
+// LogAt logs a message of a specified severity level with the given time instead of the current time,
+// e.g. to backfill historical events or to bridge sources timestamping their own events.
+// A zero time is replaced with the current time.
+func (l *Logger) LogAt(t time.Time, level Level, format string, a ...interface{}) {
+	l.logAt(3, t, level, format, a...)
+}
+
+// LogIf logs a message at levelTrue if cond is true, and at levelFalse otherwise, e.g. at Info on success
+// and at Error on failure:
+//
+//	logger.LogIf(err == nil, log.LevelInfo, log.LevelError, "import of %v finished: %v", name, err)
+//
+// Like Log, it checks the chosen level before anything else, and the message is formatted at most once.
+func (l *Logger) LogIf(cond bool, levelTrue, levelFalse Level, format string, a ...interface{}) {
+	level := levelFalse
+	if cond {
+		level = levelTrue
+	}
+	l.logAt(3, time.Time{}, level, format, a...)
+}
+
+// log logs a message of a specified severity level.
+// skip is the number of stack frames to skip when capturing the call stack, counting from GetCallStack,
+// so that wrappers (such as BroadcastLogger) can report the frame of their own caller.
+func (l *Logger) log(skip int, level Level, format string, a ...interface{}) {
+	l.logAt(skip+1, time.Time{}, level, format, a...)
+}
+
+// logAt logs a message like log, with the given time unless it is zero.
+func (l *Logger) logAt(skip int, at time.Time, level Level, format string, a ...interface{}) {
+	if l.discards(level) {
 		return
 	}
-	message := format
-	if len(a) > 0 {
-		message = fmt.Sprintf(format, a...)
+	message, formatted := format, false
+	if remaps := l.levelRemaps(); len(remaps) > 0 {
+		// the rules match the formatted message, so it must be formatted before filtering
+		if len(a) > 0 {
+			message = fmt.Sprintf(format, a...)
+		}
+		formatted = true
+		if level = remapLevel(remaps, level, message); level > l.MaxLevel {
+			return
+		}
+	}
+	if !l.accepting() {
+		if l.ReportLogAfterClose {
+			if !formatted && len(a) > 0 {
+				message = fmt.Sprintf(format, a...)
+			}
+			l.reportLogAfterClose(level, message)
+		}
+		return
 	}
 	entry := &Entry{
 		Category: l.Category,
 		Level:    level,
-		Message:  message,
-		Time:     time.Now(),
 	}
-	if l.CallStackDepth > 0 {
-		entry.CallStack = GetCallStack(3, l.CallStackDepth, l.CallStackFilter)
+	if l.every != nil && !l.every.check(entry, format) {
+		return
 	}
-	if l.Fields != nil {
-		entry.Fields = make(Fields, 0)
-		for dn, d := range l.Fields {
-			entry.Fields[dn] = d
+	if l.Sampler != nil && !l.Sampler.allow(entry, l.Fields) {
+		return
+	}
+	entry.Time = at
+	if at.IsZero() {
+		entry.Time = time.Now()
+	}
+	if !formatted && len(a) > 0 {
+		message = fmt.Sprintf(format, a...)
+	}
+	entry.Message = message
+	if depth := l.callStackDepth(level, entry.Category); depth > 0 {
+		entry.CallStack = GetCallStack(skip+l.callerSkip(), depth, l.CallStackFilter)
+	}
+	l.enqueue(entry)
+}
+
+// LogEntry logs a pre-built entry, which is useful for bridging other logging systems.
+// The entry is subject to the same level filtering and sampling as the entries logged via Log.
+// The logger fills in Seq and FormattedMessage, as well as Time, Category and CallStack if they are empty.
+// The logger's fields and params are merged into those of the entry, with the latter taking precedence.
+// The entry must not be modified after calling this method.
+func (l *Logger) LogEntry(e *Entry) {
+	if !l.accept(e) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if depth := l.callStackDepth(e.Level, e.Category); depth > 0 && e.CallStack == "" {
+		e.CallStack = GetCallStack(2+l.callerSkip(), depth, l.CallStackFilter)
+	}
+	l.enqueue(e)
+}
+
+// accept remaps the level and fills in the category of a pre-built entry, and checks if the entry passes the level filtering,
+// the limit set by Every and sampling.
+func (l *Logger) accept(e *Entry) bool {
+	e.Level = remapLevel(l.levelRemaps(), e.Level, e.Message)
+	if e.Level > l.MaxLevel {
+		return false
+	}
+	if !l.accepting() {
+		l.reportLogAfterClose(e.Level, e.Message)
+		return false
+	}
+	if e.Category == "" {
+		e.Category = l.Category
+	}
+	if l.every != nil && !l.every.check(e, e.Message) {
+		return false
+	}
+	return l.Sampler == nil || l.Sampler.allow(e, l.Fields)
+}
+
+// enqueue completes an entry and sends it to the processing goroutine, followed by an alert
+// if the entry makes the rate of error entries exceed the threshold of ErrorBurst.
+func (l *Logger) enqueue(entry *Entry) {
+	if l.keepEarly(entry) {
+		return
+	}
+	l.dispatch(entry)
+	if l.ErrorBurst == nil || entry.Level > LevelError {
+		return
+	}
+	now := time.Now()
+	if count, elapsed, ok := l.ErrorBurst.observe(now); ok {
+		l.dispatch(&Entry{
+			Level:    LevelCritical,
+			Category: entry.Category,
+			Time:     now,
+			Message:  fmt.Sprintf("error rate spike: %v error entries in %v (threshold: %v per %v)", count, elapsed, l.ErrorBurst.Threshold, l.ErrorBurst.Window),
+		})
+	}
+}
+
+// dispatch completes an entry and sends it to the processing goroutine.
+func (l *Logger) dispatch(entry *Entry) {
+	entry.Message = truncate(entry.Message, l.MaxMessageLen)
+	entry.CallStack = truncate(entry.CallStack, l.MaxCallStackLen)
+	entry.Fields = mergeFields(l.fieldsAtLevel(entry.Level), entry.Fields, l.MaxFieldLen)
+	if l.Version != "" {
+		if entry.Fields == nil {
+			entry.Fields = make(Fields, 1)
+		}
+		if _, ok := entry.Fields["version"]; !ok {
+			entry.Fields["version"] = l.Version
 		}
 	}
-	if l.Params != nil {
-		entry.Params = make(Fields, 0)
-		for dn, d := range l.Params {
-			entry.Params[dn] = d
+	if l.IncludeGoroutineID {
+		if entry.Fields == nil {
+			entry.Fields = make(Fields, 1)
+		}
+		if _, ok := entry.Fields["goroutine"]; !ok {
+			entry.Fields["goroutine"] = goroutineID()
 		}
 	}
-	entry.FormattedMessage = l.Formatter(l, entry)
-	l.entries <- entry
+	if l.ErrorCorrelation != nil {
+		l.ErrorCorrelation.correlate(entry, goroutineID(), time.Now())
+	}
+	if l.IncludeUptime {
+		if entry.Fields == nil {
+			entry.Fields = make(Fields, 1)
+		}
+		if _, ok := entry.Fields["uptime"]; !ok {
+			entry.Fields["uptime"] = Uptime(time.Since(l.opened))
+		}
+	}
+	entry.Params = mergeFields(l.Params, entry.Params, 0)
+	if entry = l.applyMiddleware(entry); entry == nil {
+		return
+	}
+	entry.Seq = atomic.AddUint64(&l.seq, 1)
+	formatter := l.Formatter
+	if f := l.categoryFormatter(entry.Category); f != nil {
+		formatter = f
+	}
+	entry.FormattedMessage = formatter(l, entry)
+
+	// recheck the state while holding the lock so that no entry is sent after the closing nil entry
+	l.sendLock.RLock()
+	defer l.sendLock.RUnlock()
+	if l.isOpen() {
+		l.captureEntry(entry)
+		l.observeSaturation()
+		l.entries <- entry
+	} else {
+		l.reportLogAfterClose(entry.Level, entry.Message)
+	}
+}
+
+// mergeFields returns a new map containing the base fields overridden by the given fields.
+// String values longer than maxLen bytes are truncated (0 means no limit), including those in groups.
+// It returns nil when there is nothing to merge to avoid allocating maps for every entry.
+func mergeFields(base, fields Fields, maxLen int) Fields {
+	if len(base) == 0 && len(fields) == 0 {
+		return nil
+	}
+	ret := make(Fields, len(base)+len(fields))
+	for _, fs := range []Fields{base, fields} {
+		for dn, d := range fs {
+			ret[dn] = truncateField(d, maxLen)
+		}
+	}
+	return ret
+}
+
+// normalizeField converts a map value, such as a JSON object loaded by ozzo-config, into a Fields group
+// so that it is rendered like the groups created by WithGroup. Other values are returned as is.
+func normalizeField(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	group := make(Fields, len(m))
+	for dn, d := range m {
+		group[dn] = normalizeField(d)
+	}
+	return group
+}
+
+// truncateField truncates a string value, or the string values of a group, to maxLen bytes.
+// Groups are copied so that the fields of the logger are not modified.
+func truncateField(value interface{}, maxLen int) interface{} {
+	if maxLen <= 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return truncate(v, maxLen)
+	case Fields:
+		ret := make(Fields, len(v))
+		for dn, d := range v {
+			ret[dn] = truncateField(d, maxLen)
+		}
+		return ret
+	}
+	return value
 }
 
 // Open prepares the logger and the targets for logging purpose.
 // Open must be called before any message can be logged.
+// If FormatterName is set, Formatter is replaced by the formatter registered under that name.
+// Map values of Fields (e.g. JSON objects loaded by ozzo-config) are converted into groups.
+func (l *Logger) Open() error {
+	for dn, d := range l.Fields {
+		l.Fields[dn] = normalizeField(d)
+	}
+	if l.FormatterName != "" {
+		formatter := GetFormatter(l.FormatterName)
+		if formatter == nil {
+			return fmt.Errorf("Logger.FormatterName %q is not a registered formatter.", l.FormatterName)
+		}
+		l.Formatter = formatter
+	}
+	wasOpen := l.isOpen()
+	if err := l.coreLogger.Open(); err != nil {
+		return err
+	}
+	if !wasOpen {
+		l.sendEarly()
+	}
+	// OnOpen is called without holding the lock so that it may use the logger freely
+	if !wasOpen && l.OnOpen != nil {
+		l.OnOpen()
+	}
+	return nil
+}
+
+// Open prepares the logger and the targets for logging purpose.
 func (l *coreLogger) Open() error {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
-	if l.open {
+	if l.isOpen() {
 		return nil
 	}
 
@@ -333,8 +1036,12 @@ func (l *coreLogger) Open() error {
 	}
 
 	l.entries = make(chan *Entry, l.BufferSize)
+	l.opened = time.Now()
 	var targets []Target
 	for _, target := range l.Targets {
+		if t, ok := target.(bufferedTarget); ok {
+			t.setDefaultBufferSize(l.BufferSize)
+		}
 		if err := target.Open(l.ErrorWriter); err != nil {
 			fmt.Fprintf(l.ErrorWriter, "Failed to open target: %v", err)
 		} else {
@@ -345,58 +1052,219 @@ func (l *coreLogger) Open() error {
 
 	go l.process()
 
-	l.open = true
+	atomic.StoreInt32(&l.open, 1)
 
 	return nil
 }
 
+// isOpen checks if the logger is open.
+func (l *coreLogger) isOpen() bool {
+	return atomic.LoadInt32(&l.open) == 1
+}
+
+// accepting checks if the logger accepts new entries: it is open, or it has not been opened yet and EarlyBufferSize is positive.
+func (l *coreLogger) accepting() bool {
+	open := atomic.LoadInt32(&l.open)
+	return open == 1 || open == 0 && l.EarlyBufferSize > 0
+}
+
+// keepEarly keeps an entry logged before the logger is opened, to be sent when it is opened.
+// It returns false if the logger has been opened, or if EarlyBufferSize is 0.
+func (l *Logger) keepEarly(entry *Entry) bool {
+	if l.EarlyBufferSize <= 0 || atomic.LoadInt32(&l.open) != 0 {
+		return false
+	}
+	l.earlyLock.Lock()
+	defer l.earlyLock.Unlock()
+	if atomic.LoadInt32(&l.open) != 0 {
+		return false
+	}
+	if len(l.early) >= l.EarlyBufferSize {
+		n := len(l.early) - l.EarlyBufferSize + 1
+		l.early = append(l.early[:0], l.early[n:]...)
+		l.earlyDropped += n
+	}
+	l.early = append(l.early, earlyEntry{l, entry})
+	return true
+}
+
+// sendEarly sends the entries kept by keepEarly to the targets after the logger is opened.
+// Entries logged concurrently with Open may be sent before them.
+func (l *coreLogger) sendEarly() {
+	l.earlyLock.Lock()
+	early, dropped := l.early, l.earlyDropped
+	l.early, l.earlyDropped = nil, 0
+	l.earlyLock.Unlock()
+	if dropped > 0 {
+		fmt.Fprintf(l.ErrorWriter, "Logger dropped %v message(s) logged before it was opened\n", dropped)
+	}
+	for _, e := range early {
+		e.logger.enqueue(e.entry)
+	}
+}
+
+// callerSkip returns the number of additional frames skipped when capturing a call stack, as set by CallerSkip.
+func (l *Logger) callerSkip() int {
+	if l.CallerSkip < 0 {
+		return 0
+	}
+	return l.CallerSkip
+}
+
+// callStackDepth returns the number of call stack frames logged for the messages of the given level and category:
+// the depth set by SetCategoryCallStackDepth for the category, or CallStackDepth. 0 means no call stack is logged.
+func (l *coreLogger) callStackDepth(level Level, category string) int {
+	if level > l.CallStackMaxLevel {
+		return 0
+	}
+	if depth, ok := l.categoryCallStackDepth(category); ok {
+		return depth
+	}
+	return l.CallStackDepth
+}
+
+// reportLogAfterClose reports a message dropped because the logger is closed if ReportLogAfterClose is true.
+// Messages logged before the logger is opened are dropped silently.
+func (l *coreLogger) reportLogAfterClose(level Level, message string) {
+	if l.ReportLogAfterClose && atomic.LoadInt32(&l.open) == 2 {
+		fmt.Fprintf(l.ErrorWriter, "Logger is closed, dropped message: [%v] %v\n", level, message)
+	}
+}
+
 // process sends the messages to targets for processing.
+// When the entry channel is closed by Close, it notifies the targets of the close (see ProcessCloser),
+// calls OnClose and stops. Nil entries are ignored, so that they cannot close the targets early.
 func (l *coreLogger) process() {
-	for {
-		entry := <-l.entries
-		for _, target := range l.Targets {
-			target.Process(entry)
+	defer func() {
+		if l.OnClose != nil {
+			l.OnClose()
 		}
+	}()
+	for entry := range l.entries {
 		if entry == nil {
-			break
+			continue
+		}
+		if l.TraceFilters {
+			l.traceFilters(entry)
+		}
+		for _, target := range l.Targets {
+			target.Process(entry)
 		}
 	}
+	for _, target := range l.Targets {
+		processClose(target)
+	}
 }
 
 // Close closes the logger and the targets.
 // Existing messages will be processed before the targets are closed.
 // New incoming messages will be discarded after calling this method.
+// Close is safe to call concurrently with the log methods and with other calls to Close.
 func (l *coreLogger) Close() {
-	if !l.open {
-		return
+	l.CloseError()
+}
+
+// CloseError closes the logger and the targets like Close, and returns the errors
+// reported by the targets implementing ErrorCloser as Errors.
+// It returns nil if no target reports an error.
+func (l *coreLogger) CloseError() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// wait for the entries being sent, and discard the subsequent ones
+	l.sendLock.Lock()
+	if !l.isOpen() {
+		l.sendLock.Unlock()
+		return nil
 	}
-	l.open = false
-	// use a nil entry to signal the close of logger
-	l.entries <- nil
+	atomic.StoreInt32(&l.open, 2)
+	l.sendLock.Unlock()
+
+	// no entry is sent anymore, so closing the channel signals the close of the logger after the pending entries
+	close(l.entries)
+	var errs Errors
+	atomic.StoreInt32(&l.closing, 0)
 	for _, target := range l.Targets {
-		target.Close()
+		if closer, ok := target.(ErrorCloser); ok {
+			if err := closer.CloseError(); err != nil {
+				errs = append(errs, err)
+			}
+		} else {
+			target.Close()
+		}
+		atomic.AddInt32(&l.closing, 1)
 	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // DefaultFormatter is the default formatter used to format every log message.
+// It is equivalent to the formatter created by NewDefaultFormatter with the default options.
 func DefaultFormatter(l *Logger, e *Entry) string {
-	return fmt.Sprintf("%v [%v][%v] %v%v", e.Time.Format(time.RFC3339), e.Level, e.Category, e.Message, e.CallStack)
+	return defaultFormatter(l, e)
 }
 
+var defaultFormatter = NewDefaultFormatter(DefaultFormatterOptions{})
+
+// truncate shortens s to at most max bytes (0 means no limit), noting how many bytes were dropped.
+// The string is cut at a UTF-8 character boundary.
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s...(+%d bytes truncated)", s[:n], len(s)-n)
+}
+
+// goroutineID returns the ID of the current goroutine parsed from the header of its stack trace,
+// e.g. "goroutine 18 [running]:", or 0 if the header cannot be parsed.
+func goroutineID() uint64 {
+	var buf [64]byte
+	s := buf[:runtime.Stack(buf[:], false)]
+	s = bytes.TrimPrefix(s, []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseUint(string(s), 10, 64)
+	return id
+}
+
+// maxCallStackFrames is the maximum number of frames examined by GetCallStack,
+// which bounds the cost of capturing the call stack of a deep recursion.
+const maxCallStackFrames = 512
+
 // GetCallStack returns the current call stack information as a string.
 // The skip parameter specifies how many top frames should be skipped, while
 // the frames parameter specifies at most how many frames should be returned.
+// At most 512 frames below the skipped ones are examined, whatever the value of frames.
 func GetCallStack(skip int, frames int, filter string) string {
+	n := maxCallStackFrames
+	if filter == "" && frames < n {
+		n = frames
+	}
+	if n <= 0 {
+		return ""
+	}
+	pcs := make([]uintptr, n)
+	// runtime.Callers counts itself as the first frame, unlike runtime.Caller
+	pcs = pcs[:runtime.Callers(skip+1, pcs)]
 	buf := new(bytes.Buffer)
-	for i, count := skip, 0; count < frames; i++ {
-		_, file, line, ok := runtime.Caller(i)
-		if !ok {
-			break
-		}
-		if filter == "" || strings.Contains(file, filter) {
-			fmt.Fprintf(buf, "\n%s:%d", file, line)
+	it := runtime.CallersFrames(pcs)
+	for count := 0; count < frames; {
+		frame, more := it.Next()
+		if frame.File != "" && (filter == "" || strings.Contains(frame.File, filter)) {
+			fmt.Fprintf(buf, "\n%s:%d", frame.File, frame.Line)
 			count++
 		}
+		if !more {
+			break
+		}
 	}
 	return buf.String()
 }
@@ -5,8 +5,14 @@
 package log
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-ozzo/ozzo-config"
 )
@@ -42,6 +48,32 @@ func TestGetLogger(t *testing.T) {
 	}
 }
 
+func TestGetLoggerSharedDispatch(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	l1 := logger.WithField("service", "api").GetLogger("db")
+	l1.Info("t1")
+	logger.MaxLevel = LevelWarning
+	l1.Info("t2")
+	l2 := l1.GetLogger("db.query", func(l *Logger, e *Entry) string { return "custom " + e.Message })
+	l2.Error("t3")
+	logger.Close()
+	l1.Error("t4")
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, fmt.Sprintf("%v:%v:%v", e.Category, e.Fields["service"], e.FormattedMessage))
+	}
+	expected := "db:api:" + DefaultFormatter(l1, target.entries[0]) + ",db.query:api:custom t3"
+	if result := strings.Join(messages, ","); result != expected {
+		t.Errorf("messages = %v, expected %v", result, expected)
+	}
+}
+
 type MemoryTarget struct {
 	entries []*Entry
 	open    bool
@@ -120,6 +152,10 @@ func TestLoggerConfig(t *testing.T) {
 		"Logger": {
 			"MaxLevel": 2,
 			"Category": "app2",
+			"Fields": {
+				"service": "api",
+				"version": "1.2.3"
+			},
 			"Targets": [
 				{
 					"type": "memory1",
@@ -153,6 +189,9 @@ func TestLoggerConfig(t *testing.T) {
 	if logger.Category != "app2" {
 		t.Errorf("logger.Category = %v, expected %v", logger.Category, "app2")
 	}
+	if logger.Fields["service"] != "api" || logger.Fields["version"] != "1.2.3" {
+		t.Errorf("logger.Fields = %v, expected the fields loaded from the config", logger.Fields)
+	}
 
 	if len(logger.Targets) != 2 {
 		t.Errorf("len(logger.Targets) = %v, expected %v", len(logger.Targets), 2)
@@ -200,3 +239,956 @@ func TestLoggerFields(t *testing.T) {
 		}
 	}
 }
+
+func TestLoggerBaseFields(t *testing.T) {
+	logger := NewLogger()
+	logger.Fields = Fields{
+		"service": "api",
+		"build":   map[string]interface{}{"version": "1.2.3"},
+	}
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	derived := logger.GetLogger("derived")
+	derived.Fields["service"] = "worker"
+	logger.Open()
+
+	logger.WithField("service", "override").Info("t1")
+	derived.Info("t2")
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	if v := target.entries[0].Fields["service"]; v != "override" {
+		t.Errorf("entries[0].Fields[service] = %v, expected the field added by WithField to take precedence", v)
+	}
+	if logger.Fields["service"] != "api" {
+		t.Errorf("logger.Fields[service] = %v, the fields of a derived logger should not be shared", logger.Fields["service"])
+	}
+	for _, e := range target.entries {
+		if build, ok := e.Fields["build"].(Fields); !ok || build["version"] != "1.2.3" {
+			t.Errorf("%v: Fields[build] = %#v, expected a group", e.Message, e.Fields["build"])
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s        string
+		max      int
+		expected string
+	}{
+		{"abcdef", 0, "abcdef"},
+		{"abcdef", 6, "abcdef"},
+		{"abcdef", 4, "abcd...(+2 bytes truncated)"},
+		{"abécd", 3, "ab...(+4 bytes truncated)"},
+	}
+	for _, test := range tests {
+		if result := truncate(test.s, test.max); result != test.expected {
+			t.Errorf("truncate(%q, %v) = %q, expected %q", test.s, test.max, result, test.expected)
+		}
+	}
+}
+
+func TestLoggerMaxMessageLen(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxMessageLen = 5
+	logger.MaxFieldLen = 2
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	group := logger.WithGroup("g").WithFields(Fields{"s": "abc"})
+	logger.WithFields(Fields{"s": "xyz", "n": 12345}).Info("0123456789")
+	group.Info("t2")
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	e := target.entries[0]
+	if e.Message != "01234...(+5 bytes truncated)" {
+		t.Errorf("entry.Message = %q, expected %q", e.Message, "01234...(+5 bytes truncated)")
+	}
+	if e.Fields["s"] != "xy...(+1 bytes truncated)" {
+		t.Errorf("entry.Fields[s] = %q, expected %q", e.Fields["s"], "xy...(+1 bytes truncated)")
+	}
+	if e.Fields["n"] != 12345 {
+		t.Errorf("entry.Fields[n] = %v, expected %v", e.Fields["n"], 12345)
+	}
+	if g, _ := target.entries[1].Fields["g"].(Fields); g["s"] != "ab...(+1 bytes truncated)" {
+		t.Errorf("entry.Fields[g][s] = %q, expected %q", g["s"], "ab...(+1 bytes truncated)")
+	}
+	if g, _ := group.Fields["g"].(Fields); g["s"] != "abc" {
+		t.Errorf("the fields of the logger should not be modified, got %q", g["s"])
+	}
+}
+
+func TestLoggerSeq(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	const goroutines, count = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := logger.WithField("goroutine", i)
+			for j := 0; j < count; j++ {
+				l.Info("t%v", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	logger.Close()
+
+	if len(target.entries) != goroutines*count {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), goroutines*count)
+	}
+	seen := make(map[uint64]bool)
+	last := make(map[interface{}]uint64)
+	for _, e := range target.entries {
+		if e.Seq < 1 || e.Seq > goroutines*count || seen[e.Seq] {
+			t.Fatalf("entry.Seq = %v is out of range or duplicated", e.Seq)
+		}
+		seen[e.Seq] = true
+		g := e.Fields["goroutine"]
+		if e.Seq <= last[g] {
+			t.Fatalf("entry.Seq = %v is not greater than the previous sequence %v of the same goroutine", e.Seq, last[g])
+		}
+		last[g] = e.Seq
+	}
+}
+
+type errorCloserTarget struct {
+	MemoryTarget
+	err error
+}
+
+func (t *errorCloserTarget) CloseError() error {
+	t.Close()
+	return t.err
+}
+
+func TestLoggerCloseError(t *testing.T) {
+	logger := NewLogger()
+	t1 := &errorCloserTarget{MemoryTarget: MemoryTarget{ready: make(chan bool, 0)}, err: errors.New("e1")}
+	t2 := &MemoryTarget{ready: make(chan bool, 0)}
+	t3 := &errorCloserTarget{MemoryTarget: MemoryTarget{ready: make(chan bool, 0)}, err: errors.New("e3")}
+	logger.Targets = append(logger.Targets, t1, t2, t3)
+	logger.Open()
+	logger.Info("t1")
+
+	err := logger.CloseError()
+	if err == nil || err.Error() != "e1; e3" {
+		t.Errorf("logger.CloseError() = %v, expected %v", err, "e1; e3")
+	}
+	if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Errorf("logger.CloseError() = %#v, expected Errors with 2 errors", err)
+	}
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("logger.CloseError() on a closed logger = %v, expected nil", err)
+	}
+
+	logger = NewLogger()
+	logger.Targets = append(logger.Targets, &MemoryTarget{ready: make(chan bool, 0)})
+	logger.Open()
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("logger.CloseError() = %v, expected nil", err)
+	}
+}
+
+type discardTarget struct {
+	done chan bool
+}
+
+func (t *discardTarget) Open(io.Writer) error {
+	t.done = make(chan bool, 0)
+	return nil
+}
+
+func (t *discardTarget) Process(e *Entry) {
+	if e == nil {
+		t.done <- true
+	}
+}
+
+func (t *discardTarget) Close() {
+	<-t.done
+}
+
+func TestLoggerEmptyFields(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.WithFields(Fields{}).Info("t2")
+	logger.Close()
+
+	for _, e := range target.entries {
+		if e.Fields != nil {
+			t.Errorf("entry.Fields = %v, expected nil", e.Fields)
+		}
+	}
+}
+
+func benchmarkLoggerLog(b *testing.B, logger *Logger) {
+	logger.Formatter = func(*Logger, *Entry) string { return "" }
+	logger.Targets = append(logger.Targets, &discardTarget{})
+	logger.Open()
+	defer logger.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("message")
+	}
+}
+
+func BenchmarkLoggerLogNoFields(b *testing.B) {
+	benchmarkLoggerLog(b, NewLogger())
+}
+
+func BenchmarkLoggerLogFields(b *testing.B) {
+	benchmarkLoggerLog(b, NewLogger().WithField("key", "value"))
+}
+
+// filteredLogArgs are the arguments passed to the filtered-out log calls, which must not be formatted.
+var filteredLogArgs = []interface{}{
+	&struct{ ID, Name string }{"1", "admin"},
+	map[string]int{"a": 1, "b": 2},
+	[]string{"x", "y"},
+	errors.New("failure"),
+}
+
+func BenchmarkLoggerLogFiltered(b *testing.B) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	logger.Targets = append(logger.Targets, &discardTarget{})
+	logger.Open()
+	defer logger.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debug("user %v, roles %v, tags %v: %v", filteredLogArgs...)
+	}
+}
+
+func TestLoggerLogFilteredAllocs(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	logger.Targets = append(logger.Targets, &discardTarget{})
+	logger.Open()
+	defer logger.Close()
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debug("user %v, roles %v, tags %v: %v", filteredLogArgs...)
+		logger.Log(LevelDebug, "user %v", filteredLogArgs[0])
+	})
+	if allocs != 0 {
+		t.Errorf("a filtered-out log call allocates %v times, expected 0", allocs)
+	}
+}
+
+func TestTargetLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		expected string
+	}{
+		{"", nil, "NetworkTarget"},
+		{"audit-sink", nil, `NetworkTarget "audit-sink"`},
+		{"audit-sink", map[string]string{"region": "eu", "env": "prod"}, `NetworkTarget "audit-sink" [env=prod region=eu]`},
+	}
+	for _, test := range tests {
+		if label := targetLabel("NetworkTarget", test.name, test.tags); label != test.expected {
+			t.Errorf("targetLabel(%q, %v) = %v, expected %v", test.name, test.tags, label, test.expected)
+		}
+	}
+}
+
+func TestLoggerLogEntry(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	l := logger.WithFields(Fields{"a": 1, "b": 2})
+	ts := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.LogEntry(&Entry{Level: LevelDebug, Message: "filtered"})
+	l.LogEntry(&Entry{Level: LevelInfo, Message: "t1", Fields: Fields{"b": 3}})
+	l.LogEntry(&Entry{Level: LevelError, Message: "t2", Category: "bridge", Time: ts})
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	e := target.entries[0]
+	if e.Category != "app" || e.Time.IsZero() || e.Seq == 0 || e.FormattedMessage == "" {
+		t.Errorf("LogEntry() did not fill in the entry: %#v", e)
+	}
+	if e.Fields["a"] != 1 || e.Fields["b"] != 3 {
+		t.Errorf("entry.Fields = %v, expected a=1 and b=3", e.Fields)
+	}
+	e = target.entries[1]
+	if e.Category != "bridge" || !e.Time.Equal(ts) {
+		t.Errorf("LogEntry() should keep the category and time of the entry, got %v and %v", e.Category, e.Time)
+	}
+}
+
+func TestLoggerWithGroup(t *testing.T) {
+	logger := NewLogger()
+	l1 := logger.WithField("a", 1).WithGroup("http")
+	l2 := l1.WithField("status", 200)
+	l3 := l2.WithGroup("req").WithFields(Fields{"method": "GET"})
+	l4 := l2.WithField("path", "/")
+
+	if result := string(jsonValue(l3.Fields)); result != `{"a":1,"http":{"req":{"method":"GET"},"status":200}}` {
+		t.Errorf("l3.Fields = %v", result)
+	}
+	if result := string(jsonValue(l2.Fields)); result != `{"a":1,"http":{"status":200}}` {
+		t.Errorf("l2.Fields = %v, expected it not to be modified by the derived loggers", result)
+	}
+	if result := string(jsonValue(l4.Fields)); result != `{"a":1,"http":{"path":"/","status":200}}` {
+		t.Errorf("l4.Fields = %v", result)
+	}
+	if logger.WithGroup("") != logger {
+		t.Errorf("WithGroup(\"\") should return the logger itself")
+	}
+
+	e := &Entry{Fields: l3.Fields}
+	if result := LogfmtFormatter(nil, e); !strings.HasSuffix(result, " a=1 http.req.method=GET http.status=200") {
+		t.Errorf("LogfmtFormatter() = %v, expected dotted keys for groups", result)
+	}
+}
+
+func TestLoggerLifecycle(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	events := ""
+	closed := make(chan bool, 1)
+	logger.OnOpen = func() {
+		events += "open,"
+		// the logger can be used in the callback
+		logger.Info("t0")
+	}
+	logger.OnClose = func() {
+		events += fmt.Sprintf("close:%v,", len(target.entries))
+		closed <- true
+	}
+
+	logger.Open()
+	logger.Info("t1")
+	logger.Close()
+	<-closed
+
+	if events != "open,close:2," {
+		t.Errorf("events = %v, expected %v", events, "open,close:2,")
+	}
+}
+
+type lockedWriter struct {
+	lock sync.Mutex
+	buf  strings.Builder
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *lockedWriter) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.String()
+}
+
+func TestLoggerLogAfterClose(t *testing.T) {
+	logger := NewLogger()
+	writer := &lockedWriter{}
+	logger.ErrorWriter = writer
+	logger.ReportLogAfterClose = true
+	logger.Targets = append(logger.Targets, &discardTarget{})
+	logger.Info("before open")
+	logger.Open()
+
+	stop := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				logger.Info("concurrent")
+			}
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	logger.Close()
+	close(stop)
+	<-done
+
+	logger.Error("after close: %v", 1)
+	logger.LogEntry(&Entry{Level: LevelError, Message: "entry after close"})
+	result := writer.String()
+	if strings.Contains(result, "before open") {
+		t.Errorf("messages logged before Open should not be reported: %q", result)
+	}
+	for _, expected := range []string{"Logger is closed, dropped message: [Error] after close: 1\n", "dropped message: [Error] entry after close\n"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %q not found in %q", expected, result)
+		}
+	}
+}
+
+func TestLoggerIncludeGoroutineID(t *testing.T) {
+	logger := NewLogger()
+	logger.IncludeGoroutineID = true
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	done := make(chan bool)
+	go func() {
+		logger.Info("t2")
+		close(done)
+	}()
+	<-done
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	id1, _ := target.entries[0].Fields["goroutine"].(uint64)
+	id2, _ := target.entries[1].Fields["goroutine"].(uint64)
+	if id1 == 0 || id2 == 0 || id1 == id2 {
+		t.Errorf("goroutine IDs = %v and %v, expected two different IDs", id1, id2)
+	}
+	if result := JSONFormatter(nil, target.entries[0]); !strings.Contains(result, fmt.Sprintf(`"goroutine":%v`, id1)) {
+		t.Errorf("JSONFormatter() = %v, expected the goroutine ID as a number", result)
+	}
+}
+
+func recurseCallStack(depth, frames int, filter string) string {
+	if depth > 0 {
+		return recurseCallStack(depth-1, frames, filter)
+	}
+	return GetCallStack(1, frames, filter)
+}
+
+func TestGetCallStack(t *testing.T) {
+	stack := recurseCallStack(10, 3, "")
+	if n := strings.Count(stack, "\n"); n != 3 || !strings.Contains(stack, "logger_test.go") {
+		t.Errorf("GetCallStack(1, 3) = %q, expected 3 frames", stack)
+	}
+	if stack := GetCallStack(1, 1000, "no-such-file"); stack != "" {
+		t.Errorf("GetCallStack() = %q, expected no frames matching the filter", stack)
+	}
+
+	// the depth exceeds the actual stack, and the examined frames are bounded
+	stack = recurseCallStack(2000, 1<<20, "")
+	if n := strings.Count(stack, "\n"); n < 500 || n > 600 {
+		t.Errorf("GetCallStack(1, 1<<20) returned %v frames, expected about %v", n, maxCallStackFrames)
+	}
+	stack = recurseCallStack(10, 1<<20, "")
+	if n := strings.Count(stack, "\n"); n < 11 || n > 100 {
+		t.Errorf("GetCallStack(1, 1<<20) returned %v frames, expected the whole stack", n)
+	}
+}
+
+func TestLoggerMaxCallStackLen(t *testing.T) {
+	logger := NewLogger()
+	if logger.MaxCallStackLen != 16384 {
+		t.Errorf("NewLogger().MaxCallStackLen = %v, expected %v", logger.MaxCallStackLen, 16384)
+	}
+	logger.CallStackDepth = 10
+	logger.MaxCallStackLen = 20
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Close()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 1)
+	}
+	if stack := target.entries[0].CallStack; !strings.HasPrefix(stack, "\n") || !strings.Contains(stack, "bytes truncated)") || len(stack) > 60 {
+		t.Errorf("entry.CallStack = %q, expected a truncated call stack", stack)
+	}
+}
+
+// wrapperLogger is a facade adding its own helper methods over a Logger.
+type wrapperLogger struct {
+	logger *Logger
+}
+
+func (w wrapperLogger) info(msg string) {
+	w.logger.Info(msg)
+}
+
+func (w wrapperLogger) event(msg string) {
+	w.logger.NewEvent(LevelInfo).Msg(msg)
+}
+
+func (w wrapperLogger) entry(msg string) {
+	w.logger.LogEntry(&Entry{Level: LevelInfo, Message: msg})
+}
+
+func TestLoggerCallerSkip(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 1
+	logger.CallerSkip = 1
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	// the derived loggers inherit CallerSkip
+	w := wrapperLogger{logger.GetLogger("facade")}
+	_, file, line, _ := runtime.Caller(0)
+	w.info("t1")
+	w.event("t2")
+	w.entry("t3")
+	logger.Close()
+
+	if len(target.entries) != 3 {
+		t.Fatalf("number of entries = %v, expected 3", len(target.entries))
+	}
+	for i, e := range target.entries {
+		if expected := fmt.Sprintf("\n%v:%v", file, line+1+i); e.CallStack != expected {
+			t.Errorf("entries[%v].CallStack = %q, expected %q past the wrapper", i, e.CallStack, expected)
+		}
+	}
+}
+
+func TestLoggerWithFieldsAtLevel(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	l := logger.WithGroup("req").WithField("id", 1).WithFieldsAtLevel(LevelError, Fields{"body": "payload"})
+	l.Info("t1")
+	l.Error("t2")
+	l.WithFieldsAtLevel(LevelCritical, Fields{"dump": "..."}).Critical("t3")
+	logger.Close()
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	expected := []string{
+		`{"id":1}`,
+		`{"body":"payload","id":1}`,
+		`{"body":"payload","dump":"...","id":1}`,
+	}
+	for i, e := range target.entries {
+		if req := nestedJSON(e.Fields["req"]); req != expected[i] {
+			t.Errorf("%v: Fields[req] = %v, expected %v", e.Message, req, expected[i])
+		}
+	}
+	if req := nestedJSON(l.Fields["req"]); req != `{"id":1}` {
+		t.Errorf("the fields of the logger should not be modified, got %v", req)
+	}
+}
+
+func TestLoggerCallStackMaxLevel(t *testing.T) {
+	logger := NewLogger()
+	if logger.CallStackMaxLevel != LevelDebug {
+		t.Errorf("NewLogger().CallStackMaxLevel = %v, expected %v", logger.CallStackMaxLevel, LevelDebug)
+	}
+	logger.CallStackDepth = 2
+	logger.CallStackMaxLevel = LevelError
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Debug("debug")
+	logger.Error("error")
+	logger.Critical("critical")
+	logger.LogEntry(&Entry{Level: LevelInfo, Message: "entry"})
+	logger.Close()
+
+	expected := map[string]bool{"debug": false, "error": true, "critical": true, "entry": false}
+	for _, e := range target.entries {
+		if hasStack := e.CallStack != ""; hasStack != expected[e.Message] {
+			t.Errorf("%v: CallStack = %q, expected a call stack: %v", e.Message, e.CallStack, expected[e.Message])
+		}
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	level, err := RegisterLevel(-1, "Security")
+	if err != nil {
+		t.Fatalf("RegisterLevel(-1) returned error: %v", err)
+	}
+	defer delete(LevelNames, level)
+	if level.String() != "Security" {
+		t.Errorf("level.String() = %q, expected %q", level.String(), "Security")
+	}
+	if l, err := LevelFromString("SECURITY"); err != nil || l != level {
+		t.Errorf("LevelFromString(SECURITY) = %v, %v, expected %v", l, err, level)
+	}
+	if l, err := LevelFromString("warning"); err != nil || l != LevelWarning {
+		t.Errorf("LevelFromString(warning) = %v, %v, expected %v", l, err, LevelWarning)
+	}
+	if _, err := LevelFromString("verbose"); err == nil {
+		t.Error("LevelFromString(verbose) returned no error")
+	}
+	if _, err := RegisterLevel(int(LevelError), "Failure"); err == nil {
+		t.Error("RegisterLevel with a taken value returned no error")
+	}
+	if _, err := RegisterLevel(9, "security"); err == nil {
+		t.Error("RegisterLevel with a taken name returned no error")
+	}
+
+	logger := NewLogger()
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	logger.MaxLevel = LevelEmergency
+	logger.Open()
+	logger.Log(level, "locked out")
+	logger.Alert("alert")
+	logger.Close()
+	if len(target.entries) != 1 || target.entries[0].Level != level {
+		t.Fatalf("got %v entries, expected only the Security entry", len(target.entries))
+	}
+	if target.entries[0].Level.String() != "Security" {
+		t.Errorf("entry level = %q, expected %q", target.entries[0].Level.String(), "Security")
+	}
+}
+
+func TestLoggerTargetBufferSize(t *testing.T) {
+	logger := NewLogger()
+	logger.BufferSize = 64
+	t1 := NewNetworkTarget()
+	t1.Network, t1.Address, t1.Persistent = "tcp", "127.0.0.1:1", false
+	t1.BufferSize = 0
+	t2 := NewNetworkTarget()
+	t2.Network, t2.Address, t2.Persistent = "tcp", "127.0.0.1:1", false
+	t2.BufferSize = 8
+	logger.Targets = append(logger.Targets, t1, t2)
+	logger.Open()
+	defer logger.Close()
+
+	if cap(t1.entries) != 64 {
+		t.Errorf("the buffer size of a target with BufferSize 0 = %v, expected the logger's %v", cap(t1.entries), 64)
+	}
+	if cap(t2.entries) != 8 {
+		t.Errorf("the buffer size of a target with BufferSize 8 = %v, expected %v", cap(t2.entries), 8)
+	}
+}
+
+func TestLoggerSnapshot(t *testing.T) {
+	logger := NewLogger().WithGroup("http").WithField("status", 200).WithParam("p", "v")
+	config := logger.Snapshot()
+
+	logger.MaxLevel = LevelError
+	logger.Category = "test"
+	logger.Formatter = JSONFormatter
+	logger.Fields["http"].(Fields)["status"] = 500
+	logger.Fields["extra"] = true
+	logger.Params = nil
+	logger.Restore(config)
+
+	if logger.MaxLevel != LevelDebug || logger.Category != "app" {
+		t.Errorf("MaxLevel, Category = %v, %v, expected %v, %v", logger.MaxLevel, logger.Category, LevelDebug, "app")
+	}
+	if fmt.Sprint(logger.Fields) != "map[http:map[status:200]]" {
+		t.Errorf("Fields = %v, expected %v", logger.Fields, "map[http:map[status:200]]")
+	}
+	if fmt.Sprint(logger.Params) != "map[p:v]" {
+		t.Errorf("Params = %v, expected %v", logger.Params, "map[p:v]")
+	}
+	if logger.Formatter(logger, &Entry{Message: "t1"}) != DefaultFormatter(logger, &Entry{Message: "t1"}) {
+		t.Errorf("Formatter was not restored")
+	}
+	logger = logger.WithField("method", "GET")
+	if fmt.Sprint(logger.Fields) != "map[http:map[method:GET status:200]]" {
+		t.Errorf("Fields = %v, expected the groups to be restored", logger.Fields)
+	}
+}
+
+func TestLoggerEarlyBufferSize(t *testing.T) {
+	logger := NewLogger()
+	writer := &lockedWriter{}
+	logger.ErrorWriter = writer
+	logger.EarlyBufferSize = 2
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	l := logger.GetLogger("db")
+	logger.Info("t1")
+	l.Error("t2")
+	logger.LogEntry(&Entry{Level: LevelInfo, Message: "t3"})
+	logger.Open()
+	logger.Info("t4")
+	logger.Close()
+	logger.Info("t5")
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Category+":"+e.Message)
+	}
+	if result := strings.Join(messages, ","); result != "db:t2,app:t3,app:t4" {
+		t.Errorf("messages = %v, expected %v", result, "db:t2,app:t3,app:t4")
+	}
+	if target.entries[0].Seq != 1 || target.entries[0].FormattedMessage == "" {
+		t.Errorf("the early entries should be completed when they are sent, got %+v", target.entries[0])
+	}
+	if !strings.Contains(writer.String(), "Logger dropped 1 message(s) logged before it was opened") {
+		t.Errorf("ErrorWriter = %q, expected the dropped early message to be reported", writer.String())
+	}
+}
+
+func TestLoggerUnbuffered(t *testing.T) {
+	logger := NewLogger()
+	logger.BufferSize = 0
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				logger.WithField("g", g).Info("%v", i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	logger.Close()
+
+	if len(target.entries) != 400 {
+		t.Fatalf("number of entries = %v, expected %v", len(target.entries), 400)
+	}
+	next := map[interface{}]int{}
+	for _, e := range target.entries {
+		g := e.Fields["g"]
+		if e.Message != fmt.Sprint(next[g]) {
+			t.Fatalf("goroutine %v logged %q, expected %q", g, e.Message, fmt.Sprint(next[g]))
+		}
+		next[g]++
+	}
+
+	// a log call returns only once the previous entry has been processed and its entry has been taken
+	logger = NewLogger()
+	logger.BufferSize = 0
+	blocking := &blockingTarget{release: make(chan bool), done: make(chan bool)}
+	logger.Targets = append(logger.Targets, blocking)
+	logger.Open()
+	logger.Info("t1")
+	logged := make(chan bool)
+	go func() {
+		logger.Info("t2")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Errorf("logger.Info() returned while the previous entry was being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(blocking.release)
+	<-logged
+	logger.Close()
+}
+
+func TestLoggerVersion(t *testing.T) {
+	logger := NewLogger()
+	logger.Version = "v1.2.3"
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	logger.Info("t1")
+	logger.GetLogger("db").WithGroup("sql").WithField("table", "users").Info("t2")
+	logger.WithField("version", "override").Info("t3")
+	other := logger.GetLogger("other")
+	other.Version = ""
+	other.Info("t4")
+	logger.Close()
+
+	expected := []interface{}{"v1.2.3", "v1.2.3", "override", nil}
+	for i, e := range target.entries {
+		if e.Fields["version"] != expected[i] {
+			t.Errorf("entries[%v].Fields[version] = %v, expected %v", i, e.Fields["version"], expected[i])
+		}
+	}
+}
+
+func TestLoggerLogAt(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 1
+	logger.Formatter = JSONFormatter
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	logger.LogAt(at, LevelInfo, "historical %v", 1)
+	logger.LogAt(time.Time{}, LevelInfo, "now")
+	logger.Info("info")
+	logger.Close()
+
+	e := target.entries[0]
+	if !e.Time.Equal(at) || e.Message != "historical 1" || !strings.Contains(e.FormattedMessage, `"time":"2001-02-03T04:05:06Z"`) {
+		t.Errorf("entries[0] = %v %q %q, expected the given time", e.Time, e.Message, e.FormattedMessage)
+	}
+	if time.Since(target.entries[1].Time) > time.Minute {
+		t.Errorf("entries[1].Time = %v, expected the current time", target.entries[1].Time)
+	}
+	for i, e := range target.entries {
+		if !strings.Contains(e.CallStack, "logger_test.go") {
+			t.Errorf("entries[%v].CallStack = %q, expected the frame of the caller", i, e.CallStack)
+		}
+	}
+}
+
+// closerTarget is a target implementing ProcessCloser, which fails the test if Process is called with nil.
+type closerTarget struct {
+	t        *testing.T
+	messages []string
+	closes   int
+	ready    chan bool
+}
+
+func (c *closerTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (c *closerTarget) Process(e *Entry) {
+	if e == nil {
+		c.t.Errorf("Process(nil) was called on a ProcessCloser")
+		return
+	}
+	c.messages = append(c.messages, e.Message)
+}
+
+func (c *closerTarget) ProcessClose() {
+	c.closes++
+	c.ready <- true
+}
+
+func (c *closerTarget) Close() {
+	<-c.ready
+}
+
+func TestLoggerProcessCloser(t *testing.T) {
+	logger := NewLogger()
+	direct := &closerTarget{t: t, ready: make(chan bool, 1)}
+	wrapped := &closerTarget{t: t, ready: make(chan bool, 1)}
+	legacy := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, direct, NewAsyncTarget(wrapped), legacy)
+	logger.Open()
+	logger.Info("t1")
+	// an accidental nil entry must not close the targets
+	logger.entries <- nil
+	logger.Info("t2")
+	logger.Close()
+
+	for i, target := range []*closerTarget{direct, wrapped} {
+		if target.closes != 1 || strings.Join(target.messages, ",") != "t1,t2" {
+			t.Errorf("target %v got %q and %v ProcessClose calls, expected t1,t2 and 1 call", i, target.messages, target.closes)
+		}
+	}
+	if len(legacy.entries) != 2 {
+		t.Errorf("the legacy target got %v entries, expected 2", len(legacy.entries))
+	}
+}
+
+// countingStringer counts how many times it is formatted.
+type countingStringer struct {
+	s     string
+	calls int
+}
+
+func (c *countingStringer) String() string {
+	c.calls++
+	return c.s
+}
+
+func TestLoggerLogIf(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelWarning
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	arg := &countingStringer{s: "job"}
+	logger.LogIf(false, LevelInfo, LevelError, "%v failed", arg)
+	logger.LogIf(true, LevelWarning, LevelError, "%v slow", arg)
+	logger.LogIf(true, LevelInfo, LevelError, "%v done", arg)
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("number of entries = %v, expected 2", len(target.entries))
+	}
+	if e := target.entries[0]; e.Level != LevelError || e.Message != "job failed" {
+		t.Errorf("entries[0] = %v %q, expected %v %q", e.Level, e.Message, LevelError, "job failed")
+	}
+	if e := target.entries[1]; e.Level != LevelWarning || e.Message != "job slow" {
+		t.Errorf("entries[1] = %v %q, expected %v %q", e.Level, e.Message, LevelWarning, "job slow")
+	}
+	if arg.calls != 2 {
+		t.Errorf("the arguments were formatted %v times, expected 2 (once per logged message)", arg.calls)
+	}
+	for i, e := range target.entries {
+		if !strings.Contains(e.CallStack, "logger_test.go") {
+			t.Errorf("entries[%v].CallStack = %q, expected the frame of the caller", i, e.CallStack)
+		}
+	}
+}
+
+func TestLoggerIncludeUptime(t *testing.T) {
+	logger := NewLogger()
+	logger.IncludeUptime = true
+	logger.Formatter = JSONFormatter
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	time.Sleep(10 * time.Millisecond)
+	logger.GetLogger("app.db").Info("t2")
+	logger.WithField("uptime", "custom").Info("t3")
+	logger.Close()
+
+	first, ok1 := target.entries[0].Fields["uptime"].(Uptime)
+	second, ok2 := target.entries[1].Fields["uptime"].(Uptime)
+	if !ok1 || !ok2 || first < 0 || time.Duration(second-first) < 10*time.Millisecond {
+		t.Errorf("uptimes = %v, %v, expected them to increase by at least 10ms", first, second)
+	}
+	if expected := `"uptime":"` + second.String() + `"`; !strings.Contains(target.entries[1].FormattedMessage, expected) {
+		t.Errorf("FormattedMessage = %v, expected it to contain %v", target.entries[1].FormattedMessage, expected)
+	}
+	if uptime := target.entries[2].Fields["uptime"]; uptime != "custom" {
+		t.Errorf("uptime = %v, expected the field of the entry to be kept", uptime)
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package logpb serializes ozzo-log entries as protobuf messages, a compact binary alternative to JSONFormatter
+// for high-throughput pipelines. The schema is entry.proto, in this directory.
+//
+// The Go types and their encoding are written by hand rather than generated by protoc, so that neither the log
+// package nor this one depends on a protobuf runtime; the messages are nevertheless the standard proto3 encoding of
+// the schema, so they can be read and written by the code generated from entry.proto in any language.
+//
+// A stream of entries is a sequence of messages, each preceded by its length in bytes encoded as a varint,
+// which is the delimited format of the protobuf libraries (e.g. writeDelimitedTo in Java or the protodelim
+// package in Go). Formatter produces such messages and Scanner reads them back.
+package logpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+// Entry is the Go type of the Entry message of entry.proto.
+type Entry struct {
+	Seq          uint64
+	Level        int32
+	Category     string
+	Message      string
+	TimeUnixNano int64 // the time of the entry in nanoseconds since the Unix epoch, or 0 if it is not set
+	CallStack    string
+	// the fields of the entry. The values are nil, string, bool, int64, float64, []byte or log.Fields for the groups.
+	Fields log.Fields
+}
+
+// FromEntry converts a log entry to an Entry message. The values of the fields implementing log.FieldMarshaler
+// are resolved first. The integers are converted to int64 (or to a string if they overflow it), the floating-point
+// numbers to float64, the groups and other maps with string keys to log.Fields, the errors and fmt.Stringer values
+// to their text, and the other values to their fmt.Sprint rendering. The params and FormattedMessage are not kept.
+func FromEntry(e *log.Entry) *Entry {
+	m := &Entry{
+		Seq:       e.Seq,
+		Level:     int32(e.Level),
+		Category:  e.Category,
+		Message:   e.Message,
+		CallStack: e.CallStack,
+		Fields:    fromFields(e.Fields),
+	}
+	if !e.Time.IsZero() {
+		m.TimeUnixNano = e.Time.UnixNano()
+	}
+	return m
+}
+
+// fromFields converts the fields of a log entry to the values supported by the Value message.
+func fromFields(fields map[string]interface{}) log.Fields {
+	if len(fields) == 0 {
+		return nil
+	}
+	ret := make(log.Fields, len(fields))
+	for name, value := range fields {
+		ret[name] = fromValue(value)
+	}
+	return ret
+}
+
+// fromValue converts a field value to one of the values supported by the Value message.
+func fromValue(value interface{}) interface{} {
+	// at most 100 levels are resolved, in case a value returns itself
+	for i := 0; i < 100; i++ {
+		m, ok := value.(log.FieldMarshaler)
+		if !ok {
+			break
+		}
+		value = m.LogValue()
+	}
+	switch v := value.(type) {
+	case nil, string, bool, int64, float64, []byte:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return fromUint(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return fromUint(v)
+	case float32:
+		return float64(v)
+	case log.Fields:
+		return fromFields(v)
+	case map[string]interface{}:
+		return fromFields(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// fromUint converts an unsigned integer to an int64, or to a string if it overflows int64.
+func fromUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return fmt.Sprint(v)
+	}
+	return int64(v)
+}
+
+// ToEntry converts the message to a log entry. FormattedMessage is left empty, so the entry must be logged again
+// (e.g. by Logger.LogEntry) to be formatted before it is processed by targets.
+func (m *Entry) ToEntry() *log.Entry {
+	e := &log.Entry{
+		Seq:       m.Seq,
+		Level:     log.Level(m.Level),
+		Category:  m.Category,
+		Message:   m.Message,
+		CallStack: m.CallStack,
+		Fields:    m.Fields,
+	}
+	if m.TimeUnixNano != 0 {
+		e.Time = time.Unix(0, m.TimeUnixNano)
+	}
+	return e
+}
+
+// the wire types of the protobuf encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Marshal returns the protobuf encoding of the message. The fields are encoded in the order of their names,
+// so that the encoding of a message is deterministic.
+func (m *Entry) Marshal() []byte {
+	var b []byte
+	if m.Seq != 0 {
+		b = appendTag(b, 1, wireVarint)
+		b = binary.AppendUvarint(b, m.Seq)
+	}
+	if m.Level != 0 {
+		b = appendTag(b, 2, wireVarint)
+		// negative int32 values are sign-extended to 64 bits
+		b = binary.AppendUvarint(b, uint64(int64(m.Level)))
+	}
+	b = appendString(b, 3, m.Category)
+	b = appendString(b, 4, m.Message)
+	if m.TimeUnixNano != 0 {
+		b = appendTag(b, 5, wireVarint)
+		b = binary.AppendUvarint(b, uint64(m.TimeUnixNano))
+	}
+	b = appendString(b, 6, m.CallStack)
+	return appendFields(b, 7, m.Fields)
+}
+
+// appendTag appends the key of a field with the given number and wire type.
+func appendTag(b []byte, num int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(wire))
+}
+
+// appendBytes appends a length-delimited field.
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendString appends a string field, unless it is empty.
+func appendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendFields appends a map<string, Value> field as a map entry message per field, in the order of their names.
+func appendFields(b []byte, num int, fields log.Fields) []byte {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// the key and the value of a map entry are always written, as done by protoc-generated code
+		entry := appendTag(nil, 1, wireBytes)
+		entry = binary.AppendUvarint(entry, uint64(len(name)))
+		entry = append(entry, name...)
+		entry = appendBytes(entry, 2, marshalValue(fields[name]))
+		b = appendBytes(b, num, entry)
+	}
+	return b
+}
+
+// marshalValue returns the encoding of a Value message, converting the value as done by FromEntry.
+func marshalValue(value interface{}) []byte {
+	var b []byte
+	switch v := fromValue(value).(type) {
+	case string:
+		b = appendTag(b, 1, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		b = append(b, v...)
+	case bool:
+		b = appendTag(b, 2, wireVarint)
+		if v {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case int64:
+		b = appendTag(b, 3, wireVarint)
+		b = binary.AppendUvarint(b, uint64(v))
+	case float64:
+		b = appendTag(b, 4, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	case []byte:
+		b = appendBytes(b, 5, v)
+	case log.Fields:
+		b = appendBytes(b, 6, appendFields(nil, 1, v))
+	}
+	return b
+}
+
+// errTruncated is returned when a message ends in the middle of a field.
+var errTruncated = errors.New("truncated message")
+
+// Unmarshal decodes the protobuf encoding of an Entry message into m. Unknown fields are skipped,
+// so that messages written with a newer schema can be read.
+func (m *Entry) Unmarshal(data []byte) error {
+	*m = Entry{}
+	return decodeFields(data, func(num int, wire int, v uint64, data []byte) error {
+		switch {
+		case num == 1 && wire == wireVarint:
+			m.Seq = v
+		case num == 2 && wire == wireVarint:
+			m.Level = int32(v)
+		case num == 3 && wire == wireBytes:
+			m.Category = string(data)
+		case num == 4 && wire == wireBytes:
+			m.Message = string(data)
+		case num == 5 && wire == wireVarint:
+			m.TimeUnixNano = int64(v)
+		case num == 6 && wire == wireBytes:
+			m.CallStack = string(data)
+		case num == 7 && wire == wireBytes:
+			if m.Fields == nil {
+				m.Fields = make(log.Fields)
+			}
+			return unmarshalField(data, m.Fields)
+		}
+		return nil
+	})
+}
+
+// unmarshalField decodes a map entry of a map<string, Value> field into fields.
+func unmarshalField(data []byte, fields log.Fields) error {
+	var name string
+	var value interface{}
+	err := decodeFields(data, func(num int, wire int, v uint64, data []byte) error {
+		var err error
+		switch {
+		case num == 1 && wire == wireBytes:
+			name = string(data)
+		case num == 2 && wire == wireBytes:
+			value, err = unmarshalValue(data)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("field %q: %v", name, err)
+	}
+	fields[name] = value
+	return nil
+}
+
+// unmarshalValue decodes a Value message. A value without any kind set is decoded as nil.
+func unmarshalValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := decodeFields(data, func(num int, wire int, v uint64, data []byte) error {
+		switch {
+		case num == 1 && wire == wireBytes:
+			value = string(data)
+		case num == 2 && wire == wireVarint:
+			value = v != 0
+		case num == 3 && wire == wireVarint:
+			value = int64(v)
+		case num == 4 && wire == wireFixed64:
+			value = math.Float64frombits(v)
+		case num == 5 && wire == wireBytes:
+			value = append([]byte{}, data...)
+		case num == 6 && wire == wireBytes:
+			group := make(log.Fields)
+			value = group
+			return decodeFields(data, func(num int, wire int, v uint64, data []byte) error {
+				if num == 1 && wire == wireBytes {
+					return unmarshalField(data, group)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return value, err
+}
+
+// decodeFields calls fn for every field of an encoded message, with its number, its wire type, and its value:
+// the number itself for the varint and fixed fields, or the bytes for the length-delimited ones.
+func decodeFields(data []byte, fn func(num int, wire int, v uint64, data []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		num, wire := int(key>>3), int(key&7)
+		if num == 0 {
+			return errors.New("invalid field number 0")
+		}
+		var v uint64
+		var bytes []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errTruncated
+			}
+			bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported wire type %v of field %v", wire, num)
+		}
+		if err := fn(num, wire, v, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logpb_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log"
+	"github.com/go-ozzo/ozzo-log/logpb"
+)
+
+func TestEntryMarshal(t *testing.T) {
+	m := &logpb.Entry{Seq: 1, Level: 3, Category: "db", Fields: log.Fields{"n": int64(5)}}
+	expected := []byte{0x08, 0x01, 0x10, 0x03, 0x1a, 0x02, 'd', 'b', 0x3a, 0x07, 0x0a, 0x01, 'n', 0x12, 0x02, 0x18, 0x05}
+	if data := m.Marshal(); !bytes.Equal(data, expected) {
+		t.Errorf("Marshal() = % x, expected % x", data, expected)
+	}
+}
+
+func TestEntryRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	e := &log.Entry{
+		Seq:       42,
+		Level:     log.LevelError,
+		Category:  "db",
+		Message:   "query failed",
+		Time:      at,
+		CallStack: "\nmain.go:12",
+		Fields: log.Fields{
+			"table":   "users",
+			"ok":      false,
+			"ms":      120,
+			"max":     uint64(math.MaxUint64),
+			"ratio":   -0.5,
+			"raw":     []byte{0, 1},
+			"err":     errors.New("timeout"),
+			"level":   log.LevelDebug,
+			"missing": nil,
+			"http":    log.Fields{"status": 500, "empty": log.Fields{}},
+		},
+	}
+	var m logpb.Entry
+	if err := m.Unmarshal(logpb.FromEntry(e).Marshal()); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	decoded := m.ToEntry()
+	if decoded.Seq != 42 || decoded.Level != log.LevelError || decoded.Category != "db" || decoded.Message != "query failed" ||
+		!decoded.Time.Equal(at) || decoded.CallStack != "\nmain.go:12" {
+		t.Errorf("entry = %+v, expected %+v", decoded, e)
+	}
+	expected := log.Fields{
+		"table":   "users",
+		"ok":      false,
+		"ms":      int64(120),
+		"max":     "18446744073709551615",
+		"ratio":   -0.5,
+		"raw":     []byte{0, 1},
+		"err":     "timeout",
+		"level":   "Debug",
+		"missing": nil,
+		"http":    log.Fields{"status": int64(500), "empty": log.Fields{}},
+	}
+	if !reflect.DeepEqual(decoded.Fields, expected) {
+		t.Errorf("fields = %#v, expected %#v", decoded.Fields, expected)
+	}
+
+	var empty logpb.Entry
+	if err := empty.Unmarshal(nil); err != nil || !reflect.DeepEqual(empty, logpb.Entry{}) {
+		t.Errorf("Unmarshal(nil) = %v, %+v, expected an empty entry", err, empty)
+	}
+	if m := (&logpb.Entry{Level: -1}); m.Unmarshal(m.Marshal()) != nil || m.Level != -1 {
+		t.Errorf("a negative level decoded as %v, expected -1", m.Level)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.pb")
+	logger := log.NewLogger()
+	logger.Formatter = logpb.Formatter
+	logger.CallStackDepth = 2
+	logger.CallStackMaxLevel = log.LevelError
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.TrailingNewline = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.WithField("user", "alice").Info("t1 %v", 1)
+	logger.GetLogger("db").Error("t2\nsecond line")
+	logger.Close()
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	scanner := logpb.NewScanner(file)
+	var entries []*log.Entry
+	for scanner.Scan() {
+		entries = append(entries, scanner.Entry())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Errorf("scanner.Err() = %v, expected nil", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("number of entries = %v, expected 2", len(entries))
+	}
+	if e := entries[0]; e.Seq != 1 || e.Level != log.LevelInfo || e.Category != "app" || e.Message != "t1 1" || e.Fields["user"] != "alice" || e.Time.IsZero() {
+		t.Errorf("entries[0] = %+v, expected t1", e)
+	}
+	if e := entries[1]; e.Level != log.LevelError || e.Category != "db" || e.Message != "t2\nsecond line" || e.CallStack == "" {
+		t.Errorf("entries[1] = %+v, expected t2 with its call stack", e)
+	}
+}
+
+func TestScannerMalformed(t *testing.T) {
+	good := logpb.Formatter(log.NewLogger(), &log.Entry{Level: log.LevelInfo, Message: "ok"})
+	// a message whose varint field is truncated, followed by a valid one and a truncated stream
+	stream := "\x02\x08\x80" + good + "\x05\x08"
+	scanner := logpb.NewScanner(strings.NewReader(stream))
+	var messages []string
+	for scanner.Scan() {
+		messages = append(messages, scanner.Entry().Message)
+	}
+	if len(messages) != 1 || messages[0] != "ok" {
+		t.Errorf("messages = %q, expected the valid message only", messages)
+	}
+	err := scanner.Err()
+	if err == nil || err.Error() != "message 1: truncated message; message 3: unexpected EOF" {
+		t.Errorf("scanner.Err() = %v, expected the malformed and the truncated messages", err)
+	}
+
+	scanner = logpb.NewScanner(bytes.NewReader(nil))
+	if scanner.Scan() || scanner.Err() != nil {
+		t.Errorf("an empty stream was not read without error")
+	}
+}
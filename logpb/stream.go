@@ -0,0 +1,112 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logpb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+// maxMessageLen is the maximum length of a message read by Scanner.
+const maxMessageLen = 16 << 20
+
+// Formatter formats a log entry as an Entry message preceded by its length, so that a target writing its
+// messages as is produces a stream that Scanner can read. The TrailingNewline option of FileTarget and
+// NetworkTarget must therefore be false, e.g.
+//
+//	target := log.NewFileTarget()
+//	target.FileName = "app.pb"
+//	target.TrailingNewline = false
+//	logger.Formatter = logpb.Formatter
+func Formatter(l *log.Logger, e *log.Entry) string {
+	data := FromEntry(e).Marshal()
+	buf := binary.AppendUvarint(make([]byte, 0, len(data)+binary.MaxVarintLen32), uint64(len(data)))
+	return string(append(buf, data...))
+}
+
+// Scanner reads back the log entries written by Formatter, e.g. from a file written by FileTarget. It is used
+// like log.EntryScanner:
+//
+//	scanner := logpb.NewScanner(file)
+//	for scanner.Scan() {
+//		entry := scanner.Entry()
+//		...
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+//
+// The entries are converted by Entry.ToEntry. Malformed messages are skipped and reported by Err, while scanning
+// continues with the next message. Scanning stops if the reader fails, the stream ends in the middle of a message,
+// or a message is longer than 16MB, since the start of the next message cannot be found then.
+type Scanner struct {
+	reader  *bufio.Reader
+	message int
+	entry   *log.Entry
+	errs    log.Errors
+}
+
+// NewScanner creates a Scanner reading from the given reader.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{reader: bufio.NewReader(r)}
+}
+
+// Scan advances the scanner to the next log entry, which is then available through Entry.
+// It returns false when there are no more entries or the stream cannot be read further.
+func (s *Scanner) Scan() bool {
+	s.entry = nil
+	for {
+		size, err := binary.ReadUvarint(s.reader)
+		if err == io.EOF {
+			return false
+		}
+		s.message++
+		if err != nil {
+			s.fail(err)
+			return false
+		}
+		if size > maxMessageLen {
+			s.fail(fmt.Errorf("the length %v exceeds the maximum of %v bytes", size, maxMessageLen))
+			return false
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			s.fail(err)
+			return false
+		}
+		var m Entry
+		if err := m.Unmarshal(data); err != nil {
+			s.fail(err)
+			continue
+		}
+		s.entry = m.ToEntry()
+		return true
+	}
+}
+
+// fail records an error of the current message.
+func (s *Scanner) fail(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	s.errs = append(s.errs, fmt.Errorf("message %v: %v", s.message, err))
+}
+
+// Entry returns the log entry read by the last call to Scan.
+func (s *Scanner) Entry() *log.Entry {
+	return s.entry
+}
+
+// Err returns the errors of the malformed messages skipped so far, followed by the error of the reader, if any.
+func (s *Scanner) Err() error {
+	if len(s.errs) > 0 {
+		return s.errs
+	}
+	return nil
+}
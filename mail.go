@@ -15,13 +15,15 @@ import (
 // MailTarget sends log messages in emails via an SMTP server.
 type MailTarget struct {
 	*Filter
-	Host       string   // SMTP server address
-	Username   string   // SMTP server login username
-	Password   string   // SMTP server login password
-	Subject    string   // the mail subject
-	Sender     string   // the mail sender
-	Recipients []string // the mail recipients
-	BufferSize int      // the size of the message channel.
+	Name       string            // the name identifying the target in diagnostics
+	Tags       map[string]string // the tags identifying the target in diagnostics
+	Host       string            // SMTP server address
+	Username   string            // SMTP server login username
+	Password   string            // SMTP server login password
+	Subject    string            // the mail subject
+	Sender     string            // the mail sender
+	Recipients []string          // the mail recipients
+	BufferSize int               // the size of the message channel.
 
 	entries chan *Entry
 	close   chan bool
@@ -96,7 +98,7 @@ func (t *MailTarget) sendMessages(errWriter io.Writer) {
 			break
 		}
 		if err := t.write(auth, entry.String()+"\n"); err != nil {
-			fmt.Fprintf(errWriter, "MailTarget write error: %v\n", err)
+			fmt.Fprintf(errWriter, "%v write error: %v\n", t.label(), err)
 		}
 	}
 }
@@ -110,3 +112,13 @@ func (t *MailTarget) write(auth smtp.Auth, message string) error {
 	)
 	return smtp.SendMail(t.Host, auth, t.Sender, t.Recipients, []byte(msg))
 }
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *MailTarget) Describe() TargetInfo {
+	return newTargetInfo("MailTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *MailTarget) label() string {
+	return targetLabel("MailTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// Middleware transforms a log entry before it is formatted and sent to the targets, e.g. to add fields,
+// rewrite the category or redact secrets. It may modify the entry and return it, return another entry,
+// or return nil to drop the entry.
+type Middleware func(*Entry) *Entry
+
+// Use appends middleware to the chain applied to the entries of the logger and the loggers derived from it.
+// For example,
+//
+//	logger.Use(func(e *log.Entry) *log.Entry {
+//		if _, ok := e.Fields["password"]; ok {
+//			e.Fields["password"] = "***"
+//		}
+//		return e
+//	})
+//
+// The middleware runs in the order it is added, each one receiving the entry returned by the previous one;
+// once one returns nil, the entry is dropped and the rest of the chain is skipped. It runs after the entry has
+// passed the level filtering and sampling and its fields have been merged with those of the logger, so the Fields
+// map is owned by the entry and may be modified (but may be nil), and before the entry is numbered and formatted,
+// so the dropped entries do not use up sequence numbers. Middleware runs on the goroutine logging the entry,
+// which it therefore delays, and may run concurrently for entries logged by several goroutines.
+// Use may be called while messages are being logged.
+func (l *Logger) Use(mw ...Middleware) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	chain := l.middleware()
+	// copy the chain so that the one being used by the log calls is not modified
+	l.chain.Store(append(chain[:len(chain):len(chain)], mw...))
+}
+
+// middleware returns the chain added by Use.
+func (l *coreLogger) middleware() []Middleware {
+	chain, _ := l.chain.Load().([]Middleware)
+	return chain
+}
+
+// applyMiddleware runs the chain added by Use on an entry, and returns the resulting entry or nil if it is dropped.
+func (l *coreLogger) applyMiddleware(entry *Entry) *Entry {
+	for _, mw := range l.middleware() {
+		if entry = mw(entry); entry == nil {
+			return nil
+		}
+	}
+	return entry
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoggerUse(t *testing.T) {
+	logger := NewLogger()
+	logger.Formatter = LogfmtFormatter
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	var order []string
+	logger.Use(func(e *Entry) *Entry {
+		order = append(order, "region")
+		if e.Fields == nil {
+			e.Fields = Fields{}
+		}
+		e.Fields["region"] = "eu"
+		return e
+	})
+	// the middleware added to a derived logger applies to the root logger too
+	logger.GetLogger("sql").Use(func(e *Entry) *Entry {
+		order = append(order, "category")
+		if strings.HasPrefix(e.Category, "sql") {
+			e.Category = "db" + strings.TrimPrefix(e.Category, "sql")
+		}
+		return e
+	}, func(e *Entry) *Entry {
+		order = append(order, "redact")
+		if _, ok := e.Fields["password"]; ok {
+			e.Fields["password"] = "***"
+		}
+		return e
+	})
+	logger.Open()
+	logger.GetLogger("sql.users").WithField("password", "secret").Info("login")
+	logger.Close()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("number of entries = %v, expected 1", len(target.entries))
+	}
+	e := target.entries[0]
+	expected := `level=Info category=db.users message=login seq=1 password=*** region=eu`
+	if !strings.HasSuffix(e.FormattedMessage, " "+expected) {
+		t.Errorf("FormattedMessage = %v, expected %v", e.FormattedMessage, expected)
+	}
+	if got := strings.Join(order, ","); got != "region,category,redact" {
+		t.Errorf("middleware order = %v, expected region,category,redact", got)
+	}
+}
+
+func TestLoggerUseDrop(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	calls := 0
+	logger.Use(func(e *Entry) *Entry {
+		if e.Fields["health"] == true {
+			return nil
+		}
+		return e
+	}, func(e *Entry) *Entry {
+		calls++
+		return e
+	})
+	logger.Open()
+	logger.WithField("health", true).Info("GET /health")
+	logger.Info("GET /orders")
+	logger.Close()
+
+	if len(target.entries) != 1 || target.entries[0].Message != "GET /orders" {
+		t.Fatalf("entries = %v, expected the health check to be dropped", target.entries)
+	}
+	if target.entries[0].Seq != 1 {
+		t.Errorf("Seq = %v, expected the dropped entry not to use a sequence number", target.entries[0].Seq)
+	}
+	if calls != 1 {
+		t.Errorf("the rest of the chain ran %v times, expected it to be skipped for the dropped entry", calls)
+	}
+}
@@ -14,6 +14,10 @@ import (
 // NetworkTarget sends log messages over a network connection.
 type NetworkTarget struct {
 	*Filter
+	// the name identifying the target in diagnostics.
+	Name string
+	// the tags identifying the target in diagnostics.
+	Tags map[string]string
 	// the network to connect to. Valid networks include
 	// tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
 	// "udp", "udp4" (IPv4-only), "udp6" (IPv6-only), "ip", "ip4"
@@ -29,24 +33,31 @@ type NetworkTarget struct {
 	// If this is false, for every message to be sent, a network
 	// connection will be open and closed.
 	Persistent bool
-	// the size of the message channel.
+	// the size of the message channel. 0 means the BufferSize of the logger, which must then be positive.
+	// Messages are dropped when the channel is full.
 	BufferSize int
-
-	entries chan *Entry
-	conn    net.Conn
-	close   chan bool
+	// whether to append a newline to every message. Disable it if the receiver expects no record terminator.
+	TrailingNewline bool
+
+	entries           chan *Entry
+	defaultBufferSize int
+	conn              net.Conn
+	closeErr          error
+	failures          writeFailures
+	close             chan bool
 }
 
 // NewNetworkTarget creates a NetworkTarget.
 // The new NetworkTarget takes these default options:
-// MaxLevel: LevelDebug, Persistent: true, BufferSize: 1024.
+// MaxLevel: LevelDebug, Persistent: true, BufferSize: 1024, TrailingNewline: true.
 // You must specify the Network and Address fields.
 func NewNetworkTarget() *NetworkTarget {
 	return &NetworkTarget{
-		Filter:     &Filter{MaxLevel: LevelDebug},
-		BufferSize: 1024,
-		Persistent: true,
-		close:      make(chan bool, 0),
+		Filter:          &Filter{MaxLevel: LevelDebug},
+		BufferSize:      1024,
+		Persistent:      true,
+		TrailingNewline: true,
+		close:           make(chan bool, 0),
 	}
 }
 
@@ -64,8 +75,17 @@ func (t *NetworkTarget) Open(errWriter io.Writer) error {
 		return errors.New("NetworkTarget.Address must be specified")
 	}
 
-	t.entries = make(chan *Entry, t.BufferSize)
+	bufferSize := t.BufferSize
+	if bufferSize == 0 {
+		bufferSize = t.defaultBufferSize
+	}
+	if bufferSize == 0 {
+		// an unbuffered channel would drop almost every message
+		return errors.New("NetworkTarget.BufferSize must be positive when the BufferSize of the logger is 0")
+	}
+	t.entries = make(chan *Entry, bufferSize)
 	t.conn = nil
+	t.failures = writeFailures{}
 
 	if t.Persistent {
 		if err := t.connect(); err != nil {
@@ -78,8 +98,17 @@ func (t *NetworkTarget) Open(errWriter io.Writer) error {
 	return nil
 }
 
+func (t *NetworkTarget) setDefaultBufferSize(size int) {
+	t.defaultBufferSize = size
+}
+
 // Process puts filtered log messages into a channel for sending over network.
+// The messages are dropped when the channel is full, but not the nil entry closing the target.
 func (t *NetworkTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
 	if t.Allow(e) {
 		select {
 		case t.entries <- e:
@@ -90,7 +119,19 @@ func (t *NetworkTarget) Process(e *Entry) {
 
 // Close closes the network target.
 func (t *NetworkTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the network target and returns the errors occurred when sending messages
+// or when closing the connection.
+func (t *NetworkTarget) CloseError() error {
 	<-t.close
+	return t.closeErr
+}
+
+// FailureStreak returns the number of consecutive messages that could not be sent.
+func (t *NetworkTarget) FailureStreak() int {
+	return t.failures.failureStreak()
 }
 
 func (t *NetworkTarget) connect() error {
@@ -101,7 +142,7 @@ func (t *NetworkTarget) connect() error {
 
 	conn, err := net.Dial(t.Network, t.Address)
 	if err != nil {
-		return err
+		return fmt.Errorf("%v was unable to connect to %v: %v", t.label(), t.Address, err)
 	}
 
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
@@ -116,14 +157,25 @@ func (t *NetworkTarget) sendMessages(errWriter io.Writer) {
 	for {
 		entry := <-t.entries
 		if entry == nil {
+			var err error
 			if t.conn != nil {
-				t.conn.Close()
+				if err = t.conn.Close(); err != nil {
+					err = fmt.Errorf("%v was unable to close the connection: %v", t.label(), err)
+				}
 			}
+			t.closeErr = joinErrors(t.failures.err(t.label()), err)
 			t.close <- true
 			break
 		}
-		if err := t.write(entry.String() + "\n"); err != nil {
-			fmt.Fprintf(errWriter, "NetworkTarget write error: %v\n", err)
+		msg := entry.String()
+		if t.TrailingNewline {
+			msg += "\n"
+		}
+		if err := t.write(msg); err != nil {
+			t.failures.add(err)
+			fmt.Fprintf(errWriter, "%v write error: %v\n", t.label(), err)
+		} else {
+			t.failures.ok()
 		}
 	}
 }
@@ -138,3 +190,13 @@ func (t *NetworkTarget) write(message string) error {
 	_, err := t.conn.Write([]byte(message))
 	return err
 }
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *NetworkTarget) Describe() TargetInfo {
+	return newTargetInfo("NetworkTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *NetworkTarget) label() string {
+	return targetLabel("NetworkTarget", t.Name, t.Tags)
+}
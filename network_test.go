@@ -8,6 +8,7 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-ozzo/ozzo-log"
 )
@@ -83,3 +84,33 @@ func TestNetworkTarget(t *testing.T) {
 		t.Errorf("Expected %q not found", "t2: 3")
 	}
 }
+
+func TestNetworkTargetUnbufferedLogger(t *testing.T) {
+	var errWriter strings.Builder
+	logger := log.NewLogger()
+	logger.BufferSize = 0
+	logger.ErrorWriter = &errWriter
+	t1 := log.NewNetworkTarget()
+	t1.Network, t1.Address, t1.Persistent = "tcp", "127.0.0.1:1", false
+	t1.BufferSize = 0
+	t2 := log.NewNetworkTarget()
+	t2.Network, t2.Address, t2.Persistent = "tcp", "127.0.0.1:1", false
+	t2.BufferSize = 8
+	logger.Targets = append(logger.Targets, t1, t2)
+	logger.Open()
+	if !strings.Contains(errWriter.String(), "NetworkTarget.BufferSize must be positive") {
+		t.Errorf("ErrorWriter = %q, expected the target without a buffer to fail to open", errWriter.String())
+	}
+
+	logger.Info("t1")
+	done := make(chan bool)
+	go func() {
+		logger.CloseError()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseError did not return")
+	}
+}
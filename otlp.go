@@ -0,0 +1,381 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log/internal/batching"
+)
+
+// OTLPTarget exports filtered log entries in batches to an OpenTelemetry collector or backend using the
+// OTLP/HTTP logs protocol with the JSON encoding (the protobuf encoding is not supported, so that the package
+// does not depend on a protobuf library).
+//
+// Every entry is exported as a log record whose body is the message, whose severity number is mapped from the
+// level (Debug to DEBUG, Info to INFO, Notice to INFO2, Warning to WARN, Error to ERROR, Critical to FATAL,
+// Alert to FATAL3 and Emergency to FATAL4; the custom levels less severe than Debug are exported as TRACE) and
+// whose attributes are the fields, with the groups exported as nested key-value lists. The call stack, if any,
+// is exported as the "code.stacktrace" attribute. The records are grouped by category, which is exported as the
+// name of the instrumentation scope, like the logger names of the OpenTelemetry log bridges.
+//
+// The batches are posted to Endpoint with the "Content-Type: application/json" header required by OTLP/HTTP,
+// plus Headers, which usually carry the credentials expected by the backend, e.g. "Authorization: Bearer <token>"
+// or a vendor-specific API key header. A batch is sent when it has BatchSize entries, every FlushInterval and
+// when the target is closed. If the request fails, or the response status is 429, 502, 503 or 504, the batch is
+// sent again after RetryInterval, up to MaxRetries times; a batch still failing after that, or rejected with
+// another status, is dropped and reported to the error writer of the logger, and the number of dropped entries
+// is reported by CloseError, as are the records rejected by a partial success response.
+//
+// Entries are queued in a channel of BufferSize entries. When it is full, the logger waits for room.
+type OTLPTarget struct {
+	*Filter
+	Name          string            // the name identifying the target in diagnostics
+	Tags          map[string]string // the tags identifying the target in diagnostics
+	Endpoint      string            // the URL of the OTLP/HTTP logs endpoint
+	Headers       map[string]string // the additional headers of the requests, e.g. for authentication
+	Resource      Fields            // the attributes of the resource producing the entries, e.g. "service.name"
+	BatchSize     int               // the maximum number of entries sent in a request
+	FlushInterval time.Duration     // how often a partial batch is sent
+	Timeout       time.Duration     // the time allowed for a request. 0 means no limit.
+	MaxRetries    int               // how many times a failed batch is sent again before it is dropped
+	RetryInterval time.Duration     // the time waited before sending a failed batch again
+	BufferSize    int               // the size of the entry channel. 0 means the BufferSize of the logger.
+	// the client sending the requests. Nil means a client with Timeout.
+	Client *http.Client
+
+	entries           chan *Entry
+	defaultBufferSize int
+	client            *http.Client
+	batch             []*Entry       // the entries to be sent in the next request
+	drops             batching.Drops // the entries in the dropped batches and the rejected records
+	closeErr          error
+	close             chan bool
+}
+
+// NewOTLPTarget creates an OTLPTarget.
+// The new OTLPTarget takes these default options:
+// MaxLevel: LevelDebug, Endpoint: "http://localhost:4318/v1/logs", BatchSize: 100, FlushInterval: 1s,
+// Timeout: 10s, MaxRetries: 3, RetryInterval: 1s, BufferSize: 1024.
+func NewOTLPTarget() *OTLPTarget {
+	return &OTLPTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		Endpoint:      "http://localhost:4318/v1/logs",
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Timeout:       10 * time.Second,
+		MaxRetries:    3,
+		RetryInterval: time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares OTLPTarget for processing log messages.
+func (t *OTLPTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Endpoint == "" {
+		return errors.New("OTLPTarget.Endpoint must be specified")
+	}
+	if t.BatchSize <= 0 {
+		return errors.New("OTLPTarget.BatchSize must be greater than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("OTLPTarget.BufferSize must be no less than 0")
+	}
+	if t.MaxRetries < 0 {
+		return errors.New("OTLPTarget.MaxRetries must be no less than 0")
+	}
+	t.client = t.Client
+	if t.client == nil {
+		t.client = &http.Client{Timeout: t.Timeout}
+	}
+	bufferSize := t.BufferSize
+	if bufferSize == 0 {
+		bufferSize = t.defaultBufferSize
+	}
+	t.entries = make(chan *Entry, bufferSize)
+	t.batch = nil
+	t.drops = batching.Drops{Writer: errWriter}
+	go t.exportBatches()
+	return nil
+}
+
+func (t *OTLPTarget) setDefaultBufferSize(size int) {
+	t.defaultBufferSize = size
+}
+
+// Process puts an allowed log entry into the channel of the entries to be exported.
+func (t *OTLPTarget) Process(e *Entry) {
+	if e == nil || t.Allow(e) {
+		t.entries <- e
+	}
+}
+
+// Close closes the OTLP target.
+func (t *OTLPTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the OTLP target after exporting the pending entries,
+// and returns an error if some entries were not delivered.
+func (t *OTLPTarget) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// exportBatches collects the entries into batches and exports them until the nil entry is received.
+func (t *OTLPTarget) exportBatches() {
+	batching.Run(t.entries, t.FlushInterval, func(e *Entry) {
+		t.batch = append(t.batch, e)
+		if len(t.batch) >= t.BatchSize {
+			t.flush()
+		}
+	}, t.flush)
+	t.closeErr = t.drops.Err(t.label(), "deliver", "entries")
+	t.close <- true
+}
+
+// flush exports the pending batch.
+func (t *OTLPTarget) flush() {
+	t.export(t.batch)
+	t.batch = nil
+}
+
+// export sends a batch until it is accepted, it is rejected or the retries are exhausted.
+func (t *OTLPTarget) export(batch []*Entry) {
+	if len(batch) == 0 {
+		return
+	}
+	data, err := json.Marshal(t.request(batch))
+	if err != nil {
+		t.drop(batch, err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		if retryable, err = t.post(data); err == nil {
+			return
+		}
+		if !retryable || attempt >= t.MaxRetries {
+			break
+		}
+		time.Sleep(t.RetryInterval)
+	}
+	t.drop(batch, err)
+}
+
+// drop records a batch that cannot be delivered.
+func (t *OTLPTarget) drop(batch []*Entry, err error) {
+	t.drops.Drop(t.label(), len(batch), "entries", err)
+}
+
+// post sends an export request. It returns an error if the request is not accepted,
+// and whether it may be accepted if it is sent again.
+func (t *OTLPTarget) post(data []byte) (bool, error) {
+	req, err := http.NewRequest("POST", t.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		var result otlpResponse
+		if json.Unmarshal(body, &result) == nil && result.PartialSuccess != nil {
+			if rejected, _ := result.PartialSuccess.RejectedLogRecords.Int64(); rejected > 0 {
+				t.drops.Count += int(rejected)
+				fmt.Fprintf(t.drops.Writer, "%v had %v records rejected: %v\n", t.label(), rejected, result.PartialSuccess.ErrorMessage)
+			}
+		}
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("the endpoint replied %v", resp.Status)
+	}
+	return false, fmt.Errorf("the endpoint replied %v: %s", resp.Status, bytes.TrimSpace(body))
+}
+
+// otlpResponse is the part of an export response reporting the rejected records.
+type otlpResponse struct {
+	PartialSuccess *struct {
+		RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+		ErrorMessage       string      `json:"errorMessage"`
+	} `json:"partialSuccess"`
+}
+
+// otlpKeyValue is an attribute of a resource or a log record.
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpRecord is a log record of an export request.
+type otlpRecord struct {
+	TimeUnixNano   string                 `json:"timeUnixNano,omitempty"`
+	SeverityNumber int                    `json:"severityNumber"`
+	SeverityText   string                 `json:"severityText"`
+	Body           map[string]interface{} `json:"body"`
+	Attributes     []otlpKeyValue         `json:"attributes,omitempty"`
+}
+
+// otlpScopeLogs is the log records of an instrumentation scope, i.e. a category.
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpRecord `json:"logRecords"`
+}
+
+// request builds the export request of a batch, with the entries grouped by category.
+func (t *OTLPTarget) request(batch []*Entry) interface{} {
+	var scopes []*otlpScopeLogs
+	index := map[string]*otlpScopeLogs{}
+	for _, e := range batch {
+		scope, ok := index[e.Category]
+		if !ok {
+			scope = &otlpScopeLogs{}
+			scope.Scope.Name = e.Category
+			index[e.Category] = scope
+			scopes = append(scopes, scope)
+		}
+		scope.LogRecords = append(scope.LogRecords, otlpLogRecord(e))
+	}
+	return map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource":  map[string]interface{}{"attributes": otlpAttributes(t.Resource)},
+				"scopeLogs": scopes,
+			},
+		},
+	}
+}
+
+// otlpLogRecord converts an entry into a log record.
+func otlpLogRecord(e *Entry) otlpRecord {
+	record := otlpRecord{
+		SeverityNumber: otlpSeverity(e.Level),
+		SeverityText:   e.Level.String(),
+		Body:           otlpValue(e.Message),
+		Attributes:     otlpAttributes(e.Fields),
+	}
+	if !e.Time.IsZero() {
+		record.TimeUnixNano = strconv.FormatInt(e.Time.UnixNano(), 10)
+	}
+	if e.CallStack != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue{"code.stacktrace", otlpValue(e.CallStack[1:])})
+	}
+	return record
+}
+
+// otlpSeverity maps a level to an OTLP severity number.
+func otlpSeverity(level Level) int {
+	switch {
+	case level <= LevelEmergency:
+		return 24 // FATAL4
+	case level == LevelAlert:
+		return 23 // FATAL3
+	case level == LevelCritical:
+		return 21 // FATAL
+	case level == LevelError:
+		return 17 // ERROR
+	case level == LevelWarning:
+		return 13 // WARN
+	case level == LevelNotice:
+		return 10 // INFO2
+	case level == LevelInfo:
+		return 9 // INFO
+	case level == LevelDebug:
+		return 5 // DEBUG
+	}
+	return 1 // TRACE
+}
+
+// otlpAttributes converts fields into attributes sorted by name.
+func otlpAttributes(fields Fields) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(fields))
+	for _, f := range sortedFields(fields, false) {
+		attributes = append(attributes, otlpKeyValue{f.key, otlpValue(f.value)})
+	}
+	return attributes
+}
+
+// otlpValue converts a field value into an OTLP AnyValue encoded as JSON. The values which are not strings,
+// booleans, numbers, byte slices, groups, slices or arrays are exported as strings using fmt.Sprint.
+func otlpValue(value interface{}) map[string]interface{} {
+	value = logValue(value)
+	switch v := value.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case []byte:
+		return map[string]interface{}{"bytesValue": v}
+	case Fields:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": otlpAttributes(v)}}
+	case error:
+		return map[string]interface{}{"stringValue": v.Error()}
+	case fmt.Stringer:
+		return map[string]interface{}{"stringValue": v.String()}
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// the 64-bit integers are encoded as strings in the JSON encoding of protobuf
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() <= math.MaxInt64 {
+			return map[string]interface{}{"intValue": strconv.FormatUint(v.Uint(), 10)}
+		}
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return map[string]interface{}{"doubleValue": "NaN"}
+		case math.IsInf(f, 1):
+			return map[string]interface{}{"doubleValue": "Infinity"}
+		case math.IsInf(f, -1):
+			return map[string]interface{}{"doubleValue": "-Infinity"}
+		}
+		return map[string]interface{}{"doubleValue": f}
+	case reflect.Slice, reflect.Array:
+		values := make([]map[string]interface{}, v.Len())
+		for i := range values {
+			values[i] = otlpValue(v.Index(i).Interface())
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *OTLPTarget) Describe() TargetInfo {
+	return newTargetInfo("OTLPTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *OTLPTarget) label() string {
+	return targetLabel("OTLPTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// otlpCollector records the requests of an OTLPTarget and replies with the given statuses in turn, then 200.
+type otlpCollector struct {
+	lock     sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   []string
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.headers = append(c.headers, r.Header)
+	c.bodies = append(c.bodies, string(body))
+	if len(c.statuses) > 0 {
+		w.WriteHeader(c.statuses[0])
+		c.statuses = c.statuses[1:]
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func TestOTLPTarget(t *testing.T) {
+	collector := &otlpCollector{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	logger := NewLogger()
+	target := NewOTLPTarget()
+	target.Endpoint = server.URL + "/v1/logs"
+	target.Headers = map[string]string{"Authorization": "Bearer secret"}
+	target.Resource = Fields{"service.name": "api"}
+	target.RetryInterval = 10 * time.Millisecond
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.GetLogger("db").WithFields(Fields{"table": "users", "ms": 120, "ok": false, "http": Fields{"status": 500}}).Error("query failed")
+	logger.Info("started")
+	if err := logger.CloseError(); err != nil {
+		t.Fatalf("logger.CloseError(): %v", err)
+	}
+
+	if len(collector.bodies) != 2 || collector.bodies[0] != collector.bodies[1] {
+		t.Fatalf("requests = %q, expected the same batch to be sent twice", collector.bodies)
+	}
+	header := collector.headers[1]
+	if header.Get("Content-Type") != "application/json" || header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("headers = %v, expected the JSON content type and the authorization", header)
+	}
+	var request struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []otlpKeyValue
+			}
+			ScopeLogs []struct {
+				Scope struct {
+					Name string
+				}
+				LogRecords []otlpRecord
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(collector.bodies[1]), &request); err != nil {
+		t.Fatalf("invalid request %v: %v", collector.bodies[1], err)
+	}
+	resource := request.ResourceLogs[0]
+	if data, _ := json.Marshal(resource.Resource.Attributes); string(data) != `[{"key":"service.name","value":{"stringValue":"api"}}]` {
+		t.Errorf("resource attributes = %s", data)
+	}
+	if len(resource.ScopeLogs) != 2 || resource.ScopeLogs[0].Scope.Name != "db" || resource.ScopeLogs[1].Scope.Name != "app" {
+		t.Fatalf("scopes = %+v, expected db and app", resource.ScopeLogs)
+	}
+	record := resource.ScopeLogs[0].LogRecords[0]
+	if record.SeverityNumber != 17 || record.SeverityText != "Error" || record.Body["stringValue"] != "query failed" || record.TimeUnixNano == "" {
+		t.Errorf("record = %+v, expected the error", record)
+	}
+	expected := `[{"key":"http","value":{"kvlistValue":{"values":[{"key":"status","value":{"intValue":"500"}}]}}},` +
+		`{"key":"ms","value":{"intValue":"120"}},{"key":"ok","value":{"boolValue":false}},{"key":"table","value":{"stringValue":"users"}}]`
+	if data, _ := json.Marshal(record.Attributes); string(data) != expected {
+		t.Errorf("attributes = %s, expected %s", data, expected)
+	}
+	if record := resource.ScopeLogs[1].LogRecords[0]; record.SeverityNumber != 9 || record.Body["stringValue"] != "started" {
+		t.Errorf("record = %+v, expected the info", record)
+	}
+}
+
+func TestOTLPTargetRejected(t *testing.T) {
+	collector := &otlpCollector{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	logger := NewLogger()
+	logger.ErrorWriter = ioutil.Discard
+	target := NewOTLPTarget()
+	target.Endpoint = server.URL
+	target.RetryInterval = 10 * time.Millisecond
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+
+	err := logger.CloseError()
+	if err == nil || !strings.Contains(err.Error(), "OTLPTarget was unable to deliver 2 entries") {
+		t.Errorf("logger.CloseError() = %v, expected the undelivered entries to be reported", err)
+	}
+	if len(collector.bodies) != 1 {
+		t.Errorf("%v requests were sent, expected a rejected batch not to be sent again", len(collector.bodies))
+	}
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	tests := []struct {
+		level    Level
+		expected int
+	}{
+		{LevelEmergency - 1, 24},
+		{LevelEmergency, 24},
+		{LevelAlert, 23},
+		{LevelCritical, 21},
+		{LevelError, 17},
+		{LevelWarning, 13},
+		{LevelNotice, 10},
+		{LevelInfo, 9},
+		{LevelDebug, 5},
+		{LevelDebug + 1, 1},
+	}
+	for _, test := range tests {
+		if severity := otlpSeverity(test.level); severity != test.expected {
+			t.Errorf("otlpSeverity(%v) = %v, expected %v", test.level, severity, test.expected)
+		}
+	}
+}
+
+func TestOTLPValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `{}`},
+		{uint64(math.MaxUint64), `{"stringValue":"18446744073709551615"}`},
+		{1.5, `{"doubleValue":1.5}`},
+		{math.Inf(-1), `{"doubleValue":"-Infinity"}`},
+		{[]byte("ab"), `{"bytesValue":"YWI="}`},
+		{[]interface{}{"a", 1}, `{"arrayValue":{"values":[{"stringValue":"a"},{"intValue":"1"}]}}`},
+		{LevelError, `{"stringValue":"Error"}`},
+		{struct{ A int }{1}, `{"stringValue":"{1}"}`},
+	}
+	for _, test := range tests {
+		if data, _ := json.Marshal(otlpValue(test.value)); string(data) != test.expected {
+			t.Errorf("otlpValue(%v) = %s, expected %s", test.value, data, test.expected)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package promlog counts the messages logged by ozzo-log loggers as Prometheus metrics.
+// It is a separate package so that the log package does not depend on the Prometheus client.
+package promlog
+
+import (
+	"io"
+
+	"github.com/go-ozzo/ozzo-log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Hook counts the log entries by level and category in a Prometheus counter.
+// It is attached to a logger as a target, so its Filter selects the entries that are counted.
+// For example,
+//
+//	hook := promlog.NewHook("myapp")
+//	prometheus.MustRegister(hook.Collector())
+//	logger.Targets = append(logger.Targets, hook)
+//
+// Every distinct category creates a new time series. If the categories are built from unbounded
+// values (e.g. user or request IDs), the number of series grows without limit, which degrades
+// Prometheus; use Categories to restrict the counted categories or set CategoryLabel to false.
+type Hook struct {
+	*log.Filter
+	// whether to label the counter with the entry category. If false, the entries are only counted by level.
+	CategoryLabel bool
+
+	counter *prometheus.CounterVec
+	close   chan bool
+}
+
+// NewHook creates a Hook counting the entries in the "<namespace>_log_entries_total" counter.
+// The new Hook takes these default options:
+// MaxLevel: LevelDebug, CategoryLabel: true
+func NewHook(namespace string) *Hook {
+	return &Hook{
+		Filter:        &log.Filter{MaxLevel: log.LevelDebug},
+		CategoryLabel: true,
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "log_entries_total",
+			Help:      "The number of log entries by level and category.",
+		}, []string{"level", "category"}),
+		close: make(chan bool, 0),
+	}
+}
+
+// Collector returns the collector of the counter, which should be registered with a Prometheus registry.
+func (h *Hook) Collector() prometheus.Collector {
+	return h.counter
+}
+
+// Open prepares the hook for counting log entries.
+func (h *Hook) Open(io.Writer) error {
+	h.Filter.Init()
+	return nil
+}
+
+// Process counts an allowed log entry.
+func (h *Hook) Process(e *log.Entry) {
+	if e == nil {
+		h.close <- true
+		return
+	}
+	if !h.Allow(e) {
+		return
+	}
+	category := ""
+	if h.CategoryLabel {
+		category = e.Category
+	}
+	h.counter.WithLabelValues(e.Level.String(), category).Inc()
+}
+
+// Describe returns the type and filter settings of the hook.
+func (h *Hook) Describe() log.TargetInfo {
+	return log.TargetInfo{
+		Type:            "promlog.Hook",
+		Enabled:         h.Enabled(),
+		MaxLevel:        h.MaxLevel,
+		MinLevel:        h.MinLevel,
+		MostSevereLevel: h.MostSevereLevel,
+		Categories:      append([]string(nil), h.Categories...),
+	}
+}
+
+// Close closes the hook.
+func (h *Hook) Close() {
+	<-h.close
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package promlog_test
+
+import (
+	"testing"
+
+	"github.com/go-ozzo/ozzo-log"
+	"github.com/go-ozzo/ozzo-log/promlog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHook(t *testing.T) {
+	hook := promlog.NewHook("test")
+	hook.MaxLevel = log.LevelInfo
+	logger := log.NewLogger()
+	logger.Targets = append(logger.Targets, hook)
+	logger.Open()
+	logger.Info("t1")
+	logger.Info("t2")
+	logger.GetLogger("db").Error("t3")
+	logger.Debug("t4")
+	logger.Close()
+
+	counter := hook.Collector().(*prometheus.CounterVec)
+	tests := []struct {
+		level, category string
+		expected        float64
+	}{
+		{"Info", "app", 2},
+		{"Error", "db", 1},
+		{"Debug", "app", 0},
+	}
+	for _, test := range tests {
+		if n := testutil.ToFloat64(counter.WithLabelValues(test.level, test.category)); n != test.expected {
+			t.Errorf("counter(%v, %v) = %v, expected %v", test.level, test.category, n, test.expected)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// Recover logs the panic in flight, if any, as a critical message. It must be deferred directly, e.g. at the
+// beginning of a goroutine or a handler:
+//
+//	go func() {
+//		defer logger.Recover()
+//		...
+//	}()
+//
+// The message is "panic: " followed by the panic value, which is also added as the "panic" field, and its call stack
+// starts at the panicking function. The call stack has CallStackDepth frames (filtered by CallStackFilter), or all
+// of them if CallStackDepth is 0. If SwallowPanics is false, the logger is then closed so that the message reaches
+// the targets before the process crashes, and the panic continues; since the root logger and the loggers derived
+// from it are closed, do not let another recover resume the program. The close waits at most ExitFlushTimeout,
+// after which the targets not closed yet are abandoned and reported to ErrorWriter. If SwallowPanics is true,
+// the panic is stopped and the logger keeps running.
+func (l *Logger) Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	frames := l.CallStackDepth
+	if frames == 0 {
+		frames = maxCallStackFrames
+	}
+	l.LogEntry(&Entry{
+		Level:   LevelCritical,
+		Message: fmt.Sprintf("panic: %v", r),
+		Fields:  Fields{"panic": r},
+		// skip Recover and runtime.gopanic
+		CallStack: GetCallStack(3, frames, l.CallStackFilter),
+	})
+	if !l.SwallowPanics {
+		l.closeBeforeExit()
+		panic(r)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panicking(logger *Logger) {
+	defer logger.Recover()
+	panic("boom")
+}
+
+func TestLoggerRecover(t *testing.T) {
+	logger := NewLogger()
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recover() = %v, expected the panic to continue", r)
+			}
+		}()
+		panicking(logger)
+	}()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("number of entries = %v, expected the logger to be closed after logging 1 entry", len(target.entries))
+	}
+	e := target.entries[0]
+	if e.Level != LevelCritical || e.Message != "panic: boom" || e.Fields["panic"] != "boom" {
+		t.Errorf("entry = %v %q %v, expected a Critical panic entry", e.Level, e.Message, e.Fields)
+	}
+	if !strings.HasPrefix(e.CallStack, "\n") || !strings.Contains(strings.SplitN(e.CallStack[1:], "\n", 2)[0], "recover_test.go") {
+		t.Errorf("entry.CallStack = %q, expected it to start at the panicking function", e.CallStack)
+	}
+}
+
+func TestLoggerRecoverSwallowPanics(t *testing.T) {
+	logger := NewLogger()
+	logger.SwallowPanics = true
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	panicking(logger)
+	func() {
+		defer logger.Recover()
+	}()
+	logger.Info("after")
+	logger.Close()
+
+	if len(target.entries) != 2 || target.entries[0].Message != "panic: boom" || target.entries[1].Message != "after" {
+		t.Fatalf("entries = %v, expected the panic entry followed by %q", target.entries, "after")
+	}
+	if n := strings.Count(target.entries[0].CallStack, "\n"); n != 1 {
+		t.Errorf("number of call stack frames = %v, expected %v", n, 1)
+	}
+}
+
+// slowTarget is a target whose Close blocks until release is closed.
+type slowTarget struct {
+	release chan bool
+}
+
+func (t *slowTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *slowTarget) Process(*Entry) {
+}
+
+func (t *slowTarget) Close() {
+	<-t.release
+}
+
+func TestLoggerRecoverExitFlushTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger()
+	logger.ErrorWriter = &buf
+	logger.ExitFlushTimeout = 50 * time.Millisecond
+	memory := &MemoryTarget{ready: make(chan bool, 0)}
+	slow := &slowTarget{release: make(chan bool)}
+	defer close(slow.release)
+	logger.Targets = append(logger.Targets, memory, slow)
+	logger.Open()
+
+	start := time.Now()
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recover() = %v, expected the panic to continue", r)
+			}
+		}()
+		panicking(logger)
+	}()
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Recover took %v, expected it to give up the slow target after ExitFlushTimeout", elapsed)
+	}
+	if len(memory.entries) != 1 || memory.entries[0].Message != "panic: boom" {
+		t.Errorf("entries = %v, expected the target closed in time to have the panic entry", memory.entries)
+	}
+	expected := "Logger abandoned 1 target(s) not closed within 50ms before exiting: *log.slowTarget\n"
+	if buf.String() != expected {
+		t.Errorf("ErrorWriter = %q, expected %q", buf.String(), expected)
+	}
+}
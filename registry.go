@@ -0,0 +1,158 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TargetProvider creates a new target with its default options.
+type TargetProvider func() Target
+
+// TypeRegistrar registers named object providers that can be used when configuring objects.
+// It is satisfied by *config.Config of the ozzo-config package.
+type TypeRegistrar interface {
+	Register(name string, provider interface{}) error
+}
+
+var (
+	targetTypesLock sync.RWMutex
+	targetTypes     = map[string]TargetProvider{}
+
+	formattersLock sync.RWMutex
+	formatters     = map[string]Formatter{}
+)
+
+func init() {
+	RegisterTargetType("console", func() Target { return NewConsoleTarget() })
+	RegisterTargetType("file", func() Target { return NewFileTarget() })
+	RegisterTargetType("network", func() Target { return NewNetworkTarget() })
+	RegisterTargetType("mail", func() Target { return NewMailTarget() })
+	RegisterTargetType("journald", func() Target { return NewJournaldTarget() })
+	RegisterTargetType("eventlog", func() Target { return NewWindowsEventLogTarget() })
+	RegisterTargetType("ack", func() Target { return NewAckTarget() })
+	RegisterTargetType("otlp", func() Target { return NewOTLPTarget() })
+
+	RegisterFormatter("default", DefaultFormatter)
+	RegisterFormatter("json", JSONFormatter)
+	RegisterFormatter("logfmt", LogfmtFormatter)
+	RegisterFormatter("common", CommonLogFormatter)
+	RegisterFormatter("combined", CombinedLogFormatter)
+}
+
+// RegisterTargetType registers a target type under the given name so that it can be
+// referenced by the "type" key when the logger is configured by ozzo-config.
+// The built-in targets are registered as "console", "file", "network", "mail", "journald", "eventlog", "ack"
+// and "otlp".
+// An error is returned, and the registered type is kept, if the name is already registered, which usually
+// means that two packages picked the same name or that a registration was copied without renaming it.
+// Use ReplaceTargetType to override a registered type on purpose.
+func RegisterTargetType(name string, provider TargetProvider) error {
+	targetTypesLock.Lock()
+	defer targetTypesLock.Unlock()
+	if _, ok := targetTypes[name]; ok {
+		return fmt.Errorf("target type %q is already registered", name)
+	}
+	targetTypes[name] = provider
+	return nil
+}
+
+// ReplaceTargetType registers a target type under the given name like RegisterTargetType, replacing the type
+// already registered under that name, if any, e.g. to make "file" create a FileTarget with other default options.
+func ReplaceTargetType(name string, provider TargetProvider) {
+	targetTypesLock.Lock()
+	defer targetTypesLock.Unlock()
+	targetTypes[name] = provider
+}
+
+// TargetTypes returns the names of the registered target types in alphabetical order.
+func TargetTypes() []string {
+	targetTypesLock.RLock()
+	defer targetTypesLock.RUnlock()
+	names := make([]string, 0, len(targetTypes))
+	for name := range targetTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTarget creates a target of the named type. It returns nil if the type is not registered.
+func NewTarget(name string) Target {
+	targetTypesLock.RLock()
+	provider, ok := targetTypes[name]
+	targetTypesLock.RUnlock()
+	if !ok {
+		return nil
+	}
+	return provider()
+}
+
+// RegisterTargetTypes registers all target types with the given registrar. For example,
+//
+//	c := config.New()
+//	c.Load("app.json")
+//	log.RegisterTargetTypes(c)
+//	logger := log.NewLogger()
+//	c.Configure(logger, "Logger")
+//
+// Each provider is registered as a function returning the concrete target type,
+// so that the registrar can configure the fields of the created targets. To learn that type,
+// every provider is called once when it is registered with the registrar, and the target
+// created by that call is discarded. Providers must therefore not have side effects, and must
+// always return a non-nil target of the same type; an error is returned if a provider returns nil.
+func RegisterTargetTypes(r TypeRegistrar) error {
+	targetTypesLock.RLock()
+	defer targetTypesLock.RUnlock()
+	names := make([]string, 0, len(targetTypes))
+	for name := range targetTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		provider, err := concreteProvider(name, targetTypes[name])
+		if err != nil {
+			return err
+		}
+		if err := r.Register(name, provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// concreteProvider wraps a target provider into a function whose return type is the concrete target type.
+func concreteProvider(name string, provider TargetProvider) (interface{}, error) {
+	var target Target
+	if provider != nil {
+		target = provider()
+	}
+	if target == nil {
+		return nil, fmt.Errorf("the provider of target type %q returned nil", name)
+	}
+	fn := reflect.FuncOf(nil, []reflect.Type{reflect.TypeOf(target)}, false)
+	return reflect.MakeFunc(fn, func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.ValueOf(provider())}
+	}).Interface(), nil
+}
+
+// RegisterFormatter registers a formatter under the given name so that it can be
+// selected via Logger.FormatterName, e.g. when the logger is configured by ozzo-config.
+// The built-in formatters are registered as "default", "json", "logfmt", "common" and "combined".
+func RegisterFormatter(name string, formatter Formatter) {
+	formattersLock.Lock()
+	defer formattersLock.Unlock()
+	formatters[name] = formatter
+}
+
+// GetFormatter returns the formatter registered under the given name, or nil if it is not registered.
+func GetFormatter(name string) Formatter {
+	formattersLock.RLock()
+	defer formattersLock.RUnlock()
+	return formatters[name]
+}
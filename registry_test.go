@@ -0,0 +1,125 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+type testRegistrar map[string]interface{}
+
+func (r testRegistrar) Register(name string, provider interface{}) error {
+	r[name] = provider
+	return nil
+}
+
+// unregisterTargetType removes a target type registered by a test, so that it does not leak into the other tests.
+func unregisterTargetType(name string) {
+	targetTypesLock.Lock()
+	delete(targetTypes, name)
+	targetTypesLock.Unlock()
+}
+
+func TestRegisterTargetType(t *testing.T) {
+	if err := RegisterTargetType("memory", func() Target { return &MemoryTarget{Option1: "abc"} }); err != nil {
+		t.Fatalf("RegisterTargetType(memory): %v", err)
+	}
+	t.Cleanup(func() { unregisterTargetType("memory") })
+	if names := strings.Join(TargetTypes(), ","); !strings.Contains(names, "console,eventlog,file,journald,mail,memory,network") {
+		t.Errorf("TargetTypes() = %v, expected to contain the built-in types and memory", names)
+	}
+	if target, ok := NewTarget("memory").(*MemoryTarget); !ok || target.Option1 != "abc" {
+		t.Errorf("NewTarget(memory) = %v, expected a MemoryTarget", target)
+	}
+	if target := NewTarget("unknown"); target != nil {
+		t.Errorf("NewTarget(unknown) = %v, expected nil", target)
+	}
+
+	r := testRegistrar{}
+	if err := RegisterTargetTypes(r); err != nil {
+		t.Fatalf("RegisterTargetTypes(): %v", err)
+	}
+	provider, ok := r["memory"].(func() *MemoryTarget)
+	if !ok {
+		t.Fatalf("RegisterTargetTypes() registered %T, expected func() *MemoryTarget", r["memory"])
+	}
+	if target := provider(); target.Option1 != "abc" {
+		t.Errorf("provider().Option1 = %v, expected %v", target.Option1, "abc")
+	}
+	if _, ok := r["console"].(func() *ConsoleTarget); !ok {
+		t.Errorf("RegisterTargetTypes() registered %T for console, expected func() *ConsoleTarget", r["console"])
+	}
+}
+
+func TestRegisterTargetTypeDuplicate(t *testing.T) {
+	t.Cleanup(func() { unregisterTargetType("dup") })
+	if err := RegisterTargetType("dup", func() Target { return &MemoryTarget{Option1: "first"} }); err != nil {
+		t.Fatalf("RegisterTargetType(dup): %v", err)
+	}
+	err := RegisterTargetType("dup", func() Target { return &MemoryTarget{Option1: "second"} })
+	if err == nil || err.Error() != `target type "dup" is already registered` {
+		t.Errorf("RegisterTargetType(dup) = %v, expected an error about the duplicate", err)
+	}
+	if target := NewTarget("dup").(*MemoryTarget); target.Option1 != "first" {
+		t.Errorf("NewTarget(dup).Option1 = %v, expected the first registration to be kept", target.Option1)
+	}
+	if err := RegisterTargetType("file", func() Target { return NewFileTarget() }); err == nil {
+		t.Errorf("RegisterTargetType(file) = nil, expected the built-in type not to be overridden")
+	}
+
+	ReplaceTargetType("dup", func() Target { return &MemoryTarget{Option1: "replaced"} })
+	if target := NewTarget("dup").(*MemoryTarget); target.Option1 != "replaced" {
+		t.Errorf("NewTarget(dup).Option1 = %v, expected ReplaceTargetType to override the registration", target.Option1)
+	}
+}
+
+func TestRegisterTargetTypesNilProvider(t *testing.T) {
+	RegisterTargetType("nil", func() Target { return nil })
+	defer func() {
+		targetTypesLock.Lock()
+		delete(targetTypes, "nil")
+		targetTypesLock.Unlock()
+	}()
+
+	err := RegisterTargetTypes(testRegistrar{})
+	if err == nil || !strings.Contains(err.Error(), `target type "nil" returned nil`) {
+		t.Errorf("RegisterTargetTypes() = %v, expected an error about the nil provider", err)
+	}
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter("test", func(*Logger, *Entry) string { return "test" })
+	if formatter := GetFormatter("test"); formatter == nil || formatter(nil, nil) != "test" {
+		t.Errorf("GetFormatter(test) did not return the registered formatter")
+	}
+	if GetFormatter("json") == nil || GetFormatter("logfmt") == nil || GetFormatter("default") == nil {
+		t.Errorf("the built-in formatters should be registered")
+	}
+
+	logger := NewLogger()
+	logger.FormatterName = "test"
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	derived := logger.GetLogger("derived")
+	if err := logger.Open(); err != nil {
+		t.Fatalf("logger.Open(): %v", err)
+	}
+	logger.Info("t1")
+	derived.Info("t2")
+	logger.GetLogger("custom", func(*Logger, *Entry) string { return "custom" }).Info("t3")
+	logger.Close()
+	if len(target.entries) != 3 || target.entries[0].String() != "test" || target.entries[1].String() != "test" {
+		t.Errorf("the entries were not formatted by the named formatter")
+	} else if target.entries[2].String() != "custom" {
+		t.Errorf("the formatter given to GetLogger() should replace the named formatter")
+	}
+
+	logger = NewLogger()
+	logger.FormatterName = "unknown"
+	if err := logger.Open(); err == nil {
+		t.Errorf("logger.Open() should fail for an unknown formatter name")
+	}
+}
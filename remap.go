@@ -0,0 +1,63 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// levelRemap is a rule added by RemapLevel.
+type levelRemap struct {
+	pattern *regexp.Regexp
+	level   Level
+}
+
+// RemapLevel changes the level of the messages matching the given regular expression to the given level,
+// which is useful to demote the benign errors flooding the alerts, such as those of a third-party library,
+// or to promote important messages. For example,
+//
+//	logger.RemapLevel(`^connection reset by peer`, log.LevelDebug)
+//
+// The rules apply to the messages logged by the logger and the loggers derived from it, including the entries
+// passed to LogEntry and those bridged from slog, before they are filtered by level. They are tried in the order
+// they are added, and the first matching rule sets the level. An error is returned if the pattern is invalid.
+//
+// Because the rules match the formatted messages, once a rule is added, every message is formatted before
+// being filtered, which makes the messages filtered out by MaxLevel more costly.
+func (l *Logger) RemapLevel(pattern string, to Level) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid level remapping pattern %q: %v", pattern, err)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	remaps := l.levelRemaps()
+	// copy the rules so that those being used by the log calls are not modified
+	l.remaps.Store(append(remaps[:len(remaps):len(remaps)], levelRemap{re, to}))
+	return nil
+}
+
+// levelRemaps returns the rules added by RemapLevel.
+func (l *coreLogger) levelRemaps() []levelRemap {
+	remaps, _ := l.remaps.Load().([]levelRemap)
+	return remaps
+}
+
+// discards checks if the messages of the given level are discarded regardless of their content,
+// i.e. they are filtered out by MaxLevel and no rule added by RemapLevel may change their level.
+func (l *coreLogger) discards(level Level) bool {
+	return level > l.MaxLevel && len(l.levelRemaps()) == 0
+}
+
+// remapLevel returns the level set by the first rule matching the message, or the given level if none matches.
+func remapLevel(remaps []levelRemap, level Level, message string) Level {
+	for _, r := range remaps {
+		if r.pattern.MatchString(message) {
+			return r.level
+		}
+	}
+	return level
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoggerRemapLevel(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	target := &MemoryTarget{
+		ready: make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, target)
+	if err := logger.RemapLevel(`(`, LevelDebug); err == nil {
+		t.Errorf("RemapLevel() should fail with an invalid pattern")
+	}
+	l := logger.GetLogger("lib")
+	l.RemapLevel(`^connection reset`, LevelDebug)
+	l.RemapLevel(`disk`, LevelCritical)
+	l.RemapLevel(`disk full`, LevelInfo)
+	logger.Open()
+
+	logger.Error("connection reset by peer") // demoted and filtered out
+	logger.Error("connection %v", "refused") // unchanged
+	logger.Debug("%v almost full", "disk")   // promoted
+	l.NewEvent(LevelDebug).Msg("disk full")  // promoted by the first matching rule
+	l.Info("new connection reset")           // unchanged
+	l.LogEntry(&Entry{Level: LevelError, Message: "connection reset"})
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Level.String()+":"+e.Message)
+	}
+	expected := "Error:connection refused,Critical:disk almost full,Critical:disk full,Info:new connection reset"
+	if result := strings.Join(messages, ","); result != expected {
+		t.Errorf("messages = %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Sampler keeps one out of every Rate log entries to reduce the volume of noisy messages.
+// Entries whose severity is at or above AlwaysLevel (i.e. whose level value is no greater than AlwaysLevel)
+// bypass sampling and are always kept, so that sampling never drops important errors.
+// A Sampler is safe for concurrent use.
+//
+// When Key is set, the entries are grouped by the value of the field named Key, e.g. "user_id", and one out of
+// every Rate entries of each group is kept, so that a single chatty user does not use up the entries kept for
+// the others. The field is looked up in the fields of the entry and then in those of the logger (the top-level
+// ones, not those in groups); the entries without it are sampled together. At most MaxKeys groups are tracked:
+// when a new group would exceed it, the counter of an arbitrary group is evicted, so that the next entry of that
+// group is kept. The memory used is therefore bounded by MaxKeys times the length of the field values.
+//
+// Rate may be set before the Sampler is used; afterwards, call SetRate to change it, e.g. from an admin endpoint
+// keeping all entries during an incident.
+type Sampler struct {
+	count      uint64 // the number of sampled entries. Must be the first field for 64-bit alignment.
+	suppressed uint64 // the number of dropped entries
+	rate       int64  // the rate set by SetRate, overriding Rate if positive
+
+	Rate        int    // keep one out of every Rate entries. A value no greater than 1 keeps all entries.
+	AlwaysLevel Level  // the lowest severity level that is never sampled
+	Key         string // the field grouping the entries sampled separately. Empty samples all entries together.
+	MaxKeys     int    // the maximum number of groups tracked when Key is set. A value no greater than 0 means no limit.
+
+	lock sync.Mutex
+	keys map[string]uint64 // the number of sampled entries of each group
+}
+
+// NewSampler creates a Sampler keeping one out of every rate entries.
+// The new Sampler takes these default options:
+// AlwaysLevel: LevelError, MaxKeys: 1000
+func NewSampler(rate int) *Sampler {
+	return &Sampler{
+		Rate:        rate,
+		AlwaysLevel: LevelError,
+		MaxKeys:     1000,
+	}
+}
+
+// SetRate changes the rate of the sampler, so that one out of every n entries is kept from now on.
+// A value no greater than 1 keeps all entries. SetRate may be called concurrently with Allow.
+// The entry counters are kept, so the first entries sampled with the new rate are not necessarily kept.
+func (s *Sampler) SetRate(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt64(&s.rate, int64(n))
+}
+
+// currentRate returns the rate set by SetRate, or Rate if SetRate has not been called.
+func (s *Sampler) currentRate() int {
+	if rate := atomic.LoadInt64(&s.rate); rate > 0 {
+		return int(rate)
+	}
+	return s.Rate
+}
+
+// Allow checks if a log entry should be kept.
+func (s *Sampler) Allow(e *Entry) bool {
+	return s.allow(e, nil)
+}
+
+// allow checks if a log entry should be kept, looking up Key in the given logger fields
+// if the entry does not have it.
+func (s *Sampler) allow(e *Entry, fields Fields) bool {
+	rate := s.currentRate()
+	if e.Level <= s.AlwaysLevel || rate <= 1 {
+		return true
+	}
+	var n uint64
+	if key, ok := s.key(e, fields); ok {
+		n = s.countKey(key)
+	} else {
+		n = atomic.AddUint64(&s.count, 1)
+	}
+	if (n-1)%uint64(rate) != 0 {
+		atomic.AddUint64(&s.suppressed, 1)
+		return false
+	}
+	return true
+}
+
+// Suppressed returns the number of entries dropped by the sampler so far.
+// Suppressed may be called concurrently with Allow.
+func (s *Sampler) Suppressed() uint64 {
+	return atomic.LoadUint64(&s.suppressed)
+}
+
+// key returns the value of the Key field of the entry as a string, and whether the entry has the field.
+func (s *Sampler) key(e *Entry, fields Fields) (string, bool) {
+	if s.Key == "" {
+		return "", false
+	}
+	value, ok := e.Fields[s.Key]
+	if !ok {
+		if value, ok = fields[s.Key]; !ok {
+			return "", false
+		}
+	}
+	if str, ok := value.(string); ok {
+		return str, true
+	}
+	return fmt.Sprint(value), true
+}
+
+// countKey increments and returns the number of sampled entries of a group, evicting another group
+// if a new one would exceed MaxKeys.
+func (s *Sampler) countKey(key string) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]uint64)
+	}
+	n, ok := s.keys[key]
+	if !ok && s.MaxKeys > 0 && len(s.keys) >= s.MaxKeys {
+		for k := range s.keys {
+			delete(s.keys, k)
+			break
+		}
+	}
+	n++
+	s.keys[key] = n
+	return n
+}
@@ -0,0 +1,181 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	sampler := NewSampler(10)
+	if sampler.AlwaysLevel != LevelError {
+		t.Errorf("NewSampler().AlwaysLevel = %v, expected %v", sampler.AlwaysLevel, LevelError)
+	}
+
+	logger := NewLogger()
+	logger.Sampler = sampler
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	for i := 0; i < 100; i++ {
+		logger.Debug("debug")
+		logger.Error("error")
+		logger.Critical("critical")
+	}
+	logger.Close()
+
+	counts := map[Level]int{}
+	for _, e := range target.entries {
+		counts[e.Level]++
+	}
+	if counts[LevelError] != 100 {
+		t.Errorf("number of Error entries = %v, expected %v", counts[LevelError], 100)
+	}
+	if counts[LevelCritical] != 100 {
+		t.Errorf("number of Critical entries = %v, expected %v", counts[LevelCritical], 100)
+	}
+	if counts[LevelDebug] != 10 {
+		t.Errorf("number of Debug entries = %v, expected %v", counts[LevelDebug], 10)
+	}
+	if sampler.Suppressed() != 90 {
+		t.Errorf("sampler.Suppressed() = %v, expected %v", sampler.Suppressed(), 90)
+	}
+}
+
+func TestSamplerRate(t *testing.T) {
+	tests := []struct {
+		rate, expected int
+	}{
+		{0, 20},
+		{1, 20},
+		{2, 10},
+		{3, 7},
+	}
+	for _, test := range tests {
+		sampler := NewSampler(test.rate)
+		count := 0
+		for i := 0; i < 20; i++ {
+			if sampler.Allow(&Entry{Level: LevelInfo}) {
+				count++
+			}
+		}
+		if count != test.expected {
+			t.Errorf("NewSampler(%v) allowed %v entries, expected %v", test.rate, count, test.expected)
+		}
+	}
+}
+
+func TestSamplerSetRate(t *testing.T) {
+	sampler := NewSampler(10)
+	allowed := func() int {
+		count := 0
+		for i := 0; i < 20; i++ {
+			if sampler.Allow(&Entry{Level: LevelInfo}) {
+				count++
+			}
+		}
+		return count
+	}
+	if count := allowed(); count != 2 {
+		t.Errorf("with rate 10, the sampler allowed %v entries, expected 2", count)
+	}
+	sampler.SetRate(1)
+	if count := allowed(); count != 20 {
+		t.Errorf("after SetRate(1), the sampler allowed %v entries, expected 20", count)
+	}
+	sampler.SetRate(0)
+	if count := allowed(); count != 20 {
+		t.Errorf("after SetRate(0), the sampler allowed %v entries, expected 20", count)
+	}
+	sampler.SetRate(5)
+	if count := allowed(); count != 4 {
+		t.Errorf("after SetRate(5), the sampler allowed %v entries, expected 4", count)
+	}
+
+	// flip the rate while entries are sampled; the race detector checks the accesses
+	var wg sync.WaitGroup
+	stop := make(chan bool, 0)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				sampler.SetRate(1 + i%10)
+			}
+		}
+	}()
+	var samplers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		samplers.Add(1)
+		go func() {
+			defer samplers.Done()
+			for j := 0; j < 1000; j++ {
+				sampler.Allow(&Entry{Level: LevelInfo})
+			}
+		}()
+	}
+	samplers.Wait()
+	close(stop)
+	wg.Wait()
+
+	sampler.SetRate(1)
+	if count := allowed(); count != 20 {
+		t.Errorf("after SetRate(1), the sampler allowed %v entries, expected 20", count)
+	}
+}
+
+func TestSamplerKey(t *testing.T) {
+	sampler := NewSampler(10)
+	sampler.Key = "user_id"
+
+	logger := NewLogger()
+	logger.Sampler = sampler
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	chatty, quiet := logger.WithField("user_id", 1), logger.WithField("user_id", "2")
+	for i := 0; i < 100; i++ {
+		chatty.Debug("chatty")
+		logger.Debug("anonymous")
+	}
+	quiet.Debug("quiet")
+	logger.LogEntry(&Entry{Level: LevelDebug, Message: "bridged", Fields: Fields{"user_id": "2"}})
+	logger.Close()
+
+	counts := map[string]int{}
+	for _, e := range target.entries {
+		counts[e.Message]++
+	}
+	expected := map[string]int{"chatty": 10, "anonymous": 10, "quiet": 1, "bridged": 0}
+	for message, count := range expected {
+		if counts[message] != count {
+			t.Errorf("number of %q entries = %v, expected %v", message, counts[message], count)
+		}
+	}
+}
+
+func TestSamplerMaxKeys(t *testing.T) {
+	sampler := NewSampler(2)
+	sampler.Key = "user_id"
+	sampler.MaxKeys = 2
+	allow := func(user int) bool {
+		return sampler.Allow(&Entry{Level: LevelInfo, Fields: Fields{"user_id": user}})
+	}
+	for user := 0; user < 10; user++ {
+		if !allow(user) {
+			t.Errorf("the first entry of user %v was dropped", user)
+		}
+		if len(sampler.keys) > 2 {
+			t.Fatalf("number of tracked keys = %v, expected no more than %v", len(sampler.keys), 2)
+		}
+	}
+	if allow(9) {
+		t.Errorf("the second entry of user %v was kept", 9)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxScannedLineLen is the maximum length of a line read by EntryScanner.
+const maxScannedLineLen = 1 << 20
+
+// EntryScanner reads back the log entries written as JSON lines by JSONFormatter, e.g. from a file written
+// by FileTarget, so that they can be processed again (for example, by sending them to other targets).
+// It is used like bufio.Scanner:
+//
+//	scanner := log.NewEntryScanner(file)
+//	for scanner.Scan() {
+//		entry := scanner.Entry()
+//		...
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+//
+// The lines are expected to use the default keys of FormatterOptions. The fields of the entries are restored
+// from the other keys, with the JSON objects restored as Fields groups and the numbers as json.Number values;
+// the "fields." prefix added to the fields colliding with the reserved keys is removed. FormattedMessage is set
+// to the line read. Empty lines are ignored. Malformed lines, including those with an unknown level, are skipped
+// and reported by Err, while scanning continues with the next line. Scanning stops if the reader fails or a line
+// is longer than 1MB.
+type EntryScanner struct {
+	scanner *bufio.Scanner
+	line    int
+	entry   *Entry
+	errs    Errors
+}
+
+// NewEntryScanner creates an EntryScanner reading from the given reader.
+func NewEntryScanner(r io.Reader) *EntryScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScannedLineLen)
+	return &EntryScanner{scanner: scanner}
+}
+
+// Scan advances the scanner to the next log entry, which is then available through Entry.
+// It returns false when there are no more entries or the reader fails.
+func (s *EntryScanner) Scan() bool {
+	s.entry = nil
+	for s.scanner.Scan() {
+		s.line++
+		line := s.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry, err := parseEntry(line)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Errorf("line %v: %v", s.line, err))
+			continue
+		}
+		s.entry = entry
+		return true
+	}
+	return false
+}
+
+// Entry returns the log entry read by the last call to Scan.
+func (s *EntryScanner) Entry() *Entry {
+	return s.entry
+}
+
+// Err returns the errors of the malformed lines skipped so far, followed by the error of the reader, if any.
+func (s *EntryScanner) Err() error {
+	errs := append(Errors{}, s.errs...)
+	if err := s.scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return joinErrors(errs...)
+}
+
+// parseEntry parses a line written by JSONFormatter into a log entry.
+func parseEntry(line []byte) (*Entry, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	o := FormatterOptions{}.withDefaults()
+	entry := &Entry{FormattedMessage: string(line)}
+	for key, value := range values {
+		var ok bool
+		switch key {
+		case o.TimeKey:
+			var s string
+			if s, ok = value.(string); ok {
+				var err error
+				if entry.Time, err = time.Parse(o.TimeFormat, s); err != nil {
+					return nil, fmt.Errorf("invalid %v: %v", key, err)
+				}
+			}
+		case o.LevelKey:
+			var s string
+			if s, ok = value.(string); ok {
+				var err error
+				if entry.Level, err = LevelFromString(s); err != nil {
+					return nil, err
+				}
+			}
+		case o.CategoryKey:
+			entry.Category, ok = value.(string)
+		case o.MessageKey:
+			entry.Message, ok = value.(string)
+		case o.SeqKey:
+			var n json.Number
+			if n, ok = value.(json.Number); ok {
+				_, err := fmt.Sscan(n.String(), &entry.Seq)
+				ok = err == nil
+			}
+		case o.StackKey:
+			var s string
+			if s, ok = value.(string); ok {
+				// the formatters trim the leading newline of the call stack
+				entry.CallStack = "\n" + s
+			}
+		default:
+			if entry.Fields == nil {
+				entry.Fields = Fields{}
+			}
+			entry.Fields[strings.TrimPrefix(key, "fields.")] = normalizeField(value)
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid %v: %v", key, value)
+		}
+	}
+	if _, ok := values[o.LevelKey]; !ok {
+		return nil, fmt.Errorf("missing %v", o.LevelKey)
+	}
+	return entry, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+func TestEntryScanner(t *testing.T) {
+	entry := &log.Entry{
+		Seq:       7,
+		Level:     log.LevelError,
+		Category:  "app",
+		Message:   "request failed",
+		Time:      time.Date(2016, 5, 1, 10, 20, 30, 123, time.UTC),
+		CallStack: "\nmain.go:10",
+		Fields:    log.Fields{"message": "shadowed", "http": log.Fields{"status": 500}},
+	}
+	line := log.JSONFormatter(nil, entry)
+	input := line + "\n\nnot json\n" + `{"level":"Verbose","message":"x"}` + "\n" + log.JSONFormatter(nil, &log.Entry{Level: log.LevelInfo, Message: "t2"}) + "\n"
+
+	scanner := log.NewEntryScanner(strings.NewReader(input))
+	var entries []*log.Entry
+	for scanner.Scan() {
+		entries = append(entries, scanner.Entry())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %v entries, expected 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.Seq != entry.Seq || e.Level != entry.Level || e.Category != entry.Category || e.Message != entry.Message {
+		t.Errorf("entry = %+v, expected %+v", e, entry)
+	}
+	if !e.Time.Equal(entry.Time) {
+		t.Errorf("entry.Time = %v, expected %v", e.Time, entry.Time)
+	}
+	if e.CallStack != entry.CallStack {
+		t.Errorf("entry.CallStack = %q, expected %q", e.CallStack, entry.CallStack)
+	}
+	expected := log.Fields{"message": "shadowed", "http": log.Fields{"status": json.Number("500")}}
+	if !reflect.DeepEqual(e.Fields, expected) {
+		t.Errorf("entry.Fields = %v, expected %v", e.Fields, expected)
+	}
+	if e.String() != line {
+		t.Errorf("entry.String() = %q, expected %q", e.String(), line)
+	}
+	if entries[1].Message != "t2" || !entries[1].Time.IsZero() || entries[1].Fields != nil {
+		t.Errorf("entry = %+v, expected the t2 entry without time and fields", entries[1])
+	}
+
+	err := scanner.Err()
+	if err == nil || !strings.Contains(err.Error(), "line 3: invalid JSON") || !strings.Contains(err.Error(), `line 4: unknown level name "Verbose"`) {
+		t.Errorf("scanner.Err() = %v, expected the errors of lines 3 and 4", err)
+	}
+}
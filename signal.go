@@ -0,0 +1,52 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals installs a handler that closes the logger when one of the given signals arrives,
+// so that buffered log messages are flushed to the targets before the application terminates.
+// If no signal is given, os.Interrupt (SIGINT) and SIGTERM are handled.
+// After the logger is closed, the process exits with the code 128+signal unless ExitOnSignal is false.
+// The close waits at most ExitFlushTimeout: the targets not closed by then are abandoned and reported to ErrorWriter,
+// so that a target stuck on a dead destination does not delay the exit indefinitely.
+//
+// HandleSignals is idempotent: only the first call installs a handler.
+// Applications that manage their own signal handling should not use this method and
+// should call Close() in their own handler instead.
+func (l *coreLogger) HandleSignals(signals ...os.Signal) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.signals != nil {
+		return
+	}
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	l.signals = make(chan os.Signal, 1)
+	signal.Notify(l.signals, signals...)
+
+	go func() {
+		sig := <-l.signals
+		signal.Stop(l.signals)
+		l.closeBeforeExit()
+		if l.ExitOnSignal {
+			os.Exit(signalExitCode(sig))
+		}
+	}()
+}
+
+// signalExitCode returns the conventional exit code for a process terminated by the given signal.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
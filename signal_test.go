@@ -0,0 +1,92 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+type closeNotifyTarget struct {
+	entries []*Entry
+	closed  chan bool
+}
+
+func (t *closeNotifyTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *closeNotifyTarget) Process(e *Entry) {
+	if e == nil {
+		t.closed <- true
+	} else {
+		t.entries = append(t.entries, e)
+	}
+}
+
+func (t *closeNotifyTarget) Close() {
+}
+
+func TestHandleSignals(t *testing.T) {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("os.FindProcess(): %v", err)
+	}
+
+	logger := NewLogger()
+	logger.ExitOnSignal = false
+	target := &closeNotifyTarget{closed: make(chan bool, 1)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.HandleSignals(os.Interrupt)
+	// the second call should be ignored
+	logger.HandleSignals(os.Interrupt)
+	logger.Info("t1")
+
+	// keep logging while the signal handler closes the logger
+	stop := make(chan bool)
+	logging := make(chan bool)
+	go func() {
+		defer close(logging)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				logger.Debug("t2")
+			}
+		}
+	}()
+
+	if err := p.Signal(os.Interrupt); err != nil {
+		close(stop)
+		t.Skipf("sending signals is not supported: %v", err)
+	}
+
+	select {
+	case <-target.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the logger was not closed after receiving the signal")
+	}
+	close(stop)
+	<-logging
+
+	// closing the logger again, as a deferred Close would do, must not block
+	done := make(chan bool)
+	go func() {
+		logger.Close()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("closing a closed logger blocked")
+	}
+	if len(target.entries) == 0 || target.entries[0].Message != "t1" {
+		t.Errorf("the entries logged before the signal were not processed")
+	}
+}
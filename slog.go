@@ -0,0 +1,136 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// slogHandler is a slog.Handler that logs records with a Logger.
+type slogHandler struct {
+	logger *Logger
+}
+
+// NewSlogHandler creates a slog.Handler that sends the slog records to the given logger,
+// which allows ozzo-log to be used as the backend of log/slog. For example,
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(logger)))
+//
+// The slog levels are mapped to the closest RFC5424 levels (see SlogLevel), and
+// the record attributes become entry fields. Groups are mapped to field groups (see Logger.WithGroup).
+// As a slog record only carries the program counter of the logging call, the call stack of
+// the entries contains at most that frame, regardless of Logger.CallStackDepth. Records without
+// a time produce entries with a zero Time, which the built-in formatters omit.
+func NewSlogHandler(l *Logger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// SlogLevel converts a slog level into a log level.
+// Levels above slog.LevelError are mapped to LevelCritical, levels between slog.LevelInfo
+// and slog.LevelWarn are mapped to LevelNotice, and levels below slog.LevelInfo to LevelDebug.
+func SlogLevel(level slog.Level) Level {
+	switch {
+	case level > slog.LevelError:
+		return LevelCritical
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarning
+	case level > slog.LevelInfo:
+		return LevelNotice
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	}
+	return LevelDebug
+}
+
+// Enabled reports whether the logger may log messages of the given level, including through the rules of RemapLevel.
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return !h.logger.discards(SlogLevel(level))
+}
+
+// Handle converts a slog record into a log entry and logs it.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := &Entry{
+		Level:   SlogLevel(r.Level),
+		Message: r.Message,
+		Time:    r.Time,
+	}
+	logger := h.logger
+	if r.NumAttrs() > 0 {
+		fields := make(Fields, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			addSlogAttr(fields, a)
+			return true
+		})
+		if len(logger.groups) > 0 {
+			// nest the record attributes in the logger's groups
+			logger = logger.WithFields(fields)
+		} else {
+			entry.Fields = fields
+		}
+	}
+	if logger.callStackDepth(entry.Level, logger.Category) > 0 && r.PC != 0 {
+		entry.CallStack = slogCallStack(r.PC, logger.CallStackFilter)
+	}
+	if logger.accept(entry) {
+		logger.enqueue(entry)
+	}
+	return nil
+}
+
+// slogCallStack returns the call stack information of the frame identified by the program counter
+// if its file name contains the filter.
+func slogCallStack(pc uintptr, filter string) string {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" || filter != "" && !strings.Contains(frame.File, filter) {
+		return ""
+	}
+	return fmt.Sprintf("\n%s:%d", frame.File, frame.Line)
+}
+
+// WithAttrs returns a handler whose logger has the given attributes added as fields.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(Fields, len(attrs))
+	for _, a := range attrs {
+		addSlogAttr(fields, a)
+	}
+	return &slogHandler{logger: h.logger.WithFields(fields)}
+}
+
+// WithGroup returns a handler whose logger nests the subsequent attributes in the named group.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	return &slogHandler{logger: h.logger.WithGroup(name)}
+}
+
+// addSlogAttr adds a slog attribute to the fields. Groups are added as Fields values.
+// Attributes with empty keys are ignored, except groups whose attributes are inlined.
+func addSlogAttr(fields Fields, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := fields
+		if a.Key != "" {
+			group = make(Fields, len(a.Value.Group()))
+		}
+		for _, ga := range a.Value.Group() {
+			addSlogAttr(group, ga)
+		}
+		if a.Key != "" && len(group) > 0 {
+			fields[a.Key] = group
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	fields[a.Key] = a.Value.Any()
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected Level
+	}{
+		{slog.LevelDebug, LevelDebug},
+		{slog.LevelInfo, LevelInfo},
+		{slog.LevelInfo + 2, LevelNotice},
+		{slog.LevelWarn, LevelWarning},
+		{slog.LevelError, LevelError},
+		{slog.LevelError + 4, LevelCritical},
+	}
+	for _, test := range tests {
+		if level := SlogLevel(test.level); level != test.expected {
+			t.Errorf("SlogLevel(%v) = %v, expected %v", test.level, level, test.expected)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	logger := NewLogger()
+	logger.MaxLevel = LevelInfo
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	handler := NewSlogHandler(logger)
+	if handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("handler.Enabled(Debug) = true, expected false")
+	}
+	if !handler.Enabled(context.Background(), slog.LevelWarn) {
+		t.Errorf("handler.Enabled(Warn) = false, expected true")
+	}
+
+	l := slog.New(handler).With("service", "api").WithGroup("http")
+	l.Debug("filtered")
+	l.Warn("slow request", "status", 200, slog.Group("req", "method", "GET"))
+	logger.Close()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 1)
+	}
+	e := target.entries[0]
+	if e.Level != LevelWarning || e.Message != "slow request" {
+		t.Errorf("entry = %v %q, expected %v %q", e.Level, e.Message, LevelWarning, "slow request")
+	}
+	expected := `{"http":{"req":{"method":"GET"},"status":200},"service":"api"}`
+	if result := string(jsonValue(e.Fields)); result != expected {
+		t.Errorf("entry.Fields = %v, expected %v", result, expected)
+	}
+}
+
+func TestSlogHandlerRecord(t *testing.T) {
+	logger := NewLogger()
+	logger.CallStackDepth = 3
+	target := &MemoryTarget{ready: make(chan bool, 0)}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	handler := NewSlogHandler(logger)
+	slog.New(handler).Info("t1")
+	handler.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "t2", 0))
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	if e := target.entries[0]; !strings.HasPrefix(e.CallStack, "\n") || !strings.Contains(e.CallStack, "slog_test.go") || strings.Count(e.CallStack, "\n") != 1 {
+		t.Errorf("entries[0].CallStack = %q, expected the frame of the slog call", e.CallStack)
+	}
+	if e := target.entries[1]; !e.Time.IsZero() || e.CallStack != "" {
+		t.Errorf("entries[1] = %v/%q, expected a zero time and no call stack", e.Time, e.CallStack)
+	}
+	if msg := target.entries[1].String(); !strings.HasPrefix(msg, "[Info]") {
+		t.Errorf("entries[1].String() = %q, expected no time", msg)
+	}
+}
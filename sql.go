@@ -0,0 +1,228 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-ozzo/ozzo-log/internal/batching"
+)
+
+// SQLTarget inserts filtered log entries into a database table via database/sql, e.g. to meet auditing
+// requirements. Every entry is stored as a row of the columns of the time, the level name, the category,
+// the message and the fields encoded as a JSON object (NULL if the entry has no field).
+//
+// The entries are inserted in batches of up to BatchSize entries, which are sent when they are full,
+// every FlushInterval and when the target is closed. Every batch is inserted in a transaction, so that it
+// is stored entirely or not at all. If the transaction fails, the batch is inserted again after RetryInterval,
+// up to MaxRetries times; a batch still failing after that is dropped and reported to the error writer of
+// the logger, and the number of dropped entries is reported by CloseError.
+//
+// Entries are queued in a channel of BufferSize entries. When it is full, the logger waits for room.
+// The table and column names are used in the INSERT statement as is, so they must not come from untrusted input.
+// Since the database handle cannot be configured by ozzo-config, SQLTarget is not registered as a target type.
+type SQLTarget struct {
+	*Filter
+	Name           string            // the name identifying the target in diagnostics
+	Tags           map[string]string // the tags identifying the target in diagnostics
+	DB             *sql.DB           // the database to insert the entries into
+	Table          string            // the name of the table
+	TimeColumn     string            // the name of the time column
+	LevelColumn    string            // the name of the level column
+	CategoryColumn string            // the name of the category column
+	MessageColumn  string            // the name of the message column
+	FieldsColumn   string            // the name of the JSON fields column
+	// whether to use numbered placeholders ($1, $2, ...), as required by PostgreSQL, instead of "?".
+	NumberedPlaceholders bool
+	BatchSize            int           // the maximum number of entries inserted in a transaction
+	FlushInterval        time.Duration // how often a partial batch is inserted
+	MaxRetries           int           // how many times a failed batch is inserted again before it is dropped
+	RetryInterval        time.Duration // the time waited before inserting a failed batch again
+	BufferSize           int           // the size of the entry channel. 0 means the BufferSize of the logger.
+
+	entries           chan *Entry
+	defaultBufferSize int
+	query             string
+	batch             []*Entry       // the entries to be inserted in the next transaction
+	drops             batching.Drops // the entries in the dropped batches
+	closeErr          error
+	close             chan bool
+}
+
+// NewSQLTarget creates an SQLTarget inserting the entries into the given database.
+// The new SQLTarget takes these default options:
+// MaxLevel: LevelDebug, Table: "logs", TimeColumn: "time", LevelColumn: "level", CategoryColumn: "category",
+// MessageColumn: "message", FieldsColumn: "fields", BatchSize: 100, FlushInterval: 1s, MaxRetries: 3,
+// RetryInterval: 1s, BufferSize: 1024.
+func NewSQLTarget(db *sql.DB) *SQLTarget {
+	return &SQLTarget{
+		Filter:         &Filter{MaxLevel: LevelDebug},
+		DB:             db,
+		Table:          "logs",
+		TimeColumn:     "time",
+		LevelColumn:    "level",
+		CategoryColumn: "category",
+		MessageColumn:  "message",
+		FieldsColumn:   "fields",
+		BatchSize:      100,
+		FlushInterval:  time.Second,
+		MaxRetries:     3,
+		RetryInterval:  time.Second,
+		BufferSize:     1024,
+		close:          make(chan bool, 0),
+	}
+}
+
+// Open prepares SQLTarget for processing log messages.
+func (t *SQLTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.DB == nil {
+		return errors.New("SQLTarget.DB must be set")
+	}
+	if t.Table == "" || t.TimeColumn == "" || t.LevelColumn == "" || t.CategoryColumn == "" || t.MessageColumn == "" || t.FieldsColumn == "" {
+		return errors.New("SQLTarget.Table and the column names must be specified")
+	}
+	if t.BatchSize <= 0 {
+		return errors.New("SQLTarget.BatchSize must be greater than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("SQLTarget.BufferSize must be no less than 0")
+	}
+	if t.MaxRetries < 0 {
+		return errors.New("SQLTarget.MaxRetries must be no less than 0")
+	}
+	placeholders := []string{"?", "?", "?", "?", "?"}
+	if t.NumberedPlaceholders {
+		placeholders = []string{"$1", "$2", "$3", "$4", "$5"}
+	}
+	t.query = fmt.Sprintf("INSERT INTO %v (%v, %v, %v, %v, %v) VALUES (%v)", t.Table, t.TimeColumn, t.LevelColumn,
+		t.CategoryColumn, t.MessageColumn, t.FieldsColumn, strings.Join(placeholders, ", "))
+	bufferSize := t.BufferSize
+	if bufferSize == 0 {
+		bufferSize = t.defaultBufferSize
+	}
+	t.entries = make(chan *Entry, bufferSize)
+	t.batch = nil
+	t.drops = batching.Drops{Writer: errWriter}
+	go t.insertBatches()
+	return nil
+}
+
+func (t *SQLTarget) setDefaultBufferSize(size int) {
+	t.defaultBufferSize = size
+}
+
+// Process puts an allowed log entry into the channel of the entries to be inserted.
+func (t *SQLTarget) Process(e *Entry) {
+	if e == nil || t.Allow(e) {
+		t.entries <- e
+	}
+}
+
+// Close closes the SQL target.
+func (t *SQLTarget) Close() {
+	t.CloseError()
+}
+
+// CloseError closes the SQL target after inserting the pending entries,
+// and returns an error if some entries were not inserted.
+func (t *SQLTarget) CloseError() error {
+	<-t.close
+	return t.closeErr
+}
+
+// insertBatches collects the entries into batches and inserts them until the nil entry is received.
+func (t *SQLTarget) insertBatches() {
+	batching.Run(t.entries, t.FlushInterval, func(e *Entry) {
+		t.batch = append(t.batch, e)
+		if len(t.batch) >= t.BatchSize {
+			t.flush()
+		}
+	}, t.flush)
+	t.closeErr = t.drops.Err(t.label(), "insert", "entries")
+	t.close <- true
+}
+
+// flush inserts the pending batch.
+func (t *SQLTarget) flush() {
+	t.insert(t.batch)
+	t.batch = nil
+}
+
+// insert inserts a batch until it succeeds or the retries are exhausted.
+func (t *SQLTarget) insert(batch []*Entry) {
+	if len(batch) == 0 {
+		return
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = t.insertTx(batch); err == nil {
+			return
+		}
+		if attempt >= t.MaxRetries {
+			break
+		}
+		time.Sleep(t.RetryInterval)
+	}
+	t.drops.Drop(t.label(), len(batch), "entries", err)
+}
+
+// insertTx inserts a batch in a transaction.
+func (t *SQLTarget) insertTx(batch []*Entry) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(t.query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, e := range batch {
+		var fields interface{}
+		if len(e.Fields) > 0 {
+			fields = fieldsJSON(e.Fields)
+		}
+		if _, err := stmt.Exec(e.Time, e.Level.String(), e.Category, e.Message, fields); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	stmt.Close()
+	return tx.Commit()
+}
+
+// fieldsJSON encodes the fields as a JSON object like JSONFormatter does.
+func fieldsJSON(fields Fields) string {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, f := range sortedFields(fields, false) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(jsonValue(f.key))
+		buf.WriteByte(':')
+		buf.Write(jsonValue(f.value))
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *SQLTarget) Describe() TargetInfo {
+	return newTargetInfo("SQLTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *SQLTarget) label() string {
+	return targetLabel("SQLTarget", t.Name, t.Tags)
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+// testSQLDriver is a database/sql driver recording the rows of the committed transactions.
+type testSQLDriver struct {
+	lock     sync.Mutex
+	query    string
+	rows     []string
+	commits  int
+	failures int // the number of transactions to fail
+}
+
+func (d *testSQLDriver) Open(string) (driver.Conn, error) {
+	return &testSQLConn{driver: d}, nil
+}
+
+type testSQLConn struct {
+	driver *testSQLDriver
+	rows   []string
+}
+
+func (c *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	c.driver.lock.Lock()
+	c.driver.query = query
+	c.driver.lock.Unlock()
+	return &testSQLStmt{conn: c}, nil
+}
+
+func (c *testSQLConn) Close() error { return nil }
+
+func (c *testSQLConn) Begin() (driver.Tx, error) {
+	c.rows = nil
+	return c, nil
+}
+
+func (c *testSQLConn) Commit() error {
+	c.driver.lock.Lock()
+	defer c.driver.lock.Unlock()
+	if c.driver.failures > 0 {
+		c.driver.failures--
+		return errors.New("commit failed")
+	}
+	c.driver.rows = append(c.driver.rows, c.rows...)
+	c.driver.commits++
+	return nil
+}
+
+func (c *testSQLConn) Rollback() error { return nil }
+
+type testSQLStmt struct {
+	conn *testSQLConn
+}
+
+func (s *testSQLStmt) Close() error  { return nil }
+func (s *testSQLStmt) NumInput() int { return 5 }
+
+func (s *testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.rows = append(s.conn.rows, fmt.Sprintf("%v|%v|%v|%v", args[1], args[2], args[3], args[4]))
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testSQLStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var sqlDriverID int
+
+func openTestSQLDB(t *testing.T, d *testSQLDriver) *sql.DB {
+	sqlDriverID++
+	name := fmt.Sprintf("ozzo-log-test-%v", sqlDriverID)
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	return db
+}
+
+func TestSQLTarget(t *testing.T) {
+	d := &testSQLDriver{failures: 1}
+	db := openTestSQLDB(t, d)
+	defer db.Close()
+
+	logger := log.NewLogger()
+	target := log.NewSQLTarget(db)
+	target.Table = "audit"
+	target.NumberedPlaceholders = true
+	target.BatchSize = 2
+	target.RetryInterval = 0
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.WithField("user", "bob").Error("t2")
+	logger.Warning("t3")
+	if err := logger.CloseError(); err != nil {
+		t.Errorf("logger.CloseError() = %v, expected nil", err)
+	}
+
+	if expected := "INSERT INTO audit (time, level, category, message, fields) VALUES ($1, $2, $3, $4, $5)"; d.query != expected {
+		t.Errorf("query = %q, expected %q", d.query, expected)
+	}
+	expected := `Info|app|t1|<nil>,Error|app|t2|{"user":"bob"},Warning|app|t3|<nil>`
+	if rows := strings.Join(d.rows, ","); rows != expected {
+		t.Errorf("rows = %v, expected %v", rows, expected)
+	}
+	if d.commits != 2 {
+		t.Errorf("commits = %v, expected 2 batches", d.commits)
+	}
+}
+
+func TestSQLTargetUndelivered(t *testing.T) {
+	d := &testSQLDriver{failures: 2}
+	db := openTestSQLDB(t, d)
+	defer db.Close()
+
+	logger := log.NewLogger()
+	logger.ErrorWriter = ioutil.Discard
+	target := log.NewSQLTarget(db)
+	target.MaxRetries = 1
+	target.RetryInterval = 0
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	err := logger.CloseError()
+
+	if err == nil || !strings.Contains(err.Error(), "SQLTarget was unable to insert 1 entries") {
+		t.Errorf("logger.CloseError() = %v, expected the undelivered entries to be reported", err)
+	}
+	if len(d.rows) != 0 {
+		t.Errorf("rows = %v, expected none", d.rows)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// templatePart writes a part of a log message formatted by a template formatter.
+type templatePart func(buf *bytes.Buffer, l *Logger, e *Entry)
+
+// templateTokens maps the tokens supported by NewTemplateFormatter to the parts they render.
+var templateTokens = map[string]templatePart{
+	"time": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		if !e.Time.IsZero() {
+			buf.WriteString(e.Time.Format(time.RFC3339))
+		}
+	},
+	"level": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		buf.WriteString(l.levelName(e.Level))
+	},
+	"category": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		buf.WriteString(e.Category)
+	},
+	"message": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		buf.WriteString(e.Message)
+	},
+	"fields": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		var fields bytes.Buffer
+		for _, f := range sortedFields(e.Fields, true) {
+			writeLogfmtField(&fields, f.key, f.value)
+		}
+		if fields.Len() > 0 {
+			buf.Write(fields.Bytes()[1:])
+		}
+	},
+	"stack": func(buf *bytes.Buffer, l *Logger, e *Entry) {
+		buf.WriteString(strings.TrimPrefix(e.CallStack, "\n"))
+	},
+}
+
+// NewTemplateFormatter creates a formatter arranging the parts of a log message as given by a template, e.g.
+//
+//	{time} {level} {message} {fields} [{category}]
+//
+// The tokens are replaced with the entry time (in RFC3339, or empty if it is not set), the level, the category,
+// the message, the fields (sorted by name and rendered in the logfmt style, with the fields of groups named
+// with dots) and the call stack, while the rest of the template is copied as is. Unless the template has the
+// {stack} token, the call stack (if any) is appended to the message, as done by DefaultFormatter.
+// An error is returned if the template has an unknown token.
+func NewTemplateFormatter(tmpl string) (Formatter, error) {
+	var parts []templatePart
+	hasStack := false
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		token := tmpl[start+1 : end]
+		part, ok := templateTokens[token]
+		if !ok {
+			return nil, fmt.Errorf("unknown token %q in the log template", tmpl[start:end+1])
+		}
+		if literal := tmpl[:start]; literal != "" {
+			parts = append(parts, func(buf *bytes.Buffer, l *Logger, e *Entry) {
+				buf.WriteString(literal)
+			})
+		}
+		parts = append(parts, part)
+		hasStack = hasStack || token == "stack"
+		tmpl = tmpl[end+1:]
+	}
+	if literal := tmpl; literal != "" {
+		parts = append(parts, func(buf *bytes.Buffer, l *Logger, e *Entry) {
+			buf.WriteString(literal)
+		})
+	}
+	return func(l *Logger, e *Entry) string {
+		buf := new(bytes.Buffer)
+		for _, part := range parts {
+			part(buf, l, e)
+		}
+		if !hasStack {
+			buf.WriteString(e.CallStack)
+		}
+		return buf.String()
+	}, nil
+}
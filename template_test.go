@@ -0,0 +1,51 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTemplateFormatter(t *testing.T) {
+	tests := []struct {
+		tmpl, expected string
+	}{
+		{"{time} {level} {message} {fields} [{category}]", `2016-01-02T03:04:05Z Warning slow query ms=120 table=users [app.db]`},
+		{"{level}: {fields} | {message}", `Warning: ms=120 table=users | slow query`},
+		{"{message}", `slow query`},
+		{"no tokens", `no tokens`},
+		{"{message} {unclosed", `slow query {unclosed`},
+	}
+	for _, test := range tests {
+		formatter, err := NewTemplateFormatter(test.tmpl)
+		if err != nil {
+			t.Errorf("NewTemplateFormatter(%q) = %v", test.tmpl, err)
+			continue
+		}
+		if result := formatter(nil, newFormatterTestEntry()); result != test.expected {
+			t.Errorf("NewTemplateFormatter(%q)() = %v, expected %v", test.tmpl, result, test.expected)
+		}
+	}
+
+	e := newFormatterTestEntry()
+	e.Fields = Fields{"http": Fields{"status": 200}}
+	e.CallStack = "\nmain.go:10"
+	formatter, _ := NewTemplateFormatter("{message} {fields}")
+	if result, expected := formatter(nil, e), "slow query http.status=200\nmain.go:10"; result != expected {
+		t.Errorf("NewTemplateFormatter()() = %q, expected %q", result, expected)
+	}
+	formatter, _ = NewTemplateFormatter("{message} ({stack}) {fields}")
+	if result, expected := formatter(nil, e), "slow query (main.go:10) http.status=200"; result != expected {
+		t.Errorf("NewTemplateFormatter()() = %q, expected %q", result, expected)
+	}
+}
+
+func TestNewTemplateFormatterUnknownToken(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{time} {lvl} {message}")
+	if formatter != nil || err == nil || !strings.Contains(err.Error(), `unknown token "{lvl}"`) {
+		t.Errorf("NewTemplateFormatter() = %v, expected an unknown token error", err)
+	}
+}
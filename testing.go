@@ -0,0 +1,70 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "io"
+
+// TestLogger is the part of testing.TB used by TestingTarget, so that the package does not depend on the testing package.
+type TestLogger interface {
+	Log(args ...interface{})
+}
+
+// TestingTarget writes filtered log messages to a test via t.Log, so that they are attributed to the right test
+// in the output of "go test" and only shown if the test fails or is run in verbose mode.
+//
+// The messages are passed to t.Log by the goroutine dispatching the entries of the logger, and testing panics if
+// t.Log is called after the test has completed. The logger must therefore be closed before the test returns,
+// e.g. with "defer logger.Close()" or "t.Cleanup(logger.Close)".
+type TestingTarget struct {
+	*Filter
+	Name string            // the name identifying the target in diagnostics
+	Tags map[string]string // the tags identifying the target in diagnostics
+	T    TestLogger        // the test to log the messages to, such as a *testing.T
+
+	close chan bool
+}
+
+// NewTestingTarget creates a TestingTarget logging the messages to the given test, such as a *testing.T or a *testing.B.
+// The new TestingTarget takes these default options:
+// MaxLevel: LevelDebug
+func NewTestingTarget(t TestLogger) *TestingTarget {
+	return &TestingTarget{
+		Filter: &Filter{MaxLevel: LevelDebug},
+		T:      t,
+		close:  make(chan bool, 0),
+	}
+}
+
+// Open prepares TestingTarget for processing log messages.
+func (t *TestingTarget) Open(io.Writer) error {
+	t.Filter.Init()
+	return nil
+}
+
+// Process logs an allowed log message to the test.
+func (t *TestingTarget) Process(e *Entry) {
+	if e == nil {
+		t.close <- true
+		return
+	}
+	if t.Allow(e) {
+		t.T.Log(e.String())
+	}
+}
+
+// Close closes the testing target.
+func (t *TestingTarget) Close() {
+	<-t.close
+}
+
+// Describe returns the type, name, tags and filter settings of the target.
+func (t *TestingTarget) Describe() TargetInfo {
+	return newTargetInfo("TestingTarget", t.Name, t.Tags, t.Filter)
+}
+
+// label returns the description of the target used in diagnostics.
+func (t *TestingTarget) label() string {
+	return targetLabel("TestingTarget", t.Name, t.Tags)
+}
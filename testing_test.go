@@ -0,0 +1,68 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-log"
+)
+
+// recordingTest records the messages logged to a test.
+type recordingTest struct {
+	messages []string
+}
+
+func (r *recordingTest) Log(args ...interface{}) {
+	r.messages = append(r.messages, fmt.Sprint(args...))
+}
+
+func TestTestingTarget(t *testing.T) {
+	var _ log.TestLogger = t
+
+	test := &recordingTest{}
+	logger := log.NewLogger()
+	logger.Formatter = func(l *log.Logger, e *log.Entry) string {
+		return e.Level.String() + " " + e.Message
+	}
+	target := log.NewTestingTarget(test)
+	target.MaxLevel = log.LevelInfo
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	logger.Debug("t2")
+	logger.Error("t3")
+	logger.Close()
+
+	if result := strings.Join(test.messages, ","); result != "Info t1,Error t3" {
+		t.Errorf("messages = %v, expected %v", result, "Info t1,Error t3")
+	}
+}
+
+func TestTestingTargetDescribe(t *testing.T) {
+	var decisions []log.FilterDecision
+	logger := log.NewLogger()
+	logger.TraceFilters = true
+	logger.FilterTracer = func(e *log.Entry, d []log.FilterDecision) {
+		decisions = d
+	}
+	target := log.NewTestingTarget(&recordingTest{})
+	target.Name = "unit"
+	target.Tags = map[string]string{"suite": "db"}
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+	logger.Info("t1")
+	infos := logger.DescribeTargets()
+	logger.Close()
+
+	if len(infos) != 1 || infos[0].Type != "TestingTarget" || infos[0].Name != "unit" || infos[0].Tags["suite"] != "db" {
+		t.Errorf("DescribeTargets() = %+v, expected the name and tags of the target", infos)
+	}
+	if len(decisions) != 1 || decisions[0].Target != `TestingTarget "unit" [suite=db]` {
+		t.Errorf("decisions = %+v, expected the label of the target", decisions)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// FilterDecision is the decision of the filter of a target about an entry, as reported by the filter trace
+// enabled by Logger.TraceFilters.
+type FilterDecision struct {
+	Target   string // the description of the target, with its name and tags, or its Go type for the custom targets
+	Accepted bool   // whether the filter of the target accepts the entry
+	// why the entry is accepted or rejected, e.g. `level Debug is less severe than MaxLevel Info`
+	// or `category "db" does not match the categories [http.*]`.
+	Reason string
+}
+
+// filtered is implemented by the targets embedding a Filter.
+type filtered interface {
+	filter() *Filter
+}
+
+// filter returns the filter, so that the logger can find the filters of the targets embedding one.
+func (t *Filter) filter() *Filter {
+	return t
+}
+
+// labeled is implemented by the built-in targets.
+type labeled interface {
+	label() string
+}
+
+// targetDescription returns the description of a target used in the diagnostics, with its name and tags for the
+// built-in targets, or its Go type for the custom targets.
+func targetDescription(target Target) string {
+	if t, ok := target.(labeled); ok {
+		return t.label()
+	}
+	return fmt.Sprintf("%T", target)
+}
+
+// traceFilters reports the decisions of the filters of the targets about an entry to FilterTracer,
+// or to ErrorWriter if FilterTracer is nil. The targets wrapped by AsyncTarget are reported instead of it.
+func (l *coreLogger) traceFilters(e *Entry) {
+	decisions := make([]FilterDecision, 0, len(l.Targets))
+	for _, target := range l.Targets {
+		// the entries queued by an AsyncTarget are filtered by the wrapped target
+		for async, ok := target.(*AsyncTarget); ok && async.Target != nil; async, ok = target.(*AsyncTarget) {
+			target = async.Target
+		}
+		decision := FilterDecision{Target: targetDescription(target), Accepted: true, Reason: "the target has no filter"}
+		if t, ok := target.(filtered); ok && t.filter() != nil {
+			decision.Reason = t.filter().rejection(e)
+			if decision.Accepted = decision.Reason == ""; decision.Accepted {
+				decision.Reason = fmt.Sprintf("level %v and category %q are allowed", e.Level, e.Category)
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+	if l.FilterTracer != nil {
+		l.FilterTracer(e, decisions)
+		return
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "Filter trace of [%v][%v] %v\n", e.Level, e.Category, e.Message)
+	for _, d := range decisions {
+		verdict := "rejected"
+		if d.Accepted {
+			verdict = "accepted"
+		}
+		fmt.Fprintf(buf, "  %v %v: %v\n", d.Target, verdict, d.Reason)
+	}
+	l.ErrorWriter.Write(buf.Bytes())
+}
+
+// rejection returns why the filter rejects an entry, or an empty string if it allows the entry.
+// It must follow the same rules as allow.
+func (t *Filter) rejection(e *Entry) string {
+	if !t.Enabled() {
+		return "the filter is disabled"
+	}
+	if e.Level > t.MaxLevel {
+		return fmt.Sprintf("level %v is less severe than MaxLevel %v", e.Level, t.MaxLevel)
+	}
+	if t.MinLevel != 0 && e.Level > t.MinLevel {
+		return fmt.Sprintf("level %v is less severe than MinLevel %v", e.Level, t.MinLevel)
+	}
+	if t.MostSevereLevel != 0 && e.Level < t.MostSevereLevel {
+		return fmt.Sprintf("level %v is more severe than MostSevereLevel %v", e.Level, t.MostSevereLevel)
+	}
+	for _, f := range t.and {
+		if reason := f.rejection(e); reason != "" {
+			return "a filter of And rejects it: " + reason
+		}
+	}
+	if len(t.or) > 0 && !t.anyAllows(e) {
+		reasons := make([]string, len(t.or))
+		for i, f := range t.or {
+			reasons[i] = f.rejection(e)
+		}
+		return "all the filters of Or reject it: " + strings.Join(reasons, "; ")
+	}
+	if !t.allow(e) {
+		return fmt.Sprintf("category %q does not match the categories %v", e.Category, t.Categories)
+	}
+	return ""
+}
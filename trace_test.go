@@ -0,0 +1,82 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func newTraceTestLogger(errWriter *bytes.Buffer) *Logger {
+	logger := NewLogger()
+	logger.ErrorWriter = errWriter
+	logger.TraceFilters = true
+
+	info := NewConsoleTarget()
+	info.Name = "info"
+	info.Writer = ioutil.Discard
+	info.MaxLevel = LevelInfo
+	db := NewConsoleTarget()
+	db.Name = "db"
+	db.Writer = ioutil.Discard
+	db.Categories = []string{"db.*"}
+	errs := &Filter{MaxLevel: LevelError}
+	composite := NewConsoleTarget()
+	composite.Name = "composite"
+	composite.Writer = ioutil.Discard
+	composite.Filter = errs.Or(&Filter{MaxLevel: LevelDebug, Categories: []string{"http"}})
+	logger.Targets = append(logger.Targets, info, NewAsyncTarget(db), composite, &MemoryTarget{ready: make(chan bool, 0)})
+	return logger
+}
+
+func TestTraceFilters(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTraceTestLogger(&buf)
+	logger.Open()
+	logger.GetLogger("db.query").Debug("slow query")
+	logger.Close()
+
+	expected := `Filter trace of [Debug][db.query] slow query
+  ConsoleTarget "info" rejected: level Debug is less severe than MaxLevel Info
+  ConsoleTarget "db" accepted: level Debug and category "db.query" are allowed
+  ConsoleTarget "composite" rejected: all the filters of Or reject it: level Debug is less severe than MaxLevel Error; category "db.query" does not match the categories [http]
+  *log.MemoryTarget accepted: the target has no filter
+`
+	if buf.String() != expected {
+		t.Errorf("trace = %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestFilterTracer(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTraceTestLogger(&buf)
+	var decisions []FilterDecision
+	logger.FilterTracer = func(e *Entry, d []FilterDecision) {
+		decisions = d
+	}
+	logger.Targets[0].(*ConsoleTarget).SetEnabled(false)
+	logger.Open()
+	logger.GetLogger("http").Info("request")
+	logger.Close()
+
+	if buf.Len() != 0 {
+		t.Errorf("ErrorWriter = %q, expected the trace to be passed to FilterTracer only", buf.String())
+	}
+	expected := []FilterDecision{
+		{`ConsoleTarget "info"`, false, "the filter is disabled"},
+		{`ConsoleTarget "db"`, false, `category "http" does not match the categories [db.*]`},
+		{`ConsoleTarget "composite"`, true, `level Info and category "http" are allowed`},
+		{"*log.MemoryTarget", true, "the target has no filter"},
+	}
+	if len(decisions) != len(expected) {
+		t.Fatalf("decisions = %v, expected %v", decisions, expected)
+	}
+	for i, d := range decisions {
+		if d != expected[i] {
+			t.Errorf("decisions[%v] = %+v, expected %+v", i, d, expected[i])
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"os"
+)
+
+// Transient is the default name of the param or field marking the transient messages, e.g. progress updates,
+// which ConsoleTarget writes over each other on a single line when its Writer is a terminal:
+//
+//	progress := logger.WithParam(log.Transient, true)
+//	for i := range files {
+//		progress.Info("copying %v/%v", i+1, len(files))
+//		...
+//	}
+//	logger.Info("copied %v files", len(files))
+const Transient = "transient"
+
+// isTerminal reports whether the writer is a terminal. It is a variable so that the tests can replace it.
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// isTransient reports whether the entry has the given param or field set to true.
+func isTransient(e *Entry, key string) bool {
+	if v, ok := e.Params[key].(bool); ok {
+		return v
+	}
+	v, _ := e.Fields[key].(bool)
+	return v
+}
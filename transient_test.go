@@ -0,0 +1,66 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func logTransient(t *testing.T, terminal bool) string {
+	defer func(f func(io.Writer) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(io.Writer) bool { return terminal }
+
+	logger := NewLogger()
+	logger.Formatter = func(l *Logger, e *Entry) string {
+		return e.Message
+	}
+	target := NewConsoleTarget()
+	var buf bytes.Buffer
+	target.Writer = &buf
+	target.ColorMode = false
+	logger.Targets = append(logger.Targets, target)
+	logger.Open()
+
+	progress := logger.WithParam(Transient, true)
+	progress.Info("1/2")
+	progress.Info("2/2")
+	logger.Info("done")
+	progress.Info("cleaning")
+	if err := logger.CloseError(); err != nil {
+		t.Fatalf("logger.CloseError() = %v", err)
+	}
+	return buf.String()
+}
+
+func TestConsoleTargetTransient(t *testing.T) {
+	expected := "\r1/2\033[K\r2/2\033[K\ndone\n\rcleaning\033[K\n"
+	if out := logTransient(t, true); out != expected {
+		t.Errorf("terminal output = %q, expected %q", out, expected)
+	}
+	expected = "1/2\n2/2\ndone\ncleaning\n"
+	if out := logTransient(t, false); out != expected {
+		t.Errorf("redirected output = %q, expected %q", out, expected)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		entry    *Entry
+		expected bool
+	}{
+		{&Entry{}, false},
+		{&Entry{Params: Fields{Transient: true}}, true},
+		{&Entry{Fields: Fields{Transient: true}}, true},
+		{&Entry{Fields: Fields{Transient: "yes"}}, false},
+		{&Entry{Params: Fields{Transient: false}, Fields: Fields{Transient: true}}, false},
+	}
+	for i, test := range tests {
+		if result := isTransient(test.entry, Transient); result != test.expected {
+			t.Errorf("%v: isTransient() = %v, expected %v", i, result, test.expected)
+		}
+	}
+}
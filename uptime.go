@@ -0,0 +1,23 @@
+// Copyright 2016 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "time"
+
+// Uptime is the value of the "uptime" field added to every entry when Logger.IncludeUptime is true:
+// the time elapsed since the logger was opened, which orders and spaces the entries correctly even on
+// devices whose wall clock is unreliable. All formatters render it like time.Duration, e.g. "1m2.5s",
+// including the JSON ones, which render the other durations as numbers of nanoseconds.
+type Uptime time.Duration
+
+// String returns the uptime formatted like time.Duration.
+func (u Uptime) String() string {
+	return time.Duration(u).String()
+}
+
+// LogValue renders the uptime as a string, so that the structured formatters render it as a duration.
+func (u Uptime) LogValue() interface{} {
+	return u.String()
+}